@@ -0,0 +1,98 @@
+// 自动排课命令：为一个学期的课程和教学班生成无冲突的上课时间和教室。
+// 默认只预览方案，确认无误后使用 -apply 和预览输出的 fingerprint 写入数据库。
+//
+//	go run ./cmd/timetable -semester "2024 Spring"
+//	go run ./cmd/timetable -semester "2024 Spring" -apply -fingerprint 9c1e5b7a0d3f2e41
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "log/slog"
+    "os"
+    "text/tabwriter"
+
+    "course-management/config"
+    "course-management/logging"
+    "course-management/models"
+)
+
+func main() {
+    semester := flag.String("semester", "", "要排课的学期，如 \"2024 Spring\"")
+    apply := flag.Bool("apply", false, "将方案写入数据库（需同时提供 -fingerprint）")
+    fingerprint := flag.String("fingerprint", "", "预览时输出的方案指纹")
+    flag.Parse()
+
+    if *semester == "" || (*apply && *fingerprint == "") {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    cfg, err := config.LoadConfig()
+    if err != nil {
+        fatal("配置加载失败", err)
+    }
+    slog.SetDefault(logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format))
+
+    db, err := models.NewDatabase(cfg.Database)
+    if err != nil {
+        fatal("数据库连接失败", err)
+    }
+    defer db.Close()
+
+    ctx := context.Background()
+    var proposal *models.TimetableProposal
+    if *apply {
+        proposal, err = db.ApplyTimetable(ctx, *semester, *fingerprint)
+    } else {
+        proposal, err = db.GenerateTimetable(ctx, *semester)
+    }
+
+    switch {
+    case errors.Is(err, models.ErrTimetableInfeasible):
+        printReasons(proposal)
+        os.Exit(1)
+    case errors.Is(err, models.ErrTimetableChanged):
+        fatal("课程或约束已变化，请重新预览", err)
+    case err != nil:
+        fatal("排课失败", err)
+    }
+
+    if !proposal.Feasible {
+        printReasons(proposal)
+        os.Exit(1)
+    }
+
+    w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "课程\t时间\t教室\t原时间\t原教室\t")
+    for _, entry := range proposal.Entries {
+        mark := ""
+        if entry.Changed() {
+            mark = "*"
+        }
+        fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\t\n", mark, entry.Label, entry.TimeSlot, entry.Location,
+            entry.PreviousTimeSlot, entry.PreviousLocation)
+    }
+    w.Flush()
+
+    if *apply {
+        fmt.Printf("\n已写入 %s 学期的排课方案\n", proposal.Semester)
+    } else {
+        fmt.Printf("\n* 表示有变化。确认无误后执行：\n  go run ./cmd/timetable -semester %q -apply -fingerprint %s\n",
+            proposal.Semester, proposal.Fingerprint)
+    }
+}
+
+func printReasons(proposal *models.TimetableProposal) {
+    fmt.Fprintf(os.Stderr, "%s 学期无法生成无冲突的课表：\n", proposal.Semester)
+    for _, reason := range proposal.Reasons {
+        fmt.Fprintf(os.Stderr, "  - %s\n", reason)
+    }
+}
+
+func fatal(msg string, err error) {
+    slog.Error(msg, "error", err)
+    os.Exit(1)
+}
@@ -1,23 +1,31 @@
 package config
 
 import (
+    "fmt"
     "os"
     "strconv"
     "strings"
     "time"
 
 	"course-management/models"
+	"course-management/ratelimit"
+	"course-management/tracing"
     
     "github.com/joho/godotenv"
 )
 
 type Config struct {
-    App      AppConfig       `json:"app"`
-    Server   ServerConfig    `json:"server"`
-    Database models.DBConfig `json:"database"`
-    CORS     CORSConfig      `json:"cors"`
-    Security SecurityConfig  `json:"security"`
-    Log      LogConfig       `json:"log"`
+    App         AppConfig         `json:"app"`
+    Server      ServerConfig      `json:"server"`
+    Database    models.DBConfig   `json:"database"`
+    CORS        CORSConfig        `json:"cors"`
+    Security    SecurityConfig    `json:"security"`
+    Log         LogConfig         `json:"log"`
+    Health      HealthConfig      `json:"health"`
+    Metrics     MetricsConfig     `json:"metrics"`
+    Tracing     tracing.Config    `json:"tracing"`
+    RateLimit   RateLimitConfig   `json:"rate_limit"`
+    Idempotency IdempotencyConfig `json:"idempotency"`
 }
 
 type AppConfig struct {
@@ -26,7 +34,14 @@ type AppConfig struct {
 }
 
 type ServerConfig struct {
-    Port int `json:"port"`
+    Port              int           `json:"port"`
+    ReadTimeout       time.Duration `json:"read_timeout"`
+    ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+    WriteTimeout      time.Duration `json:"write_timeout"`
+    IdleTimeout       time.Duration `json:"idle_timeout"`
+    MaxHeaderBytes    int           `json:"max_header_bytes"`
+    ShutdownDelay     time.Duration `json:"shutdown_delay"`   // 标记未就绪后等待负载均衡器摘除流量的时间
+    ShutdownTimeout   time.Duration `json:"shutdown_timeout"` // 等待进行中请求完成的最长时间
 }
 
 type CORSConfig struct {
@@ -46,6 +61,28 @@ type LogConfig struct {
     Format string `json:"format"`
 }
 
+// 限流配置，按路由分组设置不同的令牌桶
+type RateLimitConfig struct {
+    Enabled    bool           `json:"enabled"`
+    Default    ratelimit.Rule `json:"default"`    // 所有接口，按IP
+    Signup     ratelimit.Rule `json:"signup"`     // 学生注册 POST /students，按IP
+    Enrollment ratelimit.Rule `json:"enrollment"` // 选课、退课、换课和购物车结算，按学生
+}
+
+type IdempotencyConfig struct {
+    Enabled bool          `json:"enabled"`
+    TTL     time.Duration `json:"ttl"` // 保存首次响应的时长，期间相同的重试请求直接重放
+}
+
+type MetricsConfig struct {
+    Enabled bool   `json:"enabled"`
+    Path    string `json:"path"`
+}
+
+type HealthConfig struct {
+    CheckTimeout time.Duration `json:"check_timeout"` // 就绪检查中数据库检查的超时时间
+}
+
 func LoadConfig() (*Config, error) {
     // 加载.env文件（如果存在）
     if err := godotenv.Load(); err != nil {
@@ -58,7 +95,14 @@ func LoadConfig() (*Config, error) {
             GinMode:     getEnvWithDefault("GIN_MODE", "debug"),
         },
         Server: ServerConfig{
-            Port: getIntEnvWithDefault("SERVER_PORT", 8080),
+            Port:              getIntEnvWithDefault("SERVER_PORT", 8080),
+            ReadTimeout:       getDurationEnvWithDefault("SERVER_READ_TIMEOUT", 15*time.Second),
+            ReadHeaderTimeout: getDurationEnvWithDefault("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+            WriteTimeout:      getDurationEnvWithDefault("SERVER_WRITE_TIMEOUT", 30*time.Second),
+            IdleTimeout:       getDurationEnvWithDefault("SERVER_IDLE_TIMEOUT", 60*time.Second),
+            MaxHeaderBytes:    getIntEnvWithDefault("SERVER_MAX_HEADER_BYTES", 1<<20),
+            ShutdownDelay:     getDurationEnvWithDefault("SERVER_SHUTDOWN_DELAY", 0),
+            ShutdownTimeout:   getDurationEnvWithDefault("SERVER_SHUTDOWN_TIMEOUT", 20*time.Second),
         },
         Database: models.DBConfig{
             Host:     getEnvWithDefault("DB_HOST", "localhost"),
@@ -67,6 +111,9 @@ func LoadConfig() (*Config, error) {
             Password: getEnvWithDefault("DB_PASSWORD", ""),
             DBName:   getEnvWithDefault("DB_NAME", "course_management"),
             SSLMode:  getEnvWithDefault("DB_SSLMODE", "disable"),
+
+            AutoMigrate:  getBoolEnvWithDefault("DB_AUTO_MIGRATE", true),
+            QueryTimeout: getDurationEnvWithDefault("DB_QUERY_TIMEOUT", 5*time.Second),
         },
         CORS: CORSConfig{
             AllowedOrigins:   parseOrigins(getEnvWithDefault("CORS_ALLOWED_ORIGINS", "http://localhost:3000")),
@@ -82,6 +129,58 @@ func LoadConfig() (*Config, error) {
             Level:  getEnvWithDefault("LOG_LEVEL", "info"),
             Format: getEnvWithDefault("LOG_FORMAT", "text"),
         },
+        Health: HealthConfig{
+            CheckTimeout: getDurationEnvWithDefault("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+        },
+        Metrics: MetricsConfig{
+            Enabled: getBoolEnvWithDefault("METRICS_ENABLED", true),
+            Path:    getEnvWithDefault("METRICS_PATH", "/metrics"),
+        },
+        Tracing: tracing.Config{
+            Enabled:      getBoolEnvWithDefault("TRACING_ENABLED", false),
+            ServiceName:  getEnvWithDefault("TRACING_SERVICE_NAME", "course-management"),
+            Exporter:     getEnvWithDefault("TRACING_EXPORTER", "otlp"),
+            OTLPEndpoint: getEnvWithDefault("TRACING_OTLP_ENDPOINT", ""),
+            OTLPInsecure: getBoolEnvWithDefault("TRACING_OTLP_INSECURE", false),
+            FilePath:     getEnvWithDefault("TRACING_FILE", "traces.json"),
+            SampleRatio:  getFloatEnvWithDefault("TRACING_SAMPLE_RATIO", 1.0),
+        },
+        RateLimit: RateLimitConfig{
+            Enabled: getBoolEnvWithDefault("RATE_LIMIT_ENABLED", true),
+            Default: ratelimit.Rule{
+                PerMinute: getIntEnvWithDefault("RATE_LIMIT_DEFAULT_PER_MINUTE", 600),
+                Burst:     getIntEnvWithDefault("RATE_LIMIT_DEFAULT_BURST", 100),
+            },
+            Signup: ratelimit.Rule{
+                PerMinute: getIntEnvWithDefault("RATE_LIMIT_SIGNUP_PER_MINUTE", 5),
+                Burst:     getIntEnvWithDefault("RATE_LIMIT_SIGNUP_BURST", 3),
+            },
+            Enrollment: ratelimit.Rule{
+                PerMinute: getIntEnvWithDefault("RATE_LIMIT_ENROLLMENT_PER_MINUTE", 30),
+                Burst:     getIntEnvWithDefault("RATE_LIMIT_ENROLLMENT_BURST", 10),
+            },
+        },
+        Idempotency: IdempotencyConfig{
+            Enabled: getBoolEnvWithDefault("IDEMPOTENCY_ENABLED", true),
+            TTL:     getDurationEnvWithDefault("IDEMPOTENCY_TTL", 24*time.Hour),
+        },
+    }
+    config.Database.Tracing = config.Tracing.Enabled
+    
+    if config.RateLimit.Enabled {
+        rules := []struct {
+            name string
+            rule ratelimit.Rule
+        }{
+            {"default", config.RateLimit.Default},
+            {"signup", config.RateLimit.Signup},
+            {"enrollment", config.RateLimit.Enrollment},
+        }
+        for _, r := range rules {
+            if err := r.rule.Validate(); err != nil {
+                return nil, fmt.Errorf("invalid %s rate limit: %w", r.name, err)
+            }
+        }
     }
     
     return config, nil
@@ -105,6 +204,16 @@ func getIntEnvWithDefault(key string, defaultValue int) int {
     return defaultValue
 }
 
+// 辅助函数：获取浮点数环境变量
+func getFloatEnvWithDefault(key string, defaultValue float64) float64 {
+    if value := os.Getenv(key); value != "" {
+        if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+            return floatValue
+        }
+    }
+    return defaultValue
+}
+
 // 辅助函数：获取布尔环境变量
 func getBoolEnvWithDefault(key string, defaultValue bool) bool {
     if value := os.Getenv(key); value != "" {
@@ -115,6 +224,16 @@ func getBoolEnvWithDefault(key string, defaultValue bool) bool {
     return defaultValue
 }
 
+// 辅助函数：获取时长环境变量，格式如 "500ms"、"5s"、"1m"
+func getDurationEnvWithDefault(key string, defaultValue time.Duration) time.Duration {
+    if value := os.Getenv(key); value != "" {
+        if durationValue, err := time.ParseDuration(value); err == nil {
+            return durationValue
+        }
+    }
+    return defaultValue
+}
+
 // 辅助函数：解析CORS源列表
 func parseOrigins(originsStr string) []string {
     if originsStr == "" {
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+    // 课程在读人数变化，发给订阅了该课程的所有连接
+    SeatsChanged = "seats.changed"
+
+    // 以下为学生个人事件，只发给该学生的连接
+    Enrolled       = "enrollment.enrolled"
+    Dropped        = "enrollment.dropped"
+    RemovedByAdmin = "enrollment.removed_by_admin"
+    Moved          = "enrollment.moved"
+    StatusChanged  = "enrollment.status_changed"
+
+    // 候补递补成功，紧随同一选课的 Enrolled 事件发布
+    WaitlistPromoted = "enrollment.waitlist_promoted"
+)
+
+// 推送给客户端的事件。SeatsChanged 事件的 StudentID 为 0；个人事件的人数字段为空
+type Event struct {
+    Type           string    `json:"type"`
+    CourseID       int       `json:"course_id"`
+    StudentID      int       `json:"student_id,omitempty"`
+    Status         string    `json:"status,omitempty"`
+    EnrolledCount  *int      `json:"enrolled_count,omitempty"`
+    Capacity       *int      `json:"capacity,omitempty"`
+    AvailableSeats *int      `json:"available_seats,omitempty"`
+    At             time.Time `json:"at"`
+}
+
+// 单个订阅缓冲的事件数，超过时视为客户端过慢并断开该订阅
+const subscriptionBuffer = 64
+
+// 进程内事件总线。数据库事务提交后发布事件，SSE 连接订阅感兴趣的事件。
+// 只在单个进程内有效，多实例部署时每个实例只能收到本实例处理的写操作
+type Bus struct {
+    mu     sync.Mutex
+    subs   map[*Subscription]struct{}
+    closed bool
+}
+
+func NewBus() *Bus {
+    return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// 一个订阅。C 关闭表示订阅已结束：客户端消费过慢、总线已关闭或已取消订阅
+type Subscription struct {
+    C <-chan Event
+
+    ch    chan Event
+    match func(Event) bool
+}
+
+// 订阅 match 返回 true 的事件。总线已关闭时返回的订阅 C 已关闭
+func (b *Bus) Subscribe(match func(Event) bool) *Subscription {
+    ch := make(chan Event, subscriptionBuffer)
+    sub := &Subscription{C: ch, ch: ch, match: match}
+
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if b.closed {
+        close(ch)
+        return sub
+    }
+    b.subs[sub] = struct{}{}
+    return sub
+}
+
+// 取消订阅，可重复调用
+func (b *Bus) Unsubscribe(sub *Subscription) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.remove(sub)
+}
+
+// 发布事件，不会阻塞：缓冲已满的订阅被断开，客户端重连后重新获取当前人数
+func (b *Bus) Publish(events ...Event) {
+    if b == nil || len(events) == 0 {
+        return
+    }
+
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    for sub := range b.subs {
+        for _, event := range events {
+            if !sub.match(event) {
+                continue
+            }
+            select {
+            case sub.ch <- event:
+            default:
+                b.remove(sub)
+            }
+            if _, ok := b.subs[sub]; !ok {
+                break
+            }
+        }
+    }
+}
+
+// 关闭总线并结束所有订阅，用于服务器关闭时让长连接尽快退出
+func (b *Bus) Close() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.closed = true
+    for sub := range b.subs {
+        b.remove(sub)
+    }
+}
+
+// 当前订阅数
+func (b *Bus) Subscribers() int {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    return len(b.subs)
+}
+
+func (b *Bus) remove(sub *Subscription) {
+    if _, ok := b.subs[sub]; ok {
+        delete(b.subs, sub)
+        close(sub.ch)
+    }
+}
+
+// 匹配学生个人事件以及 courseIDs 中课程的人数变化。studentID 为 0 时不接收个人事件
+func Filter(studentID int, courseIDs []int) func(Event) bool {
+    courseIDs = slices.Clone(courseIDs)
+    return func(e Event) bool {
+        if e.Type == SeatsChanged {
+            return slices.Contains(courseIDs, e.CourseID)
+        }
+        return studentID > 0 && e.StudentID == studentID
+    }
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 审计日志单次查询的默认条数和上限
+const (
+    defaultAuditLimit = 100
+    maxAuditLimit     = 1000
+)
+
+// ==================== 审计日志API ====================
+
+// 查询审计日志 (管理员功能)，可按学生、课程和时间范围 [from, to) 筛选
+func (h *APIHandler) ListAuditEvents(c *gin.Context) {
+    filter := models.AuditFilter{Limit: defaultAuditLimit}
+    
+    var ok bool
+    if filter.StudentID, ok = optionalIDQuery(c, "student_id"); !ok {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    if filter.CourseID, ok = optionalIDQuery(c, "course_id"); !ok {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    if filter.From, ok = optionalTimeQuery(c, "from"); !ok {
+        respondError(c, http.StatusBadRequest, "无效的开始时间，应为RFC3339格式")
+        return
+    }
+    if filter.To, ok = optionalTimeQuery(c, "to"); !ok {
+        respondError(c, http.StatusBadRequest, "无效的结束时间，应为RFC3339格式")
+        return
+    }
+    
+    if value := c.Query("limit"); value != "" {
+        limit, err := strconv.Atoi(value)
+        if err != nil || limit <= 0 || limit > maxAuditLimit {
+            respondError(c, http.StatusBadRequest, "limit 应在1到1000之间")
+            return
+        }
+        filter.Limit = limit
+    }
+    
+    events, total, err := h.DB.ListAuditEvents(c.Request.Context(), filter)
+    if err != nil {
+        respondInternalError(c, "查询审计日志失败", err)
+        return
+    }
+    
+    apiEvents := make([]types.AuditEvent, len(events))
+    for i, event := range events {
+        apiEvents[i] = types.AuditEvent{
+            ID:        event.ID,
+            Actor:     event.Actor,
+            Action:    event.Action,
+            StudentID: event.StudentID,
+            CourseID:  event.CourseID,
+            Before:    event.Before,
+            After:     event.After,
+            RequestID: event.RequestID,
+            CreatedAt: event.CreatedAt,
+        }
+    }
+    
+    c.JSON(http.StatusOK, types.AuditEventsResponse{
+        Events:     apiEvents,
+        TotalCount: total,
+    })
+}
+
+// ==================== 选课状态API ====================
+
+// 修改学生当前选课的状态 (管理员功能)，如标记为退出(withdrawn)或已完成(completed)
+func (h *APIHandler) UpdateEnrollmentStatus(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.UpdateEnrollmentStatusRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    err = h.DB.SetEnrollmentStatus(withActor(c, adminActor), studentID, courseID, req.Status)
+    switch {
+    case errors.Is(err, models.ErrInvalidStatus):
+        respondError(c, http.StatusBadRequest, "无效的选课状态")
+        return
+    case errors.Is(err, models.ErrNotEnrolled):
+        respondError(c, http.StatusNotFound, "该学生当前未选修此课程")
+        return
+    case err != nil:
+        respondInternalError(c, "修改选课状态失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "选课状态已更新",
+    })
+}
+
+// ==================== 路由设置 ====================
+
+// 管理员API，挂载在 /admin 下
+func (h *APIHandler) setupAdminRoutes(admin *gin.RouterGroup) {
+    admin.GET("/audit-events", h.ListAuditEvents) // 查询审计日志
+    
+    admin.PATCH("/students/:studentId/courses/:courseId", h.UpdateEnrollmentStatus) // 修改选课状态
+    admin.PUT("/students/:studentId/courses/:courseId/grade", h.RecordGrade)        // 录入/修改成绩
+    admin.POST("/courses/:courseId/enrollments", h.BulkEnroll)                      // 批量选课
+    admin.POST("/courses/:courseId/enrollments/move", h.MoveEnrollments)            // 批量转课/合并教学班
+    admin.POST("/enrollments/copy", h.CopyEnrollments)                              // 跨学期复制选课
+    
+    admin.POST("/programmes", h.AddProgramme) // 添加培养方案
+    
+    admin.POST("/courses/:courseId/sections", h.AddSection)                  // 添加教学班
+    admin.PUT("/courses/:courseId/prerequisites", h.SetCoursePrerequisites) // 设置先修课程
+    
+    admin.POST("/instructors", h.AddInstructor)                                           // 添加教师
+    admin.PUT("/courses/:courseId/instructors/:instructorId", h.AssignInstructor)         // 安排任课
+    admin.DELETE("/courses/:courseId/instructors/:instructorId", h.UnassignInstructor)    // 取消任课
+    admin.PUT("/instructors/:instructorId/unavailability", h.SetInstructorUnavailability) // 设置教师不可用时间
+    
+    admin.POST("/rooms", h.AddRoom)                         // 添加教室
+    admin.PATCH("/courses/:courseId", h.UpdateCourseSchedule) // 修改课程时间、教室和容量
+    
+    admin.POST("/timetable/preview", h.PreviewTimetable) // 预览自动排课方案
+    admin.POST("/timetable/apply", h.ApplyTimetable)     // 确认排课方案
+    
+    admin.POST("/registration-rounds", h.CreateRound)                     // 创建选课轮次
+    admin.POST("/registration-rounds/:roundId/allocate", h.AllocateRound) // 执行轮次分配
+    
+    admin.GET("/petitions", h.GetPetitions)                       // 选课申请列表
+    admin.POST("/petitions/:petitionId/review", h.ReviewPetition) // 审批选课申请
+}
+
+// 解析可选的ID查询参数，未提供时返回0
+func optionalIDQuery(c *gin.Context, name string) (int, bool) {
+    value := c.Query(name)
+    if value == "" {
+        return 0, true
+    }
+    id, err := strconv.Atoi(value)
+    if err != nil || id <= 0 {
+        return 0, false
+    }
+    return id, true
+}
+
+// 解析可选的RFC3339时间查询参数，未提供时返回零值
+func optionalTimeQuery(c *gin.Context, name string) (time.Time, bool) {
+    value := c.Query(name)
+    if value == "" {
+        return time.Time{}, true
+    }
+    t, err := time.Parse(time.RFC3339, value)
+    if err != nil {
+        return time.Time{}, false
+    }
+    return t, true
+}
@@ -1,11 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 
+	"course-management/logging"
+	"course-management/metrics"
 	"course-management/models"
+	"course-management/requestid"
 	"course-management/types"
 
 	"github.com/gin-gonic/gin"
@@ -14,22 +22,64 @@ import (
 // API处理器结构体
 type APIHandler struct {
     DB *models.Database
+    
+    // 请求之外执行的任务（如轮次分配），关闭服务器时由 Shutdown 等待或取消
+    background       sync.WaitGroup
+    backgroundCtx    context.Context
+    cancelBackground context.CancelFunc
 }
 
 // 创建新的API处理器
 func NewAPIHandler(db *models.Database) *APIHandler {
-    return &APIHandler{DB: db}
+    ctx, cancel := context.WithCancel(context.Background())
+    return &APIHandler{DB: db, backgroundCtx: ctx, cancelBackground: cancel}
+}
+
+// 在请求之外执行 fn。fn 的上下文保留请求中的日志、操作人等信息，但不受请求超时和客户端断开的影响，
+// 只在 Shutdown 等待超时时取消
+func (h *APIHandler) runInBackground(ctx context.Context, fn func(ctx context.Context)) {
+    ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+    stop := context.AfterFunc(h.backgroundCtx, cancel)
+    
+    h.background.Add(1)
+    go func() {
+        defer h.background.Done()
+        defer stop()
+        defer cancel()
+        fn(ctx)
+    }()
 }
 
+// 等待请求之外执行的任务完成，ctx 结束时取消仍在执行的任务并等待其退出。
+// 须在 HTTP 服务器关闭之后、数据库关闭之前调用
+func (h *APIHandler) Shutdown(ctx context.Context) {
+    done := make(chan struct{})
+    go func() {
+        h.background.Wait()
+        close(done)
+    }()
+    
+    select {
+    case <-done:
+    case <-ctx.Done():
+        h.cancelBackground()
+        <-done
+    }
+}
+
+// 课程选课学生分页的默认每页条数和上限
+const (
+    defaultRosterPageSize = 20
+    maxRosterPageSize     = 100
+)
+
 // ==================== 课程相关API ====================
 
 // 获取课程列表
 func (h *APIHandler) GetCourses(c *gin.Context) {
-    courses, err := h.DB.GetAllCourses()
+    courses, err := h.DB.GetAllCourses(c.Request.Context())
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "获取课程列表失败",
-        })
+        respondInternalError(c, "获取课程列表失败", err)
         return
     }
     
@@ -52,24 +102,18 @@ func (h *APIHandler) GetCourses(c *gin.Context) {
 func (h *APIHandler) GetCourseByID(c *gin.Context) {
     courseID, err := strconv.Atoi(c.Param("id"))
     if err != nil || courseID <= 0 {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "无效的课程ID",
-        })
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
         return
     }
     
-    course, err := h.DB.GetCourseByID(courseID)
+    course, err := h.DB.GetCourseByID(c.Request.Context(), courseID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "查询课程信息失败",
-        })
+        respondInternalError(c, "查询课程信息失败", err)
         return
     }
     
     if course == nil {
-        c.JSON(http.StatusNotFound, types.ErrorResponse{
-            Error: "课程不存在",
-        })
+        respondError(c, http.StatusNotFound, "课程不存在")
         return
     }
     
@@ -84,6 +128,15 @@ func (h *APIHandler) GetCourseByID(c *gin.Context) {
         Semester:          course.Semester,
         TimeSlot:          course.TimeSlot,
         CourseLocation:    course.CourseLocation,
+        Category:          course.Category,
+        RoomID:            course.RoomID,
+        Capacity:          course.Capacity,
+    }
+    
+    apiCourse.EnrolledCount, err = h.DB.GetEnrolledCount(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询选课人数失败", err)
+        return
     }
     
     c.JSON(http.StatusOK, types.CourseDetailResponse{
@@ -91,24 +144,117 @@ func (h *APIHandler) GetCourseByID(c *gin.Context) {
     })
 }
 
+// 获取课程的选课学生，分页返回。status 默认为 enrolled，为 waitlisted 时返回排队中的候补学生，
+// 为 all 时返回所有状态的选课记录及候补；sort 可为 name、email、enrolled_at、student_id、waitlist_position，
+// order 为 asc 或 desc
+func (h *APIHandler) GetCourseStudents(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    query := models.RosterQuery{Status: c.DefaultQuery("status", models.EnrollmentEnrolled)}
+    switch query.Status {
+    case "all":
+        query.Status = ""
+    case models.EnrollmentEnrolled, models.EnrollmentDropped, models.EnrollmentWithdrawn,
+        models.EnrollmentRemovedByAdmin, models.EnrollmentMoved, models.EnrollmentCompleted, models.RosterWaitlisted:
+    default:
+        respondError(c, http.StatusBadRequest, "无效的选课状态")
+        return
+    }
+    
+    query.Sort = c.DefaultQuery("sort", "enrolled_at")
+    if _, ok := models.RosterSortColumns[query.Sort]; !ok {
+        respondError(c, http.StatusBadRequest, "无效的排序字段")
+        return
+    }
+    switch c.DefaultQuery("order", "asc") {
+    case "asc":
+    case "desc":
+        query.Desc = true
+    default:
+        respondError(c, http.StatusBadRequest, "order 应为 asc 或 desc")
+        return
+    }
+    
+    page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+    if err != nil || page <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的页码")
+        return
+    }
+    pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultRosterPageSize)))
+    if err != nil || pageSize <= 0 || pageSize > maxRosterPageSize {
+        respondError(c, http.StatusBadRequest, "page_size 应在1到100之间")
+        return
+    }
+    query.Limit = pageSize
+    query.Offset = (page - 1) * pageSize
+    
+    course, err := h.DB.GetCourseByID(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询课程信息失败", err)
+        return
+    }
+    if course == nil {
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    }
+    
+    roster, total, err := h.DB.QueryCourseRoster(c.Request.Context(), courseID, query)
+    if err != nil {
+        respondInternalError(c, "查询课程学生失败", err)
+        return
+    }
+    enrolled, err := h.DB.GetEnrolledCount(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询选课人数失败", err)
+        return
+    }
+    waitlisted, err := h.DB.GetWaitlistCount(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询候补人数失败", err)
+        return
+    }
+    
+    response := types.CourseStudentsResponse{
+        Course: types.Course{
+            ID:         course.ID,
+            CourseCode: course.CourseCode,
+            CourseName: course.CourseName,
+        },
+        Students:      toAPIRoster(roster),
+        TotalCount:    total,
+        Page:          page,
+        PageSize:      pageSize,
+        EnrolledCount: enrolled,
+        WaitlistCount: waitlisted,
+        Capacity:      course.Capacity,
+    }
+    // 课程不限人数时不返回剩余名额
+    if course.Capacity != nil {
+        available := max(*course.Capacity-enrolled, 0)
+        response.AvailableSeats = &available
+    }
+    
+    c.JSON(http.StatusOK, response)
+}
+
 // 搜索课程
 func (h *APIHandler) SearchCourses(c *gin.Context) {
     keyword := c.Query("keyword")
     if keyword == "" {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "搜索关键词不能为空",
-        })
+        respondError(c, http.StatusBadRequest, "搜索关键词不能为空")
         return
     }
     
     // 去除多余空格
     keyword = strings.TrimSpace(keyword)
     
-    courses, err := h.DB.SearchCourses(keyword)
+    courses, err := h.DB.SearchCourses(c.Request.Context(), keyword)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "搜索课程失败",
-        })
+        respondInternalError(c, "搜索课程失败", err)
         return
     }
     
@@ -131,31 +277,33 @@ func (h *APIHandler) SearchCourses(c *gin.Context) {
 func (h *APIHandler) AddCourse(c *gin.Context) {
     var req types.AddCourseRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "请求参数格式错误",
-        })
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
         return
     }
     
     // 数据验证
     if req.CourseCode == "" || req.CourseName == "" {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "课程代码和课程名称不能为空",
-        })
+        respondError(c, http.StatusBadRequest, "课程代码和课程名称不能为空")
+        return
+    }
+    if req.RoomID < 0 || req.Capacity < 0 {
+        respondError(c, http.StatusBadRequest, "教室ID和课程容量不能为负数")
         return
     }
     
-    course, err := h.DB.AddCourse(
+    course, err := h.DB.AddCourse(withActor(c, adminActor), 
         req.CourseCode, req.CourseName, req.CourseDescription,
         req.Credits, req.Instructor, req.Semester, 
-        req.TimeSlot, req.CourseLocation,
+        req.TimeSlot, req.CourseLocation, req.Category,
+        req.RoomID, req.Capacity,
     )
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "添加课程失败",
-        })
+        if !respondScheduleError(c, err) {
+            respondInternalError(c, "添加课程失败", err)
+        }
         return
     }
+    metrics.CoursesCreatedTotal.Inc()
     
     // 返回添加的课程信息
     apiCourse := types.Course{
@@ -174,11 +322,9 @@ func (h *APIHandler) AddCourse(c *gin.Context) {
 
 // 获取学生列表
 func (h *APIHandler) GetStudents(c *gin.Context) {
-    students, err := h.DB.GetAllStudents()
+    students, err := h.DB.GetAllStudents(c.Request.Context())
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "获取学生列表失败",
-        })
+        respondInternalError(c, "获取学生列表失败", err)
         return
     }
     
@@ -201,25 +347,19 @@ func (h *APIHandler) GetStudents(c *gin.Context) {
 func (h *APIHandler) AddStudent(c *gin.Context) {
     var req types.AddStudentRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "请求参数格式错误",
-        })
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
         return
     }
     
     // 数据验证
     if req.Name == "" || req.Email == "" {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "姓名和邮箱不能为空",
-        })
+        respondError(c, http.StatusBadRequest, "姓名和邮箱不能为空")
         return
     }
     
-    student, err := h.DB.AddStudent(req.Email, req.Name)
+    student, err := h.DB.AddStudent(withActor(c, adminActor), req.Email, req.Name)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "添加学生失败",
-        })
+        respondInternalError(c, "添加学生失败", err)
         return
     }
     
@@ -237,38 +377,36 @@ func (h *APIHandler) AddStudent(c *gin.Context) {
 
 // ==================== 选课相关API ====================
 
-// 获取学生选课信息
+// 获取学生选课信息，history=true 时包含退课、完成等历史记录
 func (h *APIHandler) GetStudentCourses(c *gin.Context) {
     studentID, err := strconv.Atoi(c.Param("id"))
     if err != nil || studentID <= 0 {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "无效的学生ID",
-        })
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    includeHistory, err := strconv.ParseBool(c.DefaultQuery("history", "false"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, "history 参数应为 true 或 false")
         return
     }
     
     // 获取学生基本信息
-    student, err := h.DB.GetStudentByID(studentID)
+    student, err := h.DB.GetStudentByID(c.Request.Context(), studentID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "查询学生信息失败",
-        })
+        respondInternalError(c, "查询学生信息失败", err)
         return
     }
     
     if student == nil {
-        c.JSON(http.StatusNotFound, types.ErrorResponse{
-            Error: "学生不存在",
-        })
+        respondError(c, http.StatusNotFound, "学生不存在")
         return
     }
     
     // 获取学生选课信息
-    courses, err := h.DB.GetStudentCourses(studentID)
+    courses, err := h.DB.GetStudentCourses(c.Request.Context(), studentID, includeHistory)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "查询学生选课信息失败",
-        })
+        respondInternalError(c, "查询学生选课信息失败", err)
         return
     }
     
@@ -285,6 +423,10 @@ func (h *APIHandler) GetStudentCourses(c *gin.Context) {
             CourseID:   course.ID,
             CourseCode: course.CourseCode,
             CourseName: course.CourseName,
+            Status:     course.Status,
+            Sections:   course.Sections,
+            EnrolledAt: course.EnrolledAt,
+            EndedAt:    course.EndedAt,
         }
     }
     
@@ -299,98 +441,184 @@ func (h *APIHandler) GetStudentCourses(c *gin.Context) {
 func (h *APIHandler) EnrollStudentInCourse(c *gin.Context) {
     studentID, err := strconv.Atoi(c.Param("studentId"))
     if err != nil || studentID <= 0 {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "无效的学生ID",
-        })
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
         return
     }
     
     courseID, err := strconv.Atoi(c.Param("courseId"))
     if err != nil || courseID <= 0 {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "无效的课程ID",
-        })
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
         return
     }
     
-    err = h.DB.EnrollStudentInCourse(studentID, courseID)
+    // 请求体可选，课程设置了多个教学班时需指定 section_ids
+    var req types.EnrollRequest
+    if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    err = h.DB.EnrollStudentInCourse(withActor(c, studentActor(studentID)), studentID, courseID, req.SectionIDs)
     if err != nil {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: err.Error(),
-        })
+        reason := enrollmentFailureReason(err)
+        metrics.EnrollmentFailuresTotal.WithLabelValues(reason).Inc()
+        switch reason {
+        case "internal_error", "timeout", "canceled":
+            respondInternalError(c, "选课失败", err)
+            return
+        }
+        
+        logging.FromContext(c.Request.Context()).Warn("选课失败",
+            "student_id", studentID, "course_id", courseID, "reason", reason, "error", err)
+        respondError(c, http.StatusBadRequest, err.Error())
         return
     }
+    metrics.EnrollmentsTotal.Inc()
     
     c.JSON(http.StatusOK, types.SuccessResponse{
         Message: "选课成功",
     })
 }
 
+// 选课失败原因，用作监控指标标签
+func enrollmentFailureReason(err error) string {
+    switch {
+    case errors.Is(err, models.ErrStudentNotFound):
+        return "student_not_found"
+    case errors.Is(err, models.ErrCourseNotFound):
+        return "course_not_found"
+    case errors.Is(err, models.ErrAlreadyEnrolled):
+        return "already_enrolled"
+    case errors.Is(err, models.ErrInvalidSections):
+        return "invalid_sections"
+    case errors.Is(err, models.ErrSectionFull):
+        return "section_full"
+    case errors.Is(err, models.ErrCourseFull):
+        return "course_full"
+    case errors.Is(err, models.ErrPrerequisitesNotMet):
+        return "prerequisites_not_met"
+    case errors.Is(err, models.ErrCreditLimit):
+        return "credit_limit"
+    case errors.Is(err, models.ErrTimeClash):
+        return "time_clash"
+    case errors.Is(err, models.ErrRoundPending):
+        return "round_pending"
+    case errors.Is(err, models.ErrQueryTimeout):
+        return "timeout"
+    case errors.Is(err, models.ErrQueryCanceled):
+        return "canceled"
+    default:
+        return "internal_error"
+    }
+}
+
 // 学生退课
 func (h *APIHandler) UnenrollStudentFromCourse(c *gin.Context) {
     studentID, err := strconv.Atoi(c.Param("studentId"))
     if err != nil || studentID <= 0 {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "无效的学生ID",
-        })
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
         return
     }
     
     courseID, err := strconv.Atoi(c.Param("courseId"))
     if err != nil || courseID <= 0 {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "无效的课程ID",
-        })
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
         return
     }
     
-    err = h.DB.UnenrollStudentFromCourse(studentID, courseID)
+    err = h.DB.UnenrollStudentFromCourse(withActor(c, studentActor(studentID)), studentID, courseID)
     if err != nil {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: err.Error(),
-        })
+        if !errors.Is(err, models.ErrNotEnrolled) {
+            respondInternalError(c, "退课失败", err)
+            return
+        }
+        logging.FromContext(c.Request.Context()).Warn("退课失败",
+            "student_id", studentID, "course_id", courseID, "error", err)
+        respondError(c, http.StatusBadRequest, err.Error())
         return
     }
+    metrics.UnenrollmentsTotal.Inc()
     
     c.JSON(http.StatusOK, types.SuccessResponse{
         Message: "退课成功",
     })
 }
 
+// 学生换课：退选路径中的课程并选择 to_course_id，新课程选课失败时保留原课程
+func (h *APIHandler) SwapCourse(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.SwapCourseRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    err = h.DB.SwapCourse(withActor(c, studentActor(studentID)), studentID, courseID, req.ToCourseID, req.SectionIDs)
+    if err != nil {
+        reason := enrollmentFailureReason(err)
+        if errors.Is(err, models.ErrNotEnrolled) {
+            reason = "not_enrolled"
+        }
+        metrics.EnrollmentFailuresTotal.WithLabelValues(reason).Inc()
+        switch reason {
+        case "internal_error", "timeout", "canceled":
+            respondInternalError(c, "换课失败", err)
+            return
+        }
+        
+        logging.FromContext(c.Request.Context()).Warn("换课失败，保留原课程",
+            "student_id", studentID, "from_course_id", courseID, "to_course_id", req.ToCourseID,
+            "reason", reason, "error", err)
+        respondError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    metrics.UnenrollmentsTotal.Inc()
+    metrics.EnrollmentsTotal.Inc()
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "换课成功",
+    })
+}
+
 // 批量将学生从指定课程移除 (管理员功能 - 课程deprecated时使用)
 func (h *APIHandler) RemoveAllStudentsFromCourse(c *gin.Context) {
     courseID, err := strconv.Atoi(c.Param("courseId"))
     if err != nil || courseID <= 0 {
-        c.JSON(http.StatusBadRequest, types.ErrorResponse{
-            Error: "无效的课程ID",
-        })
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
         return
     }
     
     // 检查课程是否存在
-    exists, err := h.DB.CourseExists(courseID)
+    exists, err := h.DB.CourseExists(c.Request.Context(), courseID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "检查课程失败",
-        })
+        respondInternalError(c, "检查课程失败", err)
         return
     }
     
     if !exists {
-        c.JSON(http.StatusNotFound, types.ErrorResponse{
-            Error: "课程不存在",
-        })
+        respondError(c, http.StatusNotFound, "课程不存在")
         return
     }
     
     // 清空课程的所有选课记录
-    err = h.DB.ClearCourseEnrollments(courseID)
+    removed, err := h.DB.ClearCourseEnrollments(withActor(c, adminActor), courseID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "清空课程选课记录失败",
-        })
+        respondInternalError(c, "清空课程选课记录失败", err)
         return
     }
+    logging.FromContext(c.Request.Context()).Info("已将所有学生从课程中移除",
+        "course_id", courseID, "removed", removed)
     
     c.JSON(http.StatusOK, types.SuccessResponse{
         Message: "已成功将所有学生从该课程中移除",
@@ -415,15 +643,105 @@ func (h *APIHandler) SetupRoutes(r *gin.Engine) {
     
     r.POST("/students/:studentId/courses/:courseId", h.EnrollStudentInCourse)      // 学生选课
     r.DELETE("/students/:studentId/courses/:courseId", h.UnenrollStudentFromCourse) // 学生退课
-    
+    r.POST("/students/:studentId/courses/:courseId/swap", h.SwapCourse)             // 学生换课
+    r.GET("/students/:studentId/transcript", h.GetTranscript)                       // 学生成绩单
+    r.POST("/students/:studentId/planner", h.PlanSchedule)                          // 选课规划
+    
+    r.GET("/students/:studentId/cart", h.GetCart)                                   // 购物车
+    r.PUT("/students/:studentId/cart/:courseId", h.AddToCart)                       // 加入购物车
+    r.DELETE("/students/:studentId/cart/:courseId", h.RemoveFromCart)               // 移出购物车
+    r.POST("/students/:studentId/cart/validate", h.ValidateCart)                    // 校验购物车
+    r.POST("/students/:studentId/cart/checkout", h.CheckoutCart)                    // 结算购物车
+    
+    r.GET("/students/:studentId/waitlist", h.GetStudentWaitlist)                    // 学生的候补
+    r.POST("/students/:studentId/courses/:courseId/waitlist", h.JoinWaitlist)       // 加入候补
+    r.DELETE("/students/:studentId/courses/:courseId/waitlist", h.LeaveWaitlist)    // 退出候补
+    
+    r.GET("/registration-rounds", h.GetRounds)                                                    // 选课轮次列表
+    r.GET("/registration-rounds/:roundId", h.GetRoundByID)                                        // 选课轮次详情
+    r.GET("/registration-rounds/:roundId/results", h.GetRoundResults)                             // 轮次分配结果
+    r.GET("/students/:studentId/registration-rounds/:roundId/preferences", h.GetRoundPreferences) // 查看志愿
+    r.PUT("/students/:studentId/registration-rounds/:roundId/preferences", h.SetRoundPreferences) // 提交志愿
+    r.POST("/students/:studentId/petitions", h.SubmitPetition)                                    // 提交选课申请
+    r.GET("/students/:studentId/petitions", h.GetStudentPetitions)                                // 学生的选课申请
+    
+    r.GET("/programmes", h.GetProgrammes)                                          // 培养方案列表
+    r.GET("/programmes/:programmeId", h.GetProgrammeByID)                          // 培养方案详情
+    r.POST("/students/:studentId/programmes/:programmeId", h.DeclareProgramme)     // 修读培养方案
+    r.DELETE("/students/:studentId/programmes/:programmeId", h.UndeclareProgramme) // 退出培养方案
+    r.GET("/students/:studentId/degree-audit", h.GetDegreeAudit)                   // 毕业审核
+    
+    r.GET("/courses/:courseId/students", h.GetCourseStudents)              // 课程选课学生
     r.DELETE("/courses/:courseId/students", h.RemoveAllStudentsFromCourse) // 批量移除学生(课程deprecated)
+    r.GET("/courses/:courseId/sections", h.GetCourseSections)              // 课程教学班列表
+    r.GET("/courses/:courseId/prerequisites", h.GetCoursePrerequisites)    // 课程先修要求
+    
+    r.GET("/events/stream", h.StreamEvents) // 实时推送课程人数和个人选课事件
+    
+    r.GET("/rooms", h.GetRooms)                                                                                // 教室列表
+    r.GET("/rooms/:roomId", h.GetRoomByID)                                                                     // 教室详情
+    
+    r.GET("/instructors", h.GetInstructors)                                                                    // 教师列表
+    r.GET("/instructors/:instructorId", h.GetInstructorByID)                                                   // 教师详情
+    r.GET("/instructors/:instructorId/courses", h.GetInstructorCourses)                                        // 教师任教课程
+    r.GET("/instructors/:instructorId/courses/:courseId/roster", h.GetInstructorCourseRoster)                  // 课程学生名单
+    r.GET("/instructors/:instructorId/petitions", h.GetInstructorPetitions)                                    // 任教课程的选课申请
+    r.POST("/instructors/:instructorId/petitions/:petitionId/review", h.ReviewPetitionAsInstructor)            // 教师审批选课申请
+    r.PUT("/instructors/:instructorId/courses/:courseId/students/:studentId/grade", h.RecordGradeAsInstructor) // 教师录入/修改成绩
+    
+    h.setupAdminRoutes(r.Group("/admin"))
+}
+
+// 审计日志中的操作者。系统尚未接入认证，按接口区分学生本人、教师和管理员操作
+const adminActor = "admin"
+
+func studentActor(studentID int) string {
+    return "student:" + strconv.Itoa(studentID)
+}
+
+func instructorActor(instructorID int) string {
+    return "instructor:" + strconv.Itoa(instructorID)
+}
+
+// 返回携带操作者的请求上下文，供写操作记录审计日志
+func withActor(c *gin.Context, actor string) context.Context {
+    return models.WithActor(c.Request.Context(), actor)
 }
 
 // 错误处理中间件
 func (h *APIHandler) ErrorHandler() gin.HandlerFunc {
-    return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
-        c.JSON(http.StatusInternalServerError, types.ErrorResponse{
-            Error: "服务器内部错误",
-        })
+    return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered interface{}) {
+        logging.FromContext(c.Request.Context()).Error("请求处理发生panic",
+            "panic", recovered, "stack", string(debug.Stack()))
+        respondError(c, http.StatusInternalServerError, "服务器内部错误")
     })
+}
+
+// 返回错误响应，附带请求ID便于用户反馈问题时定位日志
+func respondError(c *gin.Context, status int, message string) {
+    c.JSON(status, types.ErrorResponse{
+        Error:     message,
+        RequestID: requestid.Get(c),
+    })
+}
+
+// 客户端在响应前断开连接时记录的状态码（沿用nginx的约定）
+const statusClientClosedRequest = 499
+
+// 记录内部错误并返回，响应中不暴露错误细节。
+// 数据库查询超时返回504，客户端断开导致的查询取消不再写响应体。
+func respondInternalError(c *gin.Context, message string, err error) {
+    logger := logging.FromContext(c.Request.Context())
+
+    switch {
+    case errors.Is(err, models.ErrQueryTimeout):
+        logger.Error(message, "error", err)
+        respondError(c, http.StatusGatewayTimeout, "数据库响应超时，请稍后重试")
+    case errors.Is(err, models.ErrQueryCanceled):
+        logger.Info("客户端已断开连接，查询已取消", "error", err)
+        c.AbortWithStatus(statusClientClosedRequest)
+    default:
+        logger.Error(message, "error", err)
+        respondError(c, http.StatusInternalServerError, message)
+    }
 }
\ No newline at end of file
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"course-management/logging"
+	"course-management/metrics"
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 批量选课API (管理员功能) ====================
+
+// 批量为学生选择课程。all_or_nothing 模式下任何一名学生失败则全部不选，返回 409
+func (h *APIHandler) BulkEnroll(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.BulkEnrollRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    result, err := h.DB.BulkEnroll(withActor(c, adminActor), courseID, req.StudentIDs, req.SectionIDs, bulkMode(req.Mode))
+    switch {
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    case err != nil:
+        respondInternalError(c, "批量选课失败", err)
+        return
+    }
+    
+    respondBulkResult(c, result, "批量选课")
+}
+
+// 将课程（或其中一个教学班）的所有在读学生转到另一课程或教学班，如教学班合并。转课失败的学生保留原课程
+func (h *APIHandler) MoveEnrollments(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.MoveEnrollmentsRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    result, err := h.DB.MoveEnrollments(withActor(c, adminActor), courseID, req.FromSectionID, req.ToCourseID,
+        req.ToSectionIDs, bulkMode(req.Mode))
+    switch {
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在: "+err.Error())
+        return
+    case errors.Is(err, models.ErrInvalidSections):
+        respondError(c, http.StatusBadRequest, "在同一课程内转课时须指定 from_section_id")
+        return
+    case err != nil:
+        respondInternalError(c, "批量转课失败", err)
+        return
+    }
+    
+    respondBulkResult(c, result, "批量转课")
+}
+
+// 将一个学期的选课复制到另一学期中课程代码相同的课程
+func (h *APIHandler) CopyEnrollments(c *gin.Context) {
+    var req types.CopyEnrollmentsRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    result, err := h.DB.CopyEnrollments(withActor(c, adminActor), strings.TrimSpace(req.FromSemester),
+        strings.TrimSpace(req.ToSemester), req.CourseCodes, bulkMode(req.Mode))
+    switch {
+    case errors.Is(err, models.ErrInvalidSemester):
+        respondError(c, http.StatusBadRequest, "源学期和目标学期不能相同")
+        return
+    case err != nil:
+        respondInternalError(c, "复制选课失败", err)
+        return
+    }
+    
+    respondBulkResult(c, result, "复制选课")
+}
+
+// 批量操作默认为 all_or_nothing
+func bulkMode(mode string) string {
+    if mode == "" {
+        return models.CheckoutAllOrNothing
+    }
+    return mode
+}
+
+// 返回批量操作的逐个学生结果，未生效时返回 409
+func respondBulkResult(c *gin.Context, result *models.BulkResult, operation string) {
+    succeeded, failed := 0, 0
+    items := make([]types.BulkItemResult, len(result.Items))
+    for i, item := range result.Items {
+        items[i] = types.BulkItemResult{
+            StudentID:  item.StudentID,
+            CourseID:   item.CourseID,
+            CourseCode: item.CourseCode,
+            Status:     item.Status,
+        }
+        switch item.Status {
+        case models.CartItemEnrolled:
+            succeeded++
+            metrics.EnrollmentsTotal.Inc()
+        case models.CartItemFailed:
+            failed++
+            reason := enrollmentFailureReason(item.Err)
+            items[i].Reason = reason
+            items[i].Error = item.Err.Error()
+            metrics.EnrollmentFailuresTotal.WithLabelValues(reason).Inc()
+        }
+    }
+    
+    logging.FromContext(c.Request.Context()).Info(operation+"完成",
+        "mode", result.Mode, "committed", result.Committed, "succeeded", succeeded, "failed", failed)
+    
+    response := types.BulkEnrollmentResponse{
+        Mode:      result.Mode,
+        Committed: result.Committed,
+        Items:     items,
+    }
+    if !result.Committed {
+        response.Message = "有 " + strconv.Itoa(failed) + " 名学生不满足选课条件，未做任何更改"
+        c.JSON(http.StatusConflict, response)
+        return
+    }
+    
+    response.Message = "已处理 " + strconv.Itoa(len(items)) + " 名学生，成功 " + strconv.Itoa(succeeded) +
+        " 名，失败 " + strconv.Itoa(failed) + " 名"
+    c.JSON(http.StatusOK, response)
+}
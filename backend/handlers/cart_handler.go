@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"course-management/metrics"
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 购物车API ====================
+
+// 获取学生的购物车
+func (h *APIHandler) GetCart(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    items, err := h.DB.GetCart(c.Request.Context(), studentID)
+    switch {
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    case err != nil:
+        respondInternalError(c, "查询购物车失败", err)
+        return
+    }
+    
+    apiItems := make([]types.CartItem, len(items))
+    totalCredits := 0
+    for i, item := range items {
+        apiItems[i] = types.CartItem{
+            CourseID:   item.Course.ID,
+            CourseCode: item.Course.CourseCode,
+            CourseName: item.Course.CourseName,
+            Credits:    item.Course.Credits,
+            Semester:   item.Course.Semester,
+            TimeSlot:   item.Course.TimeSlot,
+            SectionIDs: item.SectionIDs,
+            AddedAt:    item.AddedAt,
+        }
+        totalCredits += item.Course.Credits
+    }
+    
+    c.JSON(http.StatusOK, types.CartResponse{
+        StudentID:    studentID,
+        Items:        apiItems,
+        TotalCredits: totalCredits,
+    })
+}
+
+// 将课程加入购物车，已在购物车中时更新选择的教学班
+func (h *APIHandler) AddToCart(c *gin.Context) {
+    studentID, courseID, ok := studentCourseParams(c)
+    if !ok {
+        return
+    }
+    
+    // 请求体可选，课程设置了多个教学班时需指定 section_ids
+    var req types.CartItemRequest
+    if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    err := h.DB.AddToCart(c.Request.Context(), studentID, courseID, req.SectionIDs)
+    switch {
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    case err != nil:
+        respondInternalError(c, "加入购物车失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "已加入购物车",
+    })
+}
+
+// 从购物车移除课程
+func (h *APIHandler) RemoveFromCart(c *gin.Context) {
+    studentID, courseID, ok := studentCourseParams(c)
+    if !ok {
+        return
+    }
+    
+    err := h.DB.RemoveFromCart(c.Request.Context(), studentID, courseID)
+    switch {
+    case errors.Is(err, models.ErrNotInCart):
+        respondError(c, http.StatusNotFound, "购物车中没有该课程")
+        return
+    case err != nil:
+        respondInternalError(c, "移出购物车失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "已移出购物车",
+    })
+}
+
+// 校验购物车：按结算的规则逐门检查，不实际选课
+func (h *APIHandler) ValidateCart(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    result, err := h.DB.ValidateCart(withActor(c, studentActor(studentID)), studentID)
+    if respondCartError(c, err, "校验购物车失败") {
+        return
+    }
+    
+    message := "购物车中的课程均可选"
+    for _, item := range result.Items {
+        if item.Status == models.CartItemFailed {
+            message = "部分课程不满足选课条件"
+            break
+        }
+    }
+    
+    c.JSON(http.StatusOK, toAPICartResult(result, message))
+}
+
+// 结算购物车。all_or_nothing 模式下任何一门课程失败则全部不选，返回 409；best_effort 模式下选上能选的课程
+func (h *APIHandler) CheckoutCart(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    var req types.CheckoutRequest
+    if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误，mode 须为 all_or_nothing 或 best_effort")
+        return
+    }
+    if req.Mode == "" {
+        req.Mode = models.CheckoutAllOrNothing
+    }
+    
+    result, err := h.DB.CheckoutCart(withActor(c, studentActor(studentID)), studentID, req.Mode)
+    if respondCartError(c, err, "结算失败") {
+        return
+    }
+    
+    enrolled, failed := 0, 0
+    for _, item := range result.Items {
+        switch item.Status {
+        case models.CartItemEnrolled:
+            enrolled++
+            metrics.EnrollmentsTotal.Inc()
+        case models.CartItemFailed:
+            failed++
+            metrics.EnrollmentFailuresTotal.WithLabelValues(enrollmentFailureReason(item.Err)).Inc()
+        }
+    }
+    
+    if !result.Committed {
+        c.JSON(http.StatusConflict, toAPICartResult(result, "有课程不满足选课条件，未选任何课程"))
+        return
+    }
+    
+    message := "结算成功，已选 " + strconv.Itoa(enrolled) + " 门课程"
+    if failed > 0 {
+        message += "，" + strconv.Itoa(failed) + " 门课程未能选上，仍保留在购物车中"
+    }
+    c.JSON(http.StatusOK, toAPICartResult(result, message))
+}
+
+// 处理校验和结算的公共错误，已响应时返回 true
+func respondCartError(c *gin.Context, err error, msg string) bool {
+    switch {
+    case err == nil:
+        return false
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+    case errors.Is(err, models.ErrCartEmpty):
+        respondError(c, http.StatusBadRequest, "购物车为空")
+    default:
+        respondInternalError(c, msg, err)
+    }
+    return true
+}
+
+func toAPICartResult(result *models.CartResult, message string) types.CheckoutResponse {
+    items := make([]types.CartItemResult, len(result.Items))
+    for i, item := range result.Items {
+        items[i] = types.CartItemResult{
+            CourseID:   item.CourseID,
+            CourseCode: item.CourseCode,
+            Status:     item.Status,
+        }
+        if item.Err != nil {
+            items[i].Reason = enrollmentFailureReason(item.Err)
+            items[i].Error = item.Err.Error()
+        }
+    }
+    
+    return types.CheckoutResponse{
+        Mode:      result.Mode,
+        Committed: result.Committed,
+        Message:   message,
+        Items:     items,
+    }
+}
+
+// 解析路径中的学生ID和课程ID，无效时已响应并返回 false
+func studentCourseParams(c *gin.Context) (int, int, bool) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return 0, 0, false
+    }
+    
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return 0, 0, false
+    }
+    
+    return studentID, courseID, true
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"course-management/events"
+	"course-management/logging"
+	"course-management/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+    // 单个连接最多订阅的课程数
+    maxStreamCourses = 50
+    // 没有事件时发送注释行的间隔，避免代理因连接空闲而断开
+    streamHeartbeat = 15 * time.Second
+    // 连接断开后浏览器重连前等待的时间（毫秒）
+    streamRetryMillis = 3000
+)
+
+// ==================== 实时事件API ====================
+
+// 以 Server-Sent Events 推送选课变化：course_ids 中课程的在读人数变化（seats.changed），
+// 以及 student_id 学生本人的选课、退课和被管理员移除等事件。连接建立时先推送订阅课程的当前人数。
+// 事件只在事务提交后推送；客户端消费过慢或服务器关闭时连接会被断开，浏览器自动重连后重新获取当前人数
+func (h *APIHandler) StreamEvents(c *gin.Context) {
+    studentID, ok := optionalIDQuery(c, "student_id")
+    if !ok {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    courseIDs, ok := idListQuery(c, "course_ids")
+    if !ok {
+        respondError(c, http.StatusBadRequest, "course_ids 应为逗号分隔的课程ID")
+        return
+    }
+    if len(courseIDs) > maxStreamCourses {
+        respondError(c, http.StatusBadRequest, "最多订阅 "+strconv.Itoa(maxStreamCourses)+" 门课程")
+        return
+    }
+    if studentID == 0 && len(courseIDs) == 0 {
+        respondError(c, http.StatusBadRequest, "请指定 course_ids 或 student_id")
+        return
+    }
+    
+    // 先订阅再查询当前人数，避免错过两者之间的变化
+    bus := h.DB.Events()
+    sub := bus.Subscribe(events.Filter(studentID, courseIDs))
+    defer bus.Unsubscribe(sub)
+    
+    seats, err := h.DB.GetCourseSeats(c.Request.Context(), courseIDs)
+    if err != nil {
+        respondInternalError(c, "查询课程人数失败", err)
+        return
+    }
+    
+    // 长连接不受服务器写超时限制
+    logger := logging.FromContext(c.Request.Context())
+    if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+        logger.Warn("无法取消事件流的写超时", "error", err)
+    }
+    
+    metrics.EventStreamsActive.Inc()
+    defer metrics.EventStreamsActive.Dec()
+    
+    c.Header("Content-Type", "text/event-stream")
+    c.Header("Cache-Control", "no-cache")
+    c.Header("Connection", "keep-alive")
+    c.Header("X-Accel-Buffering", "no")
+    c.Status(http.StatusOK)
+    
+    io.WriteString(c.Writer, "retry: "+strconv.Itoa(streamRetryMillis)+"\n\n")
+    for _, s := range seats {
+        event := s.Event()
+        c.SSEvent(event.Type, event)
+    }
+    c.Writer.Flush()
+    
+    heartbeat := time.NewTicker(streamHeartbeat)
+    defer heartbeat.Stop()
+    
+    for {
+        select {
+        case <-c.Request.Context().Done():
+            return
+        case event, ok := <-sub.C:
+            if !ok {
+                logger.Debug("事件订阅已结束，关闭事件流", "student_id", studentID)
+                return
+            }
+            c.SSEvent(event.Type, event)
+            c.Writer.Flush()
+        case <-heartbeat.C:
+            if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+                return
+            }
+            c.Writer.Flush()
+        }
+    }
+}
+
+// 解析逗号分隔的ID列表查询参数，未提供时返回空列表。重复的ID只保留一个
+func idListQuery(c *gin.Context, name string) ([]int, bool) {
+    ids := []int{}
+    value := c.Query(name)
+    if value == "" {
+        return ids, true
+    }
+    seen := make(map[int]bool)
+    for _, part := range strings.Split(value, ",") {
+        id, err := strconv.Atoi(strings.TrimSpace(part))
+        if err != nil || id <= 0 {
+            return nil, false
+        }
+        if !seen[id] {
+            seen[id] = true
+            ids = append(ids, id)
+        }
+    }
+    return ids, true
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 成绩相关API ====================
+
+// 获取学生成绩单，包含各学期 GPA 和累计 GPA
+func (h *APIHandler) GetTranscript(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    student, err := h.DB.GetStudentByID(c.Request.Context(), studentID)
+    if err != nil {
+        respondInternalError(c, "查询学生信息失败", err)
+        return
+    }
+    if student == nil {
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    }
+    
+    transcript, err := h.DB.GetTranscript(c.Request.Context(), studentID)
+    if err != nil {
+        respondInternalError(c, "查询成绩单失败", err)
+        return
+    }
+    
+    semesters := make([]types.TranscriptSemester, len(transcript.Semesters))
+    for i, semester := range transcript.Semesters {
+        courses := make([]types.TranscriptCourse, len(semester.Courses))
+        for j, entry := range semester.Courses {
+            courses[j] = types.TranscriptCourse{
+                CourseID:    entry.CourseID,
+                CourseCode:  entry.CourseCode,
+                CourseName:  entry.CourseName,
+                Credits:     entry.Credits,
+                Status:      entry.Status,
+                Grade:       entry.Grade,
+                GradePoints: entry.GradePoints,
+                PassFail:    entry.PassFail,
+            }
+        }
+        semesters[i] = types.TranscriptSemester{
+            Semester:      semester.Semester,
+            Courses:       courses,
+            GPA:           semester.GPA,
+            GPACredits:    semester.GPACredits,
+            CreditsEarned: semester.CreditsEarned,
+        }
+    }
+    
+    c.JSON(http.StatusOK, types.TranscriptResponse{
+        Student: types.Student{
+            ID:    student.ID,
+            Name:  student.Username,
+            Email: student.Email,
+        },
+        Semesters:     semesters,
+        GPA:           transcript.GPA,
+        GPACredits:    transcript.GPACredits,
+        CreditsEarned: transcript.CreditsEarned,
+    })
+}
+
+// 录入或修改成绩 (管理员功能)，选课状态同时变为已完成
+func (h *APIHandler) RecordGrade(c *gin.Context) {
+    h.recordGrade(c, adminActor)
+}
+
+// 教师录入或修改任教课程的成绩，审计日志中记录该教师为操作者
+func (h *APIHandler) RecordGradeAsInstructor(c *gin.Context) {
+    instructor, ok := h.instructorParam(c)
+    if !ok {
+        return
+    }
+    
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    teaches, err := h.DB.TeachesCourse(c.Request.Context(), instructor.ID, courseID)
+    if err != nil {
+        respondInternalError(c, "检查任课安排失败", err)
+        return
+    }
+    if !teaches {
+        respondError(c, http.StatusForbidden, "该教师未任教此课程")
+        return
+    }
+    
+    h.recordGrade(c, instructorActor(instructor.ID))
+}
+
+func (h *APIHandler) recordGrade(c *gin.Context, actor string) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.RecordGradeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    enrollment, amended, err := h.DB.RecordGrade(withActor(c, actor), studentID, courseID, req.Grade, req.PassFail)
+    switch {
+    case errors.Is(err, models.ErrInvalidGrade):
+        respondError(c, http.StatusBadRequest, "无效的成绩，等级成绩应为 A+ 至 F，P/F 课程应为 P 或 F")
+        return
+    case errors.Is(err, models.ErrNotEnrolled):
+        respondError(c, http.StatusNotFound, "该学生没有可录入成绩的选课记录")
+        return
+    case err != nil:
+        respondInternalError(c, "录入成绩失败", err)
+        return
+    }
+    
+    message := "成绩已录入"
+    if amended {
+        message = "成绩已修改"
+    }
+    
+    c.JSON(http.StatusOK, types.RecordGradeResponse{
+        Grade:       *enrollment.Grade,
+        GradePoints: enrollment.GradePoints,
+        PassFail:    enrollment.PassFail,
+        Amended:     amended,
+        Message:     message,
+    })
+}
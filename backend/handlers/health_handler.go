@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 健康检查处理器，供负载均衡器和编排系统探测实例状态
+type HealthHandler struct {
+    DB           *models.Database
+    CheckTimeout time.Duration
+
+    ready atomic.Bool
+}
+
+// 创建健康检查处理器，实例初始为未就绪状态，启动完成后需调用 SetReady(true)
+func NewHealthHandler(db *models.Database, checkTimeout time.Duration) *HealthHandler {
+    return &HealthHandler{DB: db, CheckTimeout: checkTimeout}
+}
+
+// 设置实例是否接收流量（关闭服务前应先标记为未就绪）
+func (h *HealthHandler) SetReady(ready bool) {
+    h.ready.Store(ready)
+}
+
+// 存活检查：进程能够响应请求即可
+func (h *HealthHandler) Liveness(c *gin.Context) {
+    c.JSON(http.StatusOK, types.HealthResponse{
+        Status: "ok",
+    })
+}
+
+// 就绪检查：实例未处于关闭流程、数据库可连接且迁移已全部应用
+func (h *HealthHandler) Readiness(c *gin.Context) {
+    checks := make(map[string]string)
+    ready := true
+
+    if h.ready.Load() {
+        checks["server"] = "ok"
+    } else {
+        checks["server"] = "not ready"
+        ready = false
+    }
+
+    ctx, cancel := context.WithTimeout(c.Request.Context(), h.CheckTimeout)
+    defer cancel()
+
+    if err := h.DB.Ping(ctx); err != nil {
+        checks["database"] = "unreachable"
+        checks["migrations"] = "unknown"
+        ready = false
+    } else {
+        checks["database"] = "ok"
+
+        pending, err := h.DB.PendingMigrations(ctx)
+        switch {
+        case err != nil:
+            checks["migrations"] = "unknown"
+            ready = false
+        case pending > 0:
+            checks["migrations"] = "pending"
+            ready = false
+        default:
+            checks["migrations"] = "ok"
+        }
+    }
+
+    if !ready {
+        c.JSON(http.StatusServiceUnavailable, types.HealthResponse{
+            Status: "unavailable",
+            Checks: checks,
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, types.HealthResponse{
+        Status: "ok",
+        Checks: checks,
+    })
+}
+
+// 设置健康检查路由
+func (h *HealthHandler) SetupRoutes(r *gin.Engine) {
+    r.GET("/healthz", h.Liveness)
+    r.GET("/readyz", h.Readiness)
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 教师相关API ====================
+
+// 获取教师列表
+func (h *APIHandler) GetInstructors(c *gin.Context) {
+    instructors, err := h.DB.GetAllInstructors(c.Request.Context())
+    if err != nil {
+        respondInternalError(c, "获取教师列表失败", err)
+        return
+    }
+    
+    apiInstructors := make([]types.Instructor, len(instructors))
+    for i, instructor := range instructors {
+        apiInstructors[i] = toAPIInstructor(instructor)
+    }
+    
+    c.JSON(http.StatusOK, types.InstructorsResponse{
+        Instructors: apiInstructors,
+    })
+}
+
+// 获取教师详情
+func (h *APIHandler) GetInstructorByID(c *gin.Context) {
+    instructor, ok := h.instructorParam(c)
+    if !ok {
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.InstructorResponse{
+        Instructor: toAPIInstructor(*instructor),
+    })
+}
+
+// 获取教师任教的课程
+func (h *APIHandler) GetInstructorCourses(c *gin.Context) {
+    instructor, ok := h.instructorParam(c)
+    if !ok {
+        return
+    }
+    
+    courses, err := h.DB.GetInstructorCourses(c.Request.Context(), instructor.ID)
+    if err != nil {
+        respondInternalError(c, "查询教师课程失败", err)
+        return
+    }
+    
+    apiCourses := make([]types.Course, len(courses))
+    for i, course := range courses {
+        apiCourses[i] = types.Course{
+            ID:         course.ID,
+            CourseCode: course.CourseCode,
+            CourseName: course.CourseName,
+        }
+    }
+    
+    c.JSON(http.StatusOK, types.InstructorCoursesResponse{
+        Instructor: toAPIInstructor(*instructor),
+        Courses:    apiCourses,
+    })
+}
+
+// 获取教师所授课程的学生名单
+func (h *APIHandler) GetInstructorCourseRoster(c *gin.Context) {
+    instructor, ok := h.instructorParam(c)
+    if !ok {
+        return
+    }
+    
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    teaches, err := h.DB.TeachesCourse(c.Request.Context(), instructor.ID, courseID)
+    if err != nil {
+        respondInternalError(c, "检查任课安排失败", err)
+        return
+    }
+    if !teaches {
+        respondError(c, http.StatusNotFound, "该教师未任教此课程")
+        return
+    }
+    
+    course, err := h.DB.GetCourseByID(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询课程失败", err)
+        return
+    }
+    if course == nil {
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    }
+    
+    roster, err := h.DB.GetCourseRoster(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询课程名单失败", err)
+        return
+    }
+    
+    students := toAPIRoster(roster)
+    
+    c.JSON(http.StatusOK, types.RosterResponse{
+        Course: types.Course{
+            ID:         course.ID,
+            CourseCode: course.CourseCode,
+            CourseName: course.CourseName,
+        },
+        Students:   students,
+        TotalCount: len(students),
+    })
+}
+
+// 添加教师 (管理员功能)
+func (h *APIHandler) AddInstructor(c *gin.Context) {
+    var req types.AddInstructorRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    name := strings.TrimSpace(req.Name)
+    if name == "" {
+        respondError(c, http.StatusBadRequest, "教师姓名不能为空")
+        return
+    }
+    
+    instructor, err := h.DB.AddInstructor(withActor(c, adminActor), name, req.Email)
+    if err != nil {
+        if errors.Is(err, models.ErrDuplicateInstructor) {
+            respondError(c, http.StatusConflict, "教师姓名或邮箱已存在")
+            return
+        }
+        respondInternalError(c, "添加教师失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusCreated, types.InstructorResponse{
+        Instructor: toAPIInstructor(*instructor),
+    })
+}
+
+// 安排教师任教课程 (管理员功能)
+func (h *APIHandler) AssignInstructor(c *gin.Context) {
+    courseID, instructorID, ok := courseInstructorParams(c)
+    if !ok {
+        return
+    }
+    
+    err := h.DB.AssignInstructor(withActor(c, adminActor), courseID, instructorID)
+    switch {
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    case errors.Is(err, models.ErrInstructorNotFound):
+        respondError(c, http.StatusNotFound, "教师不存在")
+        return
+    case errors.Is(err, models.ErrAlreadyAssigned):
+        respondError(c, http.StatusConflict, "该教师已任教此课程")
+        return
+    case err != nil:
+        respondInternalError(c, "安排任课失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "任课安排成功",
+    })
+}
+
+// 取消教师的任课安排 (管理员功能)
+func (h *APIHandler) UnassignInstructor(c *gin.Context) {
+    courseID, instructorID, ok := courseInstructorParams(c)
+    if !ok {
+        return
+    }
+    
+    err := h.DB.UnassignInstructor(withActor(c, adminActor), courseID, instructorID)
+    switch {
+    case errors.Is(err, models.ErrNotAssigned):
+        respondError(c, http.StatusNotFound, "该教师未任教此课程")
+        return
+    case err != nil:
+        respondInternalError(c, "取消任课安排失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "已取消任课安排",
+    })
+}
+
+// 设置教师的不可用时间 (管理员功能)，排课时避开这些时间
+func (h *APIHandler) SetInstructorUnavailability(c *gin.Context) {
+    instructorID, err := strconv.Atoi(c.Param("instructorId"))
+    if err != nil || instructorID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的教师ID")
+        return
+    }
+    
+    var req types.InstructorUnavailabilityRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    err = h.DB.SetInstructorUnavailability(withActor(c, adminActor), instructorID, req.TimeSlots)
+    switch {
+    case errors.Is(err, models.ErrInvalidTimeSlot):
+        respondError(c, http.StatusBadRequest, "无法识别的时间: "+err.Error())
+        return
+    case errors.Is(err, models.ErrInstructorNotFound):
+        respondError(c, http.StatusNotFound, "教师不存在")
+        return
+    case err != nil:
+        respondInternalError(c, "设置教师不可用时间失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "教师不可用时间已更新",
+    })
+}
+
+// 解析并查询路径中的教师，失败时已写入错误响应
+func (h *APIHandler) instructorParam(c *gin.Context) (*models.Instructor, bool) {
+    instructorID, err := strconv.Atoi(c.Param("instructorId"))
+    if err != nil || instructorID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的教师ID")
+        return nil, false
+    }
+    
+    instructor, err := h.DB.GetInstructorByID(c.Request.Context(), instructorID)
+    if err != nil {
+        respondInternalError(c, "查询教师失败", err)
+        return nil, false
+    }
+    if instructor == nil {
+        respondError(c, http.StatusNotFound, "教师不存在")
+        return nil, false
+    }
+    
+    return instructor, true
+}
+
+func courseInstructorParams(c *gin.Context) (int, int, bool) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return 0, 0, false
+    }
+    
+    instructorID, err := strconv.Atoi(c.Param("instructorId"))
+    if err != nil || instructorID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的教师ID")
+        return 0, 0, false
+    }
+    
+    return courseID, instructorID, true
+}
+
+func toAPIInstructor(instructor models.Instructor) types.Instructor {
+    return types.Instructor{
+        ID:          instructor.ID,
+        Name:        instructor.Name,
+        Email:       instructor.Email,
+        CourseCount: instructor.CourseCount,
+        Unavailable: instructor.Unavailable,
+    }
+}
+
+func toAPIRoster(roster []models.RosterEntry) []types.RosterStudent {
+    students := make([]types.RosterStudent, len(roster))
+    for i, entry := range roster {
+        students[i] = types.RosterStudent{
+            ID:               entry.StudentID,
+            Name:             entry.Username,
+            Email:            entry.Email,
+            Status:           entry.Status,
+            Sections:         entry.Sections,
+            EnrolledAt:       entry.EnrolledAt,
+            EndedAt:          entry.EndedAt,
+            WaitlistPosition: entry.WaitlistPosition,
+        }
+    }
+    return students
+}
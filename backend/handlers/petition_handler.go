@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"course-management/logging"
+	"course-management/metrics"
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 选课申请API ====================
+
+// 学生提交选课申请：因课程已满、未修先修课程或选课轮次未分配无法选课时，说明理由请求例外处理
+func (h *APIHandler) SubmitPetition(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    var req types.SubmitPetitionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    reason := strings.TrimSpace(req.Reason)
+    if reason == "" {
+        respondError(c, http.StatusBadRequest, "请填写申请理由")
+        return
+    }
+    
+    petition, err := h.DB.SubmitPetition(withActor(c, studentActor(studentID)), studentID, req.CourseID, req.SectionIDs, reason)
+    switch {
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    case errors.Is(err, models.ErrDuplicatePetition):
+        respondError(c, http.StatusConflict, "该课程已有待审批的申请")
+        return
+    case errors.Is(err, models.ErrPetitionNotNeeded):
+        respondError(c, http.StatusBadRequest, "当前可以直接选课，无需申请")
+        return
+    case errors.Is(err, models.ErrNotPetitionable):
+        respondError(c, http.StatusBadRequest, err.Error())
+        return
+    case err != nil:
+        respondInternalError(c, "提交选课申请失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusCreated, types.PetitionResponse{
+        Petition: toAPIPetition(*petition),
+    })
+}
+
+// 获取学生提交的选课申请
+func (h *APIHandler) GetStudentPetitions(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    h.listPetitions(c, models.PetitionFilter{StudentID: studentID})
+}
+
+// 获取教师任教课程的选课申请，可按 status 筛选
+func (h *APIHandler) GetInstructorPetitions(c *gin.Context) {
+    instructor, ok := h.instructorParam(c)
+    if !ok {
+        return
+    }
+    
+    h.listPetitions(c, models.PetitionFilter{InstructorID: instructor.ID})
+}
+
+// 获取所有选课申请 (管理员功能)，可按 status 和 student_id 筛选
+func (h *APIHandler) GetPetitions(c *gin.Context) {
+    studentID, ok := optionalIDQuery(c, "student_id")
+    if !ok {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    h.listPetitions(c, models.PetitionFilter{StudentID: studentID})
+}
+
+// 教师审批任教课程的选课申请
+func (h *APIHandler) ReviewPetitionAsInstructor(c *gin.Context) {
+    instructorID, err := strconv.Atoi(c.Param("instructorId"))
+    if err != nil || instructorID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的教师ID")
+        return
+    }
+    
+    h.reviewPetition(c, instructorID, instructorActor(instructorID))
+}
+
+// 审批选课申请 (管理员功能)
+func (h *APIHandler) ReviewPetition(c *gin.Context) {
+    h.reviewPetition(c, 0, adminActor)
+}
+
+func (h *APIHandler) listPetitions(c *gin.Context, filter models.PetitionFilter) {
+    filter.Status = c.Query("status")
+    switch filter.Status {
+    case "", models.PetitionPending, models.PetitionApproved, models.PetitionRejected:
+    default:
+        respondError(c, http.StatusBadRequest, "无效的申请状态")
+        return
+    }
+    
+    petitions, err := h.DB.GetPetitions(c.Request.Context(), filter)
+    if err != nil {
+        respondInternalError(c, "获取选课申请失败", err)
+        return
+    }
+    
+    apiPetitions := make([]types.Petition, len(petitions))
+    for i, petition := range petitions {
+        apiPetitions[i] = toAPIPetition(petition)
+    }
+    
+    c.JSON(http.StatusOK, types.PetitionsResponse{
+        Petitions: apiPetitions,
+    })
+}
+
+// 审批申请。批准时豁免申请记录的规则并完成选课，其他规则不满足时选课失败，申请保持待审批
+func (h *APIHandler) reviewPetition(c *gin.Context, instructorID int, actor string) {
+    petitionID, err := strconv.Atoi(c.Param("petitionId"))
+    if err != nil || petitionID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的申请ID")
+        return
+    }
+    
+    var req types.ReviewPetitionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    approve := req.Decision == "approve"
+    
+    petition, err := h.DB.ReviewPetition(withActor(c, actor), petitionID, instructorID, approve, strings.TrimSpace(req.Note))
+    switch {
+    case errors.Is(err, models.ErrPetitionNotFound):
+        respondError(c, http.StatusNotFound, "选课申请不存在")
+        return
+    case errors.Is(err, models.ErrPetitionReviewed):
+        respondError(c, http.StatusConflict, "该申请已审批")
+        return
+    case errors.Is(err, models.ErrNotAssigned):
+        respondError(c, http.StatusForbidden, "该教师未任教此课程")
+        return
+    case err != nil:
+        reason := enrollmentFailureReason(err)
+        switch reason {
+        case "internal_error", "timeout", "canceled":
+            respondInternalError(c, "审批选课申请失败", err)
+            return
+        }
+        metrics.EnrollmentFailuresTotal.WithLabelValues(reason).Inc()
+        logging.FromContext(c.Request.Context()).Warn("批准选课申请失败",
+            "petition_id", petitionID, "reason", reason, "error", err)
+        respondError(c, http.StatusBadRequest, "无法完成选课: "+err.Error())
+        return
+    }
+    if approve {
+        metrics.EnrollmentsTotal.Inc()
+    }
+    
+    c.JSON(http.StatusOK, types.PetitionResponse{
+        Petition: toAPIPetition(*petition),
+    })
+}
+
+func toAPIPetition(petition models.Petition) types.Petition {
+    return types.Petition{
+        ID:         petition.ID,
+        StudentID:  petition.StudentID,
+        CourseID:   petition.CourseID,
+        CourseCode: petition.CourseCode,
+        SectionIDs: petition.SectionIDs,
+        Overrides:  petition.Overrides,
+        Reason:     petition.Reason,
+        Status:     petition.Status,
+        ReviewedBy: petition.ReviewedBy,
+        ReviewNote: petition.ReviewNote,
+        CreatedAt:  petition.CreatedAt,
+        ReviewedAt: petition.ReviewedAt,
+    }
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 选课规划返回的方案数
+const (
+    defaultPlanLimit = 10
+    maxPlanLimit     = 50
+)
+
+// ==================== 选课规划API ====================
+
+// 为学生列出所选课程无时间冲突的教学班组合，按偏好排序。只做规划，不选课
+func (h *APIHandler) PlanSchedule(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    var req types.PlanRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    limit := req.Limit
+    if limit == 0 {
+        limit = defaultPlanLimit
+    }
+    if limit < 0 || limit > maxPlanLimit {
+        respondError(c, http.StatusBadRequest, "limit 须在 1 到 "+strconv.Itoa(maxPlanLimit)+" 之间")
+        return
+    }
+    
+    prefs := models.PlanPreferences{
+        NoEarlyMornings: req.Preferences.NoEarlyMornings,
+        CompactDays:     req.Preferences.CompactDays,
+        FreeFridays:     req.Preferences.FreeFridays,
+    }
+    
+    result, err := h.DB.PlanSchedule(c.Request.Context(), studentID, req.CourseIDs, prefs, limit)
+    switch {
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在: "+err.Error())
+        return
+    case errors.Is(err, models.ErrMixedSemesters):
+        respondError(c, http.StatusBadRequest, "所选课程须属于同一学期: "+err.Error())
+        return
+    case err != nil:
+        respondInternalError(c, "生成选课方案失败", err)
+        return
+    }
+    
+    plans := make([]types.Plan, len(result.Plans))
+    for i, plan := range result.Plans {
+        courses := make([]types.PlannedCourse, len(plan.Courses))
+        for j, course := range plan.Courses {
+            sections := make([]types.Section, len(course.Sections))
+            for k, section := range course.Sections {
+                sections[k] = toAPISection(section)
+            }
+            courses[j] = types.PlannedCourse{
+                CourseID:   course.CourseID,
+                CourseCode: course.CourseCode,
+                CourseName: course.CourseName,
+                TimeSlot:   course.TimeSlot,
+                Sections:   sections,
+            }
+        }
+        plans[i] = types.Plan{
+            Penalty: plan.Penalty,
+            Stats: types.PlanStats{
+                EarlyStarts:   plan.Stats.EarlyStarts,
+                FridayClasses: plan.Stats.FridayClasses,
+                TeachingDays:  plan.Stats.TeachingDays,
+                IdleMinutes:   plan.Stats.IdleMinutes,
+            },
+            Courses: courses,
+        }
+    }
+    
+    c.JSON(http.StatusOK, types.PlanResponse{
+        Semester:  result.Semester,
+        Total:     result.Total,
+        Truncated: result.Truncated,
+        Plans:     plans,
+        Reasons:   result.Reasons,
+        Notes:     result.Notes,
+    })
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 先修课程API ====================
+
+// 获取课程的先修课程代码
+func (h *APIHandler) GetCoursePrerequisites(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    exists, err := h.DB.CourseExists(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "检查课程失败", err)
+        return
+    }
+    if !exists {
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    }
+    
+    codes, err := h.DB.GetCoursePrerequisites(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询先修课程失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.PrerequisitesResponse{
+        CourseID:    courseID,
+        CourseCodes: codes,
+    })
+}
+
+// 设置课程的先修课程 (管理员功能)，整体替换原有设置，提交空数组表示取消先修要求
+func (h *APIHandler) SetCoursePrerequisites(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.PrerequisitesRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    codes, err := h.DB.SetCoursePrerequisites(withActor(c, adminActor), courseID, req.CourseCodes)
+    switch {
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    case errors.Is(err, models.ErrInvalidPrerequisites):
+        respondError(c, http.StatusBadRequest, "先修课程无效: "+err.Error())
+        return
+    case err != nil:
+        respondInternalError(c, "设置先修课程失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.PrerequisitesResponse{
+        CourseID:    courseID,
+        CourseCodes: codes,
+    })
+}
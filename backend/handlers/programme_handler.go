@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 培养方案相关API ====================
+
+// 获取培养方案列表
+func (h *APIHandler) GetProgrammes(c *gin.Context) {
+    programmes, err := h.DB.GetAllProgrammes(c.Request.Context())
+    if err != nil {
+        respondInternalError(c, "获取培养方案列表失败", err)
+        return
+    }
+    
+    apiProgrammes := make([]types.Programme, len(programmes))
+    for i, programme := range programmes {
+        apiProgrammes[i] = toAPIProgramme(programme)
+    }
+    
+    c.JSON(http.StatusOK, types.ProgrammesResponse{
+        Programmes: apiProgrammes,
+    })
+}
+
+// 获取培养方案详情
+func (h *APIHandler) GetProgrammeByID(c *gin.Context) {
+    programmeID, err := strconv.Atoi(c.Param("programmeId"))
+    if err != nil || programmeID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的培养方案ID")
+        return
+    }
+    
+    programme, err := h.DB.GetProgrammeByID(c.Request.Context(), programmeID)
+    if err != nil {
+        respondInternalError(c, "查询培养方案失败", err)
+        return
+    }
+    if programme == nil {
+        respondError(c, http.StatusNotFound, "培养方案不存在")
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.ProgrammeResponse{
+        Programme: toAPIProgramme(*programme),
+    })
+}
+
+// 添加培养方案 (管理员功能)
+func (h *APIHandler) AddProgramme(c *gin.Context) {
+    var req types.AddProgrammeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    if req.Type != models.ProgrammeMajor && req.Type != models.ProgrammeMinor {
+        respondError(c, http.StatusBadRequest, "培养方案类型应为 major 或 minor")
+        return
+    }
+    
+    programme := models.Programme{
+        Code: strings.TrimSpace(req.Code),
+        Name: strings.TrimSpace(req.Name),
+        Type: req.Type,
+    }
+    for _, r := range req.Requirements {
+        switch r.RuleType {
+        case models.RuleCourses:
+            if len(r.CourseCodes) == 0 || r.MinCourses > len(r.CourseCodes) {
+                respondError(c, http.StatusBadRequest, "课程要求「"+r.Name+"」需要列出课程代码，且 min_courses 不能超过课程数")
+                return
+            }
+        case models.RuleCredits:
+            if r.MinCredits <= 0 {
+                respondError(c, http.StatusBadRequest, "学分要求「"+r.Name+"」需要设置 min_credits")
+                return
+            }
+        default:
+            respondError(c, http.StatusBadRequest, "毕业要求的规则类型应为 courses 或 credits")
+            return
+        }
+        
+        programme.Requirements = append(programme.Requirements, models.Requirement{
+            Name:        r.Name,
+            RuleType:    r.RuleType,
+            CourseCodes: r.CourseCodes,
+            Category:    r.Category,
+            MinCourses:  r.MinCourses,
+            MinCredits:  r.MinCredits,
+        })
+    }
+    
+    created, err := h.DB.AddProgramme(withActor(c, adminActor), programme)
+    if err != nil {
+        if errors.Is(err, models.ErrDuplicateProgramme) {
+            respondError(c, http.StatusConflict, "培养方案代码已存在")
+            return
+        }
+        respondInternalError(c, "添加培养方案失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusCreated, types.ProgrammeResponse{
+        Programme: toAPIProgramme(*created),
+    })
+}
+
+// 学生修读培养方案
+func (h *APIHandler) DeclareProgramme(c *gin.Context) {
+    studentID, programmeID, ok := studentProgrammeParams(c)
+    if !ok {
+        return
+    }
+    
+    err := h.DB.DeclareProgramme(withActor(c, studentActor(studentID)), studentID, programmeID)
+    switch {
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    case errors.Is(err, models.ErrProgrammeNotFound):
+        respondError(c, http.StatusNotFound, "培养方案不存在")
+        return
+    case errors.Is(err, models.ErrAlreadyDeclared):
+        respondError(c, http.StatusConflict, "学生已修读该培养方案")
+        return
+    case err != nil:
+        respondInternalError(c, "修读培养方案失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "已修读培养方案",
+    })
+}
+
+// 学生退出培养方案
+func (h *APIHandler) UndeclareProgramme(c *gin.Context) {
+    studentID, programmeID, ok := studentProgrammeParams(c)
+    if !ok {
+        return
+    }
+    
+    err := h.DB.UndeclareProgramme(withActor(c, studentActor(studentID)), studentID, programmeID)
+    switch {
+    case errors.Is(err, models.ErrNotDeclared):
+        respondError(c, http.StatusNotFound, "学生未修读该培养方案")
+        return
+    case err != nil:
+        respondInternalError(c, "退出培养方案失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "已退出培养方案",
+    })
+}
+
+// 毕业审核：根据学生的选课和成绩，列出每个培养方案中已满足、进行中和未满足的要求
+func (h *APIHandler) GetDegreeAudit(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    student, err := h.DB.GetStudentByID(c.Request.Context(), studentID)
+    if err != nil {
+        respondInternalError(c, "查询学生信息失败", err)
+        return
+    }
+    if student == nil {
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    }
+    
+    audits, err := h.DB.GetDegreeAudit(c.Request.Context(), studentID)
+    if err != nil {
+        respondInternalError(c, "毕业审核失败", err)
+        return
+    }
+    
+    apiAudits := make([]types.DegreeAudit, len(audits))
+    for i, audit := range audits {
+        requirements := make([]types.RequirementProgress, len(audit.Requirements))
+        for j, progress := range audit.Requirements {
+            requirements[j] = types.RequirementProgress{
+                Requirement:       toAPIRequirement(progress.Requirement),
+                Status:            progress.Status,
+                CompletedCourses:  progress.CompletedCourses,
+                InProgressCourses: progress.InProgressCourses,
+                MissingCourses:    progress.MissingCourses,
+                CreditsCompleted:  progress.CreditsCompleted,
+                CreditsInProgress: progress.CreditsInProgress,
+            }
+        }
+        apiAudits[i] = types.DegreeAudit{
+            Programme:    toAPIProgramme(audit.Programme),
+            DeclaredAt:   audit.DeclaredAt,
+            Status:       audit.Status,
+            Requirements: requirements,
+        }
+    }
+    
+    c.JSON(http.StatusOK, types.DegreeAuditResponse{
+        Student: types.Student{
+            ID:    student.ID,
+            Name:  student.Username,
+            Email: student.Email,
+        },
+        Audits: apiAudits,
+    })
+}
+
+// 解析学生ID和培养方案ID路径参数，无效时已写入错误响应
+func studentProgrammeParams(c *gin.Context) (int, int, bool) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return 0, 0, false
+    }
+    
+    programmeID, err := strconv.Atoi(c.Param("programmeId"))
+    if err != nil || programmeID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的培养方案ID")
+        return 0, 0, false
+    }
+    
+    return studentID, programmeID, true
+}
+
+func toAPIProgramme(programme models.Programme) types.Programme {
+    requirements := make([]types.Requirement, len(programme.Requirements))
+    for i, requirement := range programme.Requirements {
+        requirements[i] = toAPIRequirement(requirement)
+    }
+    return types.Programme{
+        ID:           programme.ID,
+        Code:         programme.Code,
+        Name:         programme.Name,
+        Type:         programme.Type,
+        Requirements: requirements,
+    }
+}
+
+func toAPIRequirement(requirement models.Requirement) types.Requirement {
+    courseCodes := requirement.CourseCodes
+    if courseCodes == nil {
+        courseCodes = []string{}
+    }
+    return types.Requirement{
+        ID:          requirement.ID,
+        Name:        requirement.Name,
+        RuleType:    requirement.RuleType,
+        CourseCodes: courseCodes,
+        Category:    requirement.Category,
+        MinCourses:  requirement.MinCourses,
+        MinCredits:  requirement.MinCredits,
+    }
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 教室相关API ====================
+
+// 获取教室列表，可按最小容量和设施筛选
+func (h *APIHandler) GetRooms(c *gin.Context) {
+    minCapacity, ok := optionalIDQuery(c, "min_capacity")
+    if !ok {
+        respondError(c, http.StatusBadRequest, "无效的最小容量")
+        return
+    }
+    
+    rooms, err := h.DB.GetRooms(c.Request.Context(), models.RoomFilter{
+        MinCapacity: minCapacity,
+        Feature:     strings.TrimSpace(c.Query("feature")),
+    })
+    if err != nil {
+        respondInternalError(c, "获取教室列表失败", err)
+        return
+    }
+    
+    apiRooms := make([]types.Room, len(rooms))
+    for i, room := range rooms {
+        apiRooms[i] = toAPIRoom(room)
+    }
+    
+    c.JSON(http.StatusOK, types.RoomsResponse{
+        Rooms: apiRooms,
+    })
+}
+
+// 获取教室详情
+func (h *APIHandler) GetRoomByID(c *gin.Context) {
+    roomID, err := strconv.Atoi(c.Param("roomId"))
+    if err != nil || roomID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的教室ID")
+        return
+    }
+    
+    room, err := h.DB.GetRoomByID(c.Request.Context(), roomID)
+    if err != nil {
+        respondInternalError(c, "查询教室失败", err)
+        return
+    }
+    if room == nil {
+        respondError(c, http.StatusNotFound, "教室不存在")
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.RoomResponse{
+        Room: toAPIRoom(*room),
+    })
+}
+
+// 添加教室 (管理员功能)
+func (h *APIHandler) AddRoom(c *gin.Context) {
+    var req types.AddRoomRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    room := models.Room{
+        Building: strings.TrimSpace(req.Building),
+        Room:     strings.TrimSpace(req.Room),
+        Capacity: req.Capacity,
+        Features: req.Features,
+    }
+    if room.Building == "" || room.Room == "" {
+        respondError(c, http.StatusBadRequest, "楼宇和教室号不能为空")
+        return
+    }
+    if room.Capacity <= 0 {
+        respondError(c, http.StatusBadRequest, "教室容量必须大于0")
+        return
+    }
+    
+    created, err := h.DB.AddRoom(withActor(c, adminActor), room)
+    if err != nil {
+        if errors.Is(err, models.ErrDuplicateRoom) {
+            respondError(c, http.StatusConflict, "教室已存在")
+            return
+        }
+        respondInternalError(c, "添加教室失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusCreated, types.RoomResponse{
+        Room: toAPIRoom(*created),
+    })
+}
+
+// 修改课程的上课时间、教室和容量 (管理员功能)
+func (h *APIHandler) UpdateCourseSchedule(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.UpdateCourseScheduleRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    if req.RoomID != nil && *req.RoomID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的教室ID")
+        return
+    }
+    if req.Capacity != nil && *req.Capacity <= 0 {
+        respondError(c, http.StatusBadRequest, "课程容量必须大于0")
+        return
+    }
+    
+    course, err := h.DB.UpdateCourseSchedule(withActor(c, adminActor), courseID, models.CourseSchedule{
+        TimeSlot: req.TimeSlot,
+        RoomID:   req.RoomID,
+        Capacity: req.Capacity,
+    })
+    if err != nil {
+        switch {
+        case errors.Is(err, models.ErrCourseNotFound):
+            respondError(c, http.StatusNotFound, "课程不存在")
+        case respondScheduleError(c, err):
+        default:
+            respondInternalError(c, "修改课程安排失败", err)
+        }
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.CourseDetailResponse{
+        Course: types.CourseDetail{
+            ID:                course.ID,
+            CourseCode:        course.CourseCode,
+            CourseName:        course.CourseName,
+            CourseDescription: course.CourseDescription,
+            Credits:           course.Credits,
+            Instructor:        course.Instructor,
+            Semester:          course.Semester,
+            TimeSlot:          course.TimeSlot,
+            CourseLocation:    course.CourseLocation,
+            Category:          course.Category,
+            RoomID:            course.RoomID,
+            Capacity:          course.Capacity,
+        },
+    })
+}
+
+// 将教室容量、时间冲突等排课错误写入响应，不是排课错误时返回 false
+func respondScheduleError(c *gin.Context, err error) bool {
+    switch {
+    case errors.Is(err, models.ErrRoomNotFound):
+        respondError(c, http.StatusBadRequest, "教室不存在")
+    case errors.Is(err, models.ErrInvalidTimeSlot):
+        respondError(c, http.StatusBadRequest, "无法识别的上课时间，应为 \"Mon 9:00-12:00\" 或 \"周一3-4节\" 格式")
+    case errors.Is(err, models.ErrRoomTooSmall):
+        respondError(c, http.StatusBadRequest, "教室容量不足: "+err.Error())
+    case errors.Is(err, models.ErrRoomDoubleBooked):
+        respondError(c, http.StatusConflict, "教室在该时间已被占用: "+err.Error())
+    default:
+        return false
+    }
+    return true
+}
+
+func toAPIRoom(room models.Room) types.Room {
+    return types.Room{
+        ID:       room.ID,
+        Building: room.Building,
+        Room:     room.Room,
+        Name:     room.Label(),
+        Capacity: room.Capacity,
+        Features: room.Features,
+    }
+}
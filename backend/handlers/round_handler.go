@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"course-management/logging"
+	"course-management/metrics"
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 选课轮次API ====================
+
+// 获取选课轮次列表
+func (h *APIHandler) GetRounds(c *gin.Context) {
+    rounds, err := h.DB.GetRounds(c.Request.Context())
+    if err != nil {
+        respondInternalError(c, "获取选课轮次失败", err)
+        return
+    }
+    
+    apiRounds := make([]types.RegistrationRound, len(rounds))
+    for i, round := range rounds {
+        apiRounds[i] = toAPIRound(round)
+    }
+    
+    c.JSON(http.StatusOK, types.RegistrationRoundsResponse{
+        Rounds: apiRounds,
+    })
+}
+
+// 获取选课轮次详情
+func (h *APIHandler) GetRoundByID(c *gin.Context) {
+    round, ok := h.roundParam(c)
+    if !ok {
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.RegistrationRoundResponse{
+        Round: toAPIRound(*round),
+    })
+}
+
+// 获取轮次的分配结果，可按 student_id 筛选。轮次分配前结果为空
+func (h *APIHandler) GetRoundResults(c *gin.Context) {
+    studentID, ok := optionalIDQuery(c, "student_id")
+    if !ok {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    round, ok := h.roundParam(c)
+    if !ok {
+        return
+    }
+    
+    results, err := h.DB.GetRoundResults(c.Request.Context(), round.ID, studentID)
+    if err != nil {
+        respondInternalError(c, "获取分配结果失败", err)
+        return
+    }
+    
+    apiResults := make([]types.RoundResult, len(results))
+    for i, r := range results {
+        apiResults[i] = types.RoundResult{
+            StudentID:     r.StudentID,
+            CourseID:      r.CourseID,
+            CourseCode:    r.CourseCode,
+            Rank:          r.Rank,
+            Priority:      r.Priority,
+            EarnedCredits: r.EarnedCredits,
+            Ticket:        r.Ticket,
+            Outcome:       r.Outcome,
+            Reason:        r.Reason,
+        }
+    }
+    
+    c.JSON(http.StatusOK, types.RoundResultsResponse{
+        Round:   toAPIRound(*round),
+        Results: apiResults,
+    })
+}
+
+// 获取学生在轮次中提交的志愿
+func (h *APIHandler) GetRoundPreferences(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    round, ok := h.roundParam(c)
+    if !ok {
+        return
+    }
+    
+    preferences, err := h.DB.GetRoundPreferences(c.Request.Context(), round.ID, studentID)
+    if err != nil {
+        respondInternalError(c, "获取志愿失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, toAPIRoundPreferences(round.ID, studentID, preferences))
+}
+
+// 提交志愿，整体替换之前提交的志愿。只能在轮次开放期间提交
+func (h *APIHandler) SetRoundPreferences(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    roundID, err := strconv.Atoi(c.Param("roundId"))
+    if err != nil || roundID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的轮次ID")
+        return
+    }
+    
+    var req types.RoundPreferencesRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    preferences := make([]models.RoundPreference, len(req.Preferences))
+    for i, p := range req.Preferences {
+        preferences[i] = models.RoundPreference{CourseID: p.CourseID, SectionIDs: p.SectionIDs}
+    }
+    
+    saved, err := h.DB.SetRoundPreferences(c.Request.Context(), roundID, studentID, preferences)
+    switch {
+    case errors.Is(err, models.ErrRoundNotFound):
+        respondError(c, http.StatusNotFound, "选课轮次不存在")
+        return
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在: "+err.Error())
+        return
+    case errors.Is(err, models.ErrRegistrationClosed):
+        respondError(c, http.StatusConflict, "当前不在志愿提交时间内")
+        return
+    case errors.Is(err, models.ErrInvalidPreferences):
+        respondError(c, http.StatusBadRequest, err.Error())
+        return
+    case err != nil:
+        respondInternalError(c, "提交志愿失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, toAPIRoundPreferences(roundID, studentID, saved))
+}
+
+// 创建选课轮次 (管理员功能)。轮次分配前，该学期的课程不能直接选课
+func (h *APIHandler) CreateRound(c *gin.Context) {
+    var req types.CreateRoundRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    if !req.ClosesAt.After(req.OpensAt) {
+        respondError(c, http.StatusBadRequest, "closes_at 须晚于 opens_at")
+        return
+    }
+    if req.Seed < 0 {
+        respondError(c, http.StatusBadRequest, "seed 不能为负数")
+        return
+    }
+    
+    round, err := h.DB.CreateRound(withActor(c, adminActor), models.RegistrationRound{
+        Name:     strings.TrimSpace(req.Name),
+        Semester: strings.TrimSpace(req.Semester),
+        OpensAt:  req.OpensAt,
+        ClosesAt: req.ClosesAt,
+        Seed:     req.Seed,
+    })
+    switch {
+    case errors.Is(err, models.ErrDuplicateRound):
+        respondError(c, http.StatusConflict, "该学期已有未分配的选课轮次")
+        return
+    case err != nil:
+        respondInternalError(c, "创建选课轮次失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusCreated, types.RegistrationRoundResponse{
+        Round: toAPIRound(*round),
+    })
+}
+
+// 执行轮次分配 (管理员功能)，须在志愿提交截止后执行。分配在后台分批进行，立即返回分配中的轮次，
+// 通过查询轮次查看进度；分配中断时可再次调用，从未处理的志愿继续
+func (h *APIHandler) AllocateRound(c *gin.Context) {
+    roundID, err := strconv.Atoi(c.Param("roundId"))
+    if err != nil || roundID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的轮次ID")
+        return
+    }
+    
+    ctx := withActor(c, adminActor)
+    round, err := h.DB.StartRoundAllocation(ctx, roundID)
+    switch {
+    case errors.Is(err, models.ErrRoundNotFound):
+        respondError(c, http.StatusNotFound, "选课轮次不存在")
+        return
+    case errors.Is(err, models.ErrRoundNotClosed):
+        respondError(c, http.StatusConflict, "志愿提交尚未截止")
+        return
+    case errors.Is(err, models.ErrRoundAllocated):
+        respondError(c, http.StatusConflict, "该轮次已完成分配")
+        return
+    case err != nil:
+        respondInternalError(c, "执行分配失败", err)
+        return
+    }
+    
+    h.runInBackground(ctx, func(ctx context.Context) {
+        h.allocateRound(ctx, roundID)
+    })
+    
+    c.JSON(http.StatusAccepted, types.RegistrationRoundResponse{
+        Round: toAPIRound(*round),
+    })
+}
+
+// 继续服务器上次退出时仍处于分配中的轮次，在启动时调用
+func (h *APIHandler) ResumeRoundAllocations(ctx context.Context) error {
+    roundIDs, err := h.DB.GetAllocatingRoundIDs(ctx)
+    if err != nil {
+        return err
+    }
+    
+    for _, roundID := range roundIDs {
+        logging.FromContext(ctx).Info("继续未完成的选课轮次分配", "round_id", roundID)
+        h.runInBackground(ctx, func(ctx context.Context) {
+            h.allocateRound(ctx, roundID)
+        })
+    }
+    return nil
+}
+
+// 在后台完成轮次分配并记录结果。服务器在分配中途退出时，已提交的批次保留，下次启动或重新执行分配时继续
+func (h *APIHandler) allocateRound(ctx context.Context, roundID int) {
+    logger := logging.FromContext(ctx)
+    summary, err := h.DB.AllocateRound(ctx, roundID)
+    if errors.Is(err, models.ErrRoundAllocated) {
+        logger.Info("选课轮次已由另一次执行完成分配", "round_id", roundID)
+        return
+    }
+    if err != nil {
+        logger.Error("选课轮次分配中断，可重新执行分配继续", "round_id", roundID, "error", err)
+        return
+    }
+    
+    metrics.EnrollmentsTotal.Add(float64(summary.NewlyEnrolled))
+    logger.Info("选课轮次分配完成",
+        "round_id", roundID, "seed", summary.Seed, "requests", summary.Requests,
+        "enrolled", summary.Enrolled, "failed", summary.Failed)
+}
+
+// 解析路径中的轮次ID并查询轮次，失败时已写入响应
+func (h *APIHandler) roundParam(c *gin.Context) (*models.RegistrationRound, bool) {
+    roundID, err := strconv.Atoi(c.Param("roundId"))
+    if err != nil || roundID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的轮次ID")
+        return nil, false
+    }
+    
+    round, err := h.DB.GetRoundByID(c.Request.Context(), roundID)
+    if err != nil {
+        respondInternalError(c, "查询选课轮次失败", err)
+        return nil, false
+    }
+    if round == nil {
+        respondError(c, http.StatusNotFound, "选课轮次不存在")
+        return nil, false
+    }
+    return round, true
+}
+
+// 分配前不公开 seed，避免学生据此预测抽签结果
+func toAPIRound(round models.RegistrationRound) types.RegistrationRound {
+    apiRound := types.RegistrationRound{
+        ID:              round.ID,
+        Name:            round.Name,
+        Semester:        round.Semester,
+        OpensAt:         round.OpensAt,
+        ClosesAt:        round.ClosesAt,
+        Status:          round.Status,
+        AllocationError: round.AllocationError,
+        AllocatedAt:     round.AllocatedAt,
+        CreatedAt:       round.CreatedAt,
+    }
+    if round.Status == models.RoundAllocated {
+        seed := round.Seed
+        apiRound.Seed = &seed
+    }
+    return apiRound
+}
+
+func toAPIRoundPreferences(roundID, studentID int, preferences []models.RoundPreference) types.RoundPreferencesResponse {
+    apiPreferences := make([]types.RoundPreference, len(preferences))
+    for i, p := range preferences {
+        apiPreferences[i] = types.RoundPreference{
+            Rank:       p.Rank,
+            CourseID:   p.CourseID,
+            CourseCode: p.CourseCode,
+            CourseName: p.CourseName,
+            SectionIDs: p.SectionIDs,
+        }
+    }
+    return types.RoundPreferencesResponse{
+        RoundID:     roundID,
+        StudentID:   studentID,
+        Preferences: apiPreferences,
+    }
+}
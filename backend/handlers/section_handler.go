@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 教学班相关API ====================
+
+// 获取课程的教学班列表及各教学班的选课人数
+func (h *APIHandler) GetCourseSections(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    exists, err := h.DB.CourseExists(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "检查课程失败", err)
+        return
+    }
+    if !exists {
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    }
+    
+    sections, err := h.DB.GetCourseSections(c.Request.Context(), courseID)
+    if err != nil {
+        respondInternalError(c, "查询教学班失败", err)
+        return
+    }
+    
+    apiSections := make([]types.Section, len(sections))
+    for i, section := range sections {
+        apiSections[i] = toAPISection(section)
+    }
+    
+    c.JSON(http.StatusOK, types.SectionsResponse{
+        CourseID: courseID,
+        Sections: apiSections,
+    })
+}
+
+// 为课程添加教学班 (管理员功能)
+func (h *APIHandler) AddSection(c *gin.Context) {
+    courseID, err := strconv.Atoi(c.Param("courseId"))
+    if err != nil || courseID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的课程ID")
+        return
+    }
+    
+    var req types.AddSectionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    switch req.SectionType {
+    case models.SectionLecture, models.SectionTutorial, models.SectionLab:
+    default:
+        respondError(c, http.StatusBadRequest, "教学班类型应为 lecture、tutorial 或 lab")
+        return
+    }
+    if req.Capacity != nil && *req.Capacity <= 0 {
+        respondError(c, http.StatusBadRequest, "教学班容量必须大于0")
+        return
+    }
+    
+    section, err := h.DB.AddSection(withActor(c, adminActor), models.Section{
+        CourseID:       courseID,
+        SectionCode:    strings.TrimSpace(req.SectionCode),
+        SectionType:    req.SectionType,
+        Instructor:     req.Instructor,
+        TimeSlot:       req.TimeSlot,
+        CourseLocation: req.CourseLocation,
+        Capacity:       req.Capacity,
+    })
+    switch {
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    case errors.Is(err, models.ErrDuplicateSection):
+        respondError(c, http.StatusConflict, "该课程已有相同代码的教学班")
+        return
+    case err != nil:
+        respondInternalError(c, "添加教学班失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusCreated, types.AddSectionResponse{
+        Section: toAPISection(*section),
+        Message: "教学班添加成功",
+    })
+}
+
+func toAPISection(section models.Section) types.Section {
+    return types.Section{
+        ID:             section.ID,
+        SectionCode:    section.SectionCode,
+        SectionType:    section.SectionType,
+        Instructor:     section.Instructor,
+        TimeSlot:       section.TimeSlot,
+        CourseLocation: section.CourseLocation,
+        RoomID:         section.RoomID,
+        Capacity:       section.Capacity,
+        EnrolledCount:  section.EnrolledCount,
+    }
+}
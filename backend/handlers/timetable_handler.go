@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 自动排课API (管理员功能) ====================
+
+// 预览学期的排课方案，不修改数据
+func (h *APIHandler) PreviewTimetable(c *gin.Context) {
+    var req types.TimetableRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    proposal, err := h.DB.GenerateTimetable(c.Request.Context(), strings.TrimSpace(req.Semester))
+    if err != nil {
+        respondInternalError(c, "生成排课方案失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, toAPITimetable(proposal))
+}
+
+// 确认排课方案并写入课程和教学班。方案须与预览时一致
+func (h *APIHandler) ApplyTimetable(c *gin.Context) {
+    var req types.TimetableRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    if req.Fingerprint == "" {
+        respondError(c, http.StatusBadRequest, "请先预览排课方案，并提交预览返回的 fingerprint")
+        return
+    }
+    
+    proposal, err := h.DB.ApplyTimetable(withActor(c, adminActor), strings.TrimSpace(req.Semester), req.Fingerprint)
+    switch {
+    case errors.Is(err, models.ErrTimetableInfeasible):
+        respondError(c, http.StatusConflict, "无法生成无冲突的课表: "+strings.Join(proposal.Reasons, "; "))
+        return
+    case errors.Is(err, models.ErrTimetableChanged):
+        respondError(c, http.StatusConflict, "课程或约束已变化，排课方案与预览不一致，请重新预览")
+        return
+    case err != nil:
+        respondInternalError(c, "应用排课方案失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, toAPITimetable(proposal))
+}
+
+func toAPITimetable(proposal *models.TimetableProposal) types.TimetableResponse {
+    entries := make([]types.TimetableEntry, len(proposal.Entries))
+    for i, entry := range proposal.Entries {
+        entries[i] = types.TimetableEntry{
+            CourseID:         entry.CourseID,
+            SectionID:        entry.SectionID,
+            Label:            entry.Label,
+            TimeSlot:         entry.TimeSlot,
+            RoomID:           entry.RoomID,
+            Location:         entry.Location,
+            PreviousTimeSlot: entry.PreviousTimeSlot,
+            PreviousLocation: entry.PreviousLocation,
+            Changed:          entry.Changed(),
+        }
+    }
+    
+    return types.TimetableResponse{
+        Semester:    proposal.Semester,
+        Feasible:    proposal.Feasible,
+        Fingerprint: proposal.Fingerprint,
+        Entries:     entries,
+        Reasons:     proposal.Reasons,
+    }
+}
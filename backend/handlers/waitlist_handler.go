@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"course-management/models"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 候补API ====================
+
+// 获取学生的候补，history=true 时包含已递补、已退出等历史记录
+func (h *APIHandler) GetStudentWaitlist(c *gin.Context) {
+    studentID, err := strconv.Atoi(c.Param("studentId"))
+    if err != nil || studentID <= 0 {
+        respondError(c, http.StatusBadRequest, "无效的学生ID")
+        return
+    }
+    
+    includeHistory, err := strconv.ParseBool(c.DefaultQuery("history", "false"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, "history 参数应为 true 或 false")
+        return
+    }
+    
+    student, err := h.DB.GetStudentByID(c.Request.Context(), studentID)
+    if err != nil {
+        respondInternalError(c, "查询学生信息失败", err)
+        return
+    }
+    if student == nil {
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    }
+    
+    entries, err := h.DB.GetStudentWaitlist(c.Request.Context(), studentID, includeHistory)
+    if err != nil {
+        respondInternalError(c, "查询候补失败", err)
+        return
+    }
+    
+    apiEntries := make([]types.WaitlistEntry, len(entries))
+    for i, entry := range entries {
+        apiEntries[i] = toAPIWaitlistEntry(entry)
+    }
+    
+    c.JSON(http.StatusOK, types.WaitlistResponse{
+        Entries: apiEntries,
+    })
+}
+
+// 学生加入课程候补：只有因课程或所选教学班已满无法选课时可以加入，有名额时按加入顺序自动选课
+func (h *APIHandler) JoinWaitlist(c *gin.Context) {
+    studentID, courseID, ok := studentCourseParams(c)
+    if !ok {
+        return
+    }
+    
+    // 请求体可选，课程设置了多个教学班时需指定 section_ids
+    var req types.EnrollRequest
+    if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+        respondError(c, http.StatusBadRequest, "请求参数格式错误")
+        return
+    }
+    
+    entry, err := h.DB.JoinWaitlist(withActor(c, studentActor(studentID)), studentID, courseID, req.SectionIDs)
+    switch {
+    case errors.Is(err, models.ErrStudentNotFound):
+        respondError(c, http.StatusNotFound, "学生不存在")
+        return
+    case errors.Is(err, models.ErrCourseNotFound):
+        respondError(c, http.StatusNotFound, "课程不存在")
+        return
+    case errors.Is(err, models.ErrAlreadyWaitlisted):
+        respondError(c, http.StatusConflict, "已在该课程的候补中")
+        return
+    case errors.Is(err, models.ErrCourseNotFull):
+        respondError(c, http.StatusBadRequest, "课程尚有名额，可以直接选课")
+        return
+    case err != nil:
+        switch enrollmentFailureReason(err) {
+        case "internal_error", "timeout", "canceled":
+            respondInternalError(c, "加入候补失败", err)
+            return
+        }
+        respondError(c, http.StatusBadRequest, err.Error())
+        return
+    }
+    
+    c.JSON(http.StatusCreated, types.WaitlistEntryResponse{
+        Entry: toAPIWaitlistEntry(*entry),
+    })
+}
+
+// 学生退出课程候补
+func (h *APIHandler) LeaveWaitlist(c *gin.Context) {
+    studentID, courseID, ok := studentCourseParams(c)
+    if !ok {
+        return
+    }
+    
+    err := h.DB.LeaveWaitlist(withActor(c, studentActor(studentID)), studentID, courseID)
+    switch {
+    case errors.Is(err, models.ErrNotWaitlisted):
+        respondError(c, http.StatusNotFound, "不在该课程的候补中")
+        return
+    case err != nil:
+        respondInternalError(c, "退出候补失败", err)
+        return
+    }
+    
+    c.JSON(http.StatusOK, types.SuccessResponse{
+        Message: "已退出候补",
+    })
+}
+
+func toAPIWaitlistEntry(entry models.WaitlistEntry) types.WaitlistEntry {
+    return types.WaitlistEntry{
+        ID:         entry.ID,
+        StudentID:  entry.StudentID,
+        CourseID:   entry.CourseID,
+        CourseCode: entry.CourseCode,
+        SectionIDs: entry.SectionIDs,
+        Status:     entry.Status,
+        Reason:     entry.Reason,
+        Position:   entry.Position,
+        CreatedAt:  entry.CreatedAt,
+        EndedAt:    entry.EndedAt,
+    }
+}
@@ -0,0 +1,172 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"course-management/logging"
+	"course-management/models"
+	"course-management/requestid"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+    // 客户端传入的幂等键请求头
+    Header = "Idempotency-Key"
+    // 标记响应是否为重放结果的响应头
+    ReplayedHeader = "Idempotent-Replayed"
+
+    maxKeyLength = 255
+    // 客户端断开连接导致请求未完成（与 handlers 中的约定一致）
+    statusClientClosedRequest = 499
+    // 清理过期幂等键的最小间隔
+    purgeInterval = 10 * time.Minute
+)
+
+// 响应记录器：在写出响应的同时保留一份副本
+type responseRecorder struct {
+    gin.ResponseWriter
+    body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+    w.body.Write(data)
+    return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+    w.body.WriteString(s)
+    return w.ResponseWriter.WriteString(s)
+}
+
+// 幂等中间件：对携带 Idempotency-Key 的写请求（POST/PUT/PATCH/DELETE），
+// 保存第一次请求的响应，在 ttl 内以相同的键重试时直接重放，不会重复执行操作。
+//   - 同一个键用于不同的请求体返回 422
+//   - 第一次请求仍在处理中时返回 409
+//   - 第一次请求返回 5xx 时释放该键，允许重试重新执行
+func Middleware(db *models.Database, ttl time.Duration) gin.HandlerFunc {
+    var lastPurge atomic.Int64
+
+    return func(c *gin.Context) {
+        key := c.GetHeader(Header)
+        if key == "" || !isWriteMethod(c.Request.Method) {
+            c.Next()
+            return
+        }
+
+        ctx := c.Request.Context()
+        logger := logging.FromContext(ctx)
+
+        if len(key) > maxKeyLength {
+            abort(c, http.StatusBadRequest, "Idempotency-Key 长度不能超过255个字符")
+            return
+        }
+
+        body, err := io.ReadAll(c.Request.Body)
+        if err != nil {
+            abort(c, http.StatusBadRequest, "读取请求体失败")
+            return
+        }
+        c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+        method, path := c.Request.Method, c.Request.URL.Path
+        hash := requestHash(method, c.Request.URL.RequestURI(), body)
+
+        record, claimed, err := db.ClaimIdempotencyKey(ctx, key, method, path, hash, ttl)
+        if err != nil {
+            logger.Error("幂等键处理失败", "error", err)
+            abort(c, http.StatusServiceUnavailable, "暂时无法处理请求，请稍后重试")
+            return
+        }
+
+        if !claimed {
+            switch {
+            case record.RequestHash != hash:
+                abort(c, http.StatusUnprocessableEntity, "该 Idempotency-Key 已用于不同的请求")
+            case record.StatusCode == 0:
+                abort(c, http.StatusConflict, "相同的请求正在处理中，请稍后重试")
+            default:
+                logger.Info("重放幂等请求的响应", "idempotency_key", key, "status", record.StatusCode)
+                c.Header(ReplayedHeader, "true")
+                c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+                c.Abort()
+            }
+            return
+        }
+
+        // 客户端断开连接时仍需保存结果，因此不使用请求上下文的取消信号
+        saveCtx := context.WithoutCancel(ctx)
+        release := func() {
+            if err := db.ReleaseIdempotencyKey(saveCtx, key, method, path); err != nil {
+                logger.Error("释放幂等键失败", "idempotency_key", key, "error", err)
+            }
+        }
+
+        // 处理过程中发生panic时释放键，再交给外层的恢复中间件处理
+        defer func() {
+            if recovered := recover(); recovered != nil {
+                release()
+                panic(recovered)
+            }
+        }()
+
+        recorder := &responseRecorder{ResponseWriter: c.Writer}
+        c.Writer = recorder
+
+        c.Next()
+
+        status := recorder.Status()
+        if status >= http.StatusInternalServerError || status == statusClientClosedRequest {
+            release()
+            return
+        }
+
+        contentType := recorder.Header().Get("Content-Type")
+        if err := db.CompleteIdempotencyKey(saveCtx, key, method, path, status, contentType, recorder.body.Bytes()); err != nil {
+            logger.Error("保存幂等响应失败", "idempotency_key", key, "error", err)
+        }
+
+        // 定期清理过期的幂等键
+        now := time.Now().Unix()
+        last := lastPurge.Load()
+        if now-last > int64(purgeInterval.Seconds()) && lastPurge.CompareAndSwap(last, now) {
+            if _, err := db.PurgeExpiredIdempotencyKeys(saveCtx); err != nil {
+                logger.Error("清理过期幂等键失败", "error", err)
+            }
+        }
+    }
+}
+
+func isWriteMethod(method string) bool {
+    switch method {
+    case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+        return true
+    }
+    return false
+}
+
+// 请求指纹：方法、带查询参数的URI和请求体
+func requestHash(method, uri string, body []byte) string {
+    h := sha256.New()
+    h.Write([]byte(method))
+    h.Write([]byte{0})
+    h.Write([]byte(uri))
+    h.Write([]byte{0})
+    h.Write(body)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+func abort(c *gin.Context, status int, message string) {
+    c.AbortWithStatusJSON(status, types.ErrorResponse{
+        Error:     message,
+        RequestID: requestid.Get(c),
+    })
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type contextKey struct{}
+
+// 根据日志级别和格式创建日志记录器，format 为 "json" 时输出JSON，否则输出 key=value 文本
+func New(w io.Writer, level, format string) *slog.Logger {
+    opts := &slog.HandlerOptions{Level: ParseLevel(level)}
+
+    var handler slog.Handler
+    if strings.EqualFold(format, "json") {
+        handler = slog.NewJSONHandler(w, opts)
+    } else {
+        handler = slog.NewTextHandler(w, opts)
+    }
+
+    return slog.New(handler)
+}
+
+// 解析日志级别，无法识别时使用 info
+func ParseLevel(level string) slog.Level {
+    switch strings.ToLower(strings.TrimSpace(level)) {
+    case "debug":
+        return slog.LevelDebug
+    case "warn", "warning":
+        return slog.LevelWarn
+    case "error":
+        return slog.LevelError
+    default:
+        return slog.LevelInfo
+    }
+}
+
+// 将日志记录器存入上下文
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+    return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// 从上下文中取出请求级日志记录器，不存在时返回全局默认记录器
+func FromContext(ctx context.Context) *slog.Logger {
+    if ctx != nil {
+        if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+            return logger
+        }
+    }
+    return slog.Default()
+}
+
+// 请求日志中间件：为每个请求创建带有请求字段的日志记录器，并在请求结束时输出访问日志。
+// quietPaths 中的路径（如健康检查）仅以 debug 级别记录，避免刷屏。
+func Middleware(logger *slog.Logger, quietPaths ...string) gin.HandlerFunc {
+    quiet := make(map[string]bool, len(quietPaths))
+    for _, path := range quietPaths {
+        quiet[path] = true
+    }
+
+    return func(c *gin.Context) {
+        start := time.Now()
+
+        reqLogger := logger.With(
+            "method", c.Request.Method,
+            "path", c.Request.URL.Path,
+            "client_ip", c.ClientIP(),
+        )
+        c.Request = c.Request.WithContext(WithContext(c.Request.Context(), reqLogger))
+
+        c.Next()
+
+        status := c.Writer.Status()
+        level := slog.LevelInfo
+        switch {
+        case quiet[c.Request.URL.Path]:
+            level = slog.LevelDebug
+        case status >= 500:
+            level = slog.LevelError
+        case status >= 400:
+            level = slog.LevelWarn
+        }
+
+        attrs := []any{
+            "route", c.FullPath(),
+            "status", status,
+            "latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+            "bytes", c.Writer.Size(),
+            "user_agent", c.Request.UserAgent(),
+        }
+        if len(c.Errors) > 0 {
+            attrs = append(attrs, "errors", c.Errors.String())
+        }
+
+        // 使用请求上下文中的记录器，以便包含后续中间件追加的字段
+        FromContext(c.Request.Context()).Log(c.Request.Context(), level, "request completed", attrs...)
+    }
+}
@@ -1,12 +1,25 @@
 package main
 
 import (
+    "context"
+    "errors"
     "fmt"
-    "log"
+    "log/slog"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
     
     "course-management/config"
     "course-management/handlers"
+    "course-management/idempotency"
+    "course-management/logging"
+    "course-management/metrics"
     "course-management/models"
+    "course-management/ratelimit"
+    "course-management/requestid"
+    "course-management/tracing"
     
     "github.com/gin-contrib/cors"
     "github.com/gin-gonic/gin"
@@ -16,57 +29,106 @@ func main() {
     // 加载配置
     cfg, err := config.LoadConfig()
     if err != nil {
-        log.Fatal("配置加载失败:", err)
+        fatal("配置加载失败", err)
     }
 
+    // 初始化日志，同时接管标准库 log 的输出
+    logger := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+    slog.SetDefault(logger)
+
     gin.SetMode(cfg.App.GinMode)
     
+    // 初始化链路追踪（需在连接数据库之前，以便SQL语句产生span）
+    shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+    if err != nil {
+        fatal("链路追踪初始化失败", err)
+    }
+    
     // 连接数据库
     db, err := models.NewDatabase(cfg.Database)
     if err != nil {
-        log.Fatal("数据库连接失败:", err)
+        fatal("数据库连接失败", err)
+    }
+    
+    // 执行数据库迁移
+    if cfg.Database.AutoMigrate {
+        if err := db.Migrate(); err != nil {
+            fatal("数据库迁移失败", err)
+        }
     }
-    defer db.Close()
     
     // 初始化示例数据
     if cfg.Security.SampleDataEnabled {
-        if err := db.InitializeSampleData(); err != nil {
-            log.Printf("示例数据初始化失败: %v", err)
+        if err := db.InitializeSampleData(context.Background()); err != nil {
+            slog.Error("示例数据初始化失败", "error", err)
         }
     }
     
     // 创建路由器
-    r := gin.Default()
+    r := gin.New()
     r.SetTrustedProxies([]string{})
     
+    apiHandler := handlers.NewAPIHandler(db)
+    
+    // 继续上次退出时未完成的轮次分配
+    if err := apiHandler.ResumeRoundAllocations(context.Background()); err != nil {
+        slog.Error("继续选课轮次分配失败", "error", err)
+    }
+    
+    r.Use(logging.Middleware(logger, "/healthz", "/readyz", cfg.Metrics.Path))
+    r.Use(requestid.Middleware())
+    if cfg.Tracing.Enabled {
+        r.Use(tracing.Middleware(cfg.Tracing.ServiceName, "/healthz", "/readyz", cfg.Metrics.Path)...)
+    }
+    r.Use(apiHandler.ErrorHandler())
+    
     // 配置CORS
     corsMiddleware := cors.Config{
         AllowOrigins:     cfg.CORS.AllowedOrigins,
-        AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+        AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
         AllowHeaders:     []string{
             "Origin", 
             "Content-Type", 
             "Accept", 
             "Authorization",
             "X-Requested-With",
+            requestid.Header,
+            idempotency.Header,
         },
-        ExposeHeaders:    []string{"Content-Length"},
+        ExposeHeaders:    []string{"Content-Length", "Retry-After", requestid.Header, idempotency.ReplayedHeader},
         AllowCredentials: cfg.CORS.AllowCredentials,
         MaxAge:           cfg.CORS.MaxAge,
     }
     
     r.Use(cors.New(corsMiddleware))
     
+    // 监控指标
+    if cfg.Metrics.Enabled {
+        registry := metrics.NewRegistry(db.DB, db.GetDataStats)
+        r.Use(metrics.Middleware())
+        r.GET(cfg.Metrics.Path, metrics.Handler(registry))
+    }
+    
+    // 限流
+    if cfg.RateLimit.Enabled {
+        r.Use(rateLimiter(cfg.RateLimit, "/healthz", "/readyz", cfg.Metrics.Path))
+    }
+    
     // 安全中间件
     if cfg.Security.HeadersEnabled {
         r.Use(securityHeaders(cfg.App.Environment))
     }
     
-    r.Use(requestLogger(cfg.Log))
+    // 写请求幂等
+    if cfg.Idempotency.Enabled {
+        r.Use(idempotency.Middleware(db, cfg.Idempotency.TTL))
+    }
     
-    // 创建API处理器并设置路由
-    apiHandler := handlers.NewAPIHandler(db)
-    r.Use(apiHandler.ErrorHandler())
+    // 健康检查端点
+    healthHandler := handlers.NewHealthHandler(db, cfg.Health.CheckTimeout)
+    healthHandler.SetupRoutes(r)
+    
+    // 设置API路由
     apiHandler.SetupRoutes(r)
     
     // 添加调试端点
@@ -75,14 +137,71 @@ func main() {
     }
     
     // 启动服务器
-    serverAddr := fmt.Sprintf(":%d", cfg.Server.Port)
-    log.Printf("🚀 服务器启动: http://localhost:%d", cfg.Server.Port)
-    log.Printf("📝 环境: %s", cfg.App.Environment)
-    log.Printf("🌐 允许的CORS源: %v", cfg.CORS.AllowedOrigins)
+    srv := &http.Server{
+        Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
+        Handler:           r,
+        ReadTimeout:       cfg.Server.ReadTimeout,
+        ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+        WriteTimeout:      cfg.Server.WriteTimeout,
+        IdleTimeout:       cfg.Server.IdleTimeout,
+        MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+    }
+    
+    slog.Info("服务器启动",
+        "addr", srv.Addr,
+        "environment", cfg.App.Environment,
+        "cors_origins", cfg.CORS.AllowedOrigins,
+        "log_level", cfg.Log.Level,
+    )
+    
+    serverErr := make(chan error, 1)
+    go func() {
+        if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            serverErr <- err
+        }
+    }()
+    healthHandler.SetReady(true)
+    
+    // 等待退出信号
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
     
-    if err := r.Run(serverAddr); err != nil {
-        log.Fatal("服务器启动失败:", err)
+    select {
+    case err := <-serverErr:
+        db.Close()
+        fatal("服务器启动失败", err)
+    case <-ctx.Done():
+        stop()
     }
+    
+    // 优雅关闭：先标记未就绪让负载均衡器摘除流量，再等待进行中的请求完成
+    slog.Info("收到退出信号，开始关闭服务器")
+    healthHandler.SetReady(false)
+    time.Sleep(cfg.Server.ShutdownDelay)
+    
+    // 结束实时事件流，否则长连接会一直阻塞关闭直到超时
+    db.Events().Close()
+    
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+    defer cancel()
+    
+    if err := srv.Shutdown(shutdownCtx); err != nil {
+        slog.Error("服务器未能在超时时间内完成关闭", "timeout", cfg.Server.ShutdownTimeout, "error", err)
+    }
+    
+    if err := shutdownTracing(shutdownCtx); err != nil {
+        slog.Error("导出剩余追踪数据失败", "error", err)
+    }
+    
+    // 等待后台的轮次分配，超时则中断（已提交的批次保留，下次启动时继续）
+    apiHandler.Shutdown(shutdownCtx)
+    
+    // 所有请求和后台任务结束后再关闭数据库连接池
+    if err := db.Close(); err != nil {
+        slog.Error("关闭数据库连接失败", "error", err)
+    }
+    
+    slog.Info("服务器已关闭")
 }
 
 func securityHeaders(environment string) gin.HandlerFunc {
@@ -101,45 +220,66 @@ func securityHeaders(environment string) gin.HandlerFunc {
     }
 }
 
-func requestLogger(logConfig config.LogConfig) gin.HandlerFunc {
-    if logConfig.Format == "json" {
-        return gin.Logger()
-    }
-    
-    return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-        return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-            param.ClientIP,
-            param.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
-            param.Method,
-            param.Path,
-            param.Request.Proto,
-            param.StatusCode,
-            param.Latency,
-            param.Request.UserAgent(),
-            param.ErrorMessage,
-        )
+// 按路由分组限流：所有接口按IP限流；学生注册接口另按IP单独限流以防批量注册；
+// 选课/退课接口按学生限流。系统暂无鉴权，以路径中的学生ID作为学生身份。
+func rateLimiter(rlConfig config.RateLimitConfig, exemptPaths ...string) gin.HandlerFunc {
+    exempt := make(map[string]bool, len(exemptPaths))
+    for _, path := range exemptPaths {
+        exempt[path] = true
+    }
+    
+    enrollmentPaths := map[string]bool{
+        "/students/:studentId/courses/:courseId":      true,
+        "/students/:studentId/courses/:courseId/swap": true,
+        "/students/:studentId/cart/checkout":          true,
+    }
+    policies := []ratelimit.Policy{
+        {
+            Name:    "default",
+            Limiter: ratelimit.NewLimiter(rlConfig.Default),
+            Match: func(c *gin.Context) bool {
+                return !exempt[c.Request.URL.Path]
+            },
+            Key: ratelimit.ByIP,
+        },
+        {
+            Name:    "signup",
+            Limiter: ratelimit.NewLimiter(rlConfig.Signup),
+            Match:   ratelimit.Route(http.MethodPost, "/students"),
+            Key:     ratelimit.ByIP,
+        },
+        {
+            Name:    "enrollment",
+            Limiter: ratelimit.NewLimiter(rlConfig.Enrollment),
+            Match: func(c *gin.Context) bool {
+                return enrollmentPaths[c.FullPath()] && c.Request.Method != http.MethodGet
+            },
+            Key: ratelimit.ByParam("studentId"),
+        },
+    }
+    
+    return ratelimit.Middleware(policies, func(policy string) {
+        metrics.RateLimitedTotal.WithLabelValues(policy).Inc()
     })
 }
 
+// 记录错误并退出进程
+func fatal(msg string, err error) {
+    slog.Error(msg, "error", err)
+    os.Exit(1)
+}
+
+// 调试端点。各表记录数由 /metrics 的 course_management_records 指标提供，不再单独提供 /debug/stats
 func setupDebugRoutes(r *gin.Engine, db *models.Database) {
     debug := r.Group("/debug")
     {
-        debug.GET("/stats", func(c *gin.Context) {
-            stats, err := db.GetDataStats()
-            if err != nil {
-                c.JSON(500, gin.H{"error": err.Error()})
-                return
-            }
-            c.JSON(200, gin.H{"stats": stats})
-        })
-        
         debug.POST("/reset-data", func(c *gin.Context) {
-            if err := db.ClearAllData(); err != nil {
+            if err := db.ClearAllData(c.Request.Context()); err != nil {
                 c.JSON(500, gin.H{"error": err.Error()})
                 return
             }
             
-            if err := db.InitializeSampleData(); err != nil {
+            if err := db.InitializeSampleData(c.Request.Context()); err != nil {
                 c.JSON(500, gin.H{"error": err.Error()})
                 return
             }
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "course_management"
+
+// ==================== HTTP指标 ====================
+
+var (
+    httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "http_requests_total",
+        Help:      "HTTP请求总数，按方法、路由和状态码区分",
+    }, []string{"method", "route", "status"})
+
+    httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Namespace: namespace,
+        Name:      "http_request_duration_seconds",
+        Help:      "HTTP请求处理耗时（秒）",
+        Buckets:   prometheus.DefBuckets,
+    }, []string{"method", "route"})
+
+    // 被限流拒绝的请求数，按限流策略区分
+    RateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "rate_limited_requests_total",
+        Help:      "被限流拒绝的请求数，按限流策略区分",
+    }, []string{"policy"})
+)
+
+// ==================== 业务指标 ====================
+
+var (
+    // 选课成功次数
+    EnrollmentsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "enrollments_total",
+        Help:      "选课成功次数",
+    })
+
+    // 退课成功次数
+    UnenrollmentsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "unenrollments_total",
+        Help:      "退课成功次数",
+    })
+
+    // 选课失败次数，按失败原因区分
+    EnrollmentFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "enrollment_failures_total",
+        Help:      "选课失败次数，按失败原因区分",
+    }, []string{"reason"})
+
+    // 新建课程数量
+    CoursesCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "courses_created_total",
+        Help:      "新建课程数量",
+    })
+
+    // 当前打开的实时事件流连接数
+    EventStreamsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+        Namespace: namespace,
+        Name:      "event_streams_active",
+        Help:      "当前打开的实时事件流连接数",
+    })
+)
+
+// 创建指标注册表，注册运行时、数据库连接池以及本服务的全部指标
+func NewRegistry(db *sql.DB, dataStats func(context.Context) (map[string]int, error)) *prometheus.Registry {
+    reg := prometheus.NewRegistry()
+    reg.MustRegister(
+        collectors.NewGoCollector(),
+        collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+        collectors.NewDBStatsCollector(db, "postgres"),
+        newRecordsCollector(dataStats),
+        httpRequestsTotal,
+        httpRequestDuration,
+        RateLimitedTotal,
+        EnrollmentsTotal,
+        UnenrollmentsTotal,
+        EnrollmentFailuresTotal,
+        CoursesCreatedTotal,
+        EventStreamsActive,
+    )
+    return reg
+}
+
+// 指标暴露端点
+func Handler(reg *prometheus.Registry) gin.HandlerFunc {
+    return gin.WrapH(promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}))
+}
+
+// 记录每个请求的次数和耗时。路由使用注册时的模板（如 /course/:id），避免标签基数膨胀
+func Middleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+
+        httpRequestsTotal.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+        httpRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+    }
+}
+
+// ==================== 数据量指标 ====================
+
+// 单次抓取中查询记录数的超时时间
+const collectTimeout = 5 * time.Second
+
+// 每次抓取时查询各表的记录数，取代 /debug/stats 作为监控数据来源
+type recordsCollector struct {
+    dataStats func(context.Context) (map[string]int, error)
+    desc      *prometheus.Desc
+}
+
+func newRecordsCollector(dataStats func(context.Context) (map[string]int, error)) *recordsCollector {
+    return &recordsCollector{
+        dataStats: dataStats,
+        desc: prometheus.NewDesc(
+            prometheus.BuildFQName(namespace, "", "records"),
+            "各数据表当前记录数",
+            []string{"table"}, nil,
+        ),
+    }
+}
+
+func (rc *recordsCollector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- rc.desc
+}
+
+func (rc *recordsCollector) Collect(ch chan<- prometheus.Metric) {
+    ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+    defer cancel()
+
+    stats, err := rc.dataStats(ctx)
+    if err != nil {
+        ch <- prometheus.NewInvalidMetric(rc.desc, err)
+        return
+    }
+
+    for table, count := range stats {
+        ch <- prometheus.MustNewConstMetric(rc.desc, prometheus.GaugeValue, float64(count), table)
+    }
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "course-management/requestid"
+)
+
+// 审计事件的操作类型
+const (
+    AuditCourseCreated  = "course.created"
+    AuditCourseUpdated  = "course.updated"
+    AuditStudentCreated = "student.created"
+    AuditEnrolled       = "enrollment.created"
+    AuditDropped        = "enrollment.dropped"
+    AuditRemovedByAdmin = "enrollment.removed_by_admin"
+    AuditMoved          = "enrollment.moved"
+    AuditStatusChanged  = "enrollment.status_changed"
+    AuditBulkEnrolled   = "enrollment.bulk_enrolled"
+    AuditBulkMoved      = "enrollment.bulk_moved"
+    AuditBulkCopied     = "enrollment.bulk_copied"
+    AuditGradeRecorded  = "grade.recorded"
+    AuditGradeAmended   = "grade.amended"
+
+    AuditPrerequisitesUpdated = "course.prerequisites_updated"
+
+    AuditProgrammeCreated    = "programme.created"
+    AuditProgrammeDeclared   = "programme.declared"
+    AuditProgrammeUndeclared = "programme.undeclared"
+
+    AuditSectionCreated = "section.created"
+    AuditSectionUpdated = "section.updated"
+
+    AuditInstructorCreated      = "instructor.created"
+    AuditInstructorAssigned     = "instructor.assigned"
+    AuditInstructorUnassigned   = "instructor.unassigned"
+    AuditInstructorAvailability = "instructor.unavailability_updated"
+
+    AuditRoomCreated = "room.created"
+
+    AuditRoundCreated   = "round.created"
+    AuditRoundAllocated = "round.allocated"
+
+    AuditPetitionSubmitted = "petition.submitted"
+    AuditPetitionApproved  = "petition.approved"
+    AuditPetitionRejected  = "petition.rejected"
+
+    AuditWaitlistJoined   = "waitlist.joined"
+    AuditWaitlistLeft     = "waitlist.left"
+    AuditWaitlistPromoted = "waitlist.promoted"
+    AuditWaitlistSkipped  = "waitlist.skipped"
+    AuditWaitlistRemoved  = "waitlist.removed"
+)
+
+// 未在上下文中指定操作者时使用（如启动任务、命令行工具）
+const SystemActor = "system"
+
+// 审计事件：记录每次写操作的操作者、对象以及变更前后的数据。
+// 不关联外键，学生或课程删除后记录依然保留。
+type AuditEvent struct {
+    ID        int64           `json:"id"`
+    Actor     string          `json:"actor"`
+    Action    string          `json:"action"`
+    StudentID *int            `json:"student_id"`
+    CourseID  *int            `json:"course_id"`
+    Before    json.RawMessage `json:"before"`
+    After     json.RawMessage `json:"after"`
+    RequestID string          `json:"request_id"`
+    CreatedAt time.Time       `json:"created_at"`
+}
+
+// 审计日志查询条件，零值表示不限制
+type AuditFilter struct {
+    StudentID int
+    CourseID  int
+    From      time.Time
+    To        time.Time
+    Limit     int
+}
+
+type actorKey struct{}
+
+// 在上下文中记录操作者，写操作据此生成审计事件
+func WithActor(ctx context.Context, actor string) context.Context {
+    return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFromContext(ctx context.Context) string {
+    if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+        return actor
+    }
+    return SystemActor
+}
+
+// 在写操作所在的事务中追加一条审计事件，studentID/courseID 为 0 表示不涉及。只检查的事务中不记录
+func (tx txn) recordAudit(ctx context.Context, action string, studentID, courseID int, before, after any) error {
+    if tx.validateOnly {
+        return nil
+    }
+    beforeData, err := auditData(before)
+    if err != nil {
+        return err
+    }
+    afterData, err := auditData(after)
+    if err != nil {
+        return err
+    }
+
+    query := `
+        INSERT INTO audit_events (actor, action, student_id, course_id, before_data, after_data, request_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `
+
+    _, err = tx.exec(ctx, query, actorFromContext(ctx), action,
+        nullableID(studentID), nullableID(courseID), beforeData, afterData,
+        requestid.FromContext(ctx))
+    if err != nil {
+        return fmt.Errorf("failed to record audit event: %w", queryError(ctx, err))
+    }
+
+    return nil
+}
+
+// 查询审计日志，按时间倒序。同时返回符合条件的总条数（不受 Limit 影响）
+func (db *Database) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, int, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    var conditions []string
+    var args []any
+    addCondition := func(condition string, value any) {
+        args = append(args, value)
+        conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+    }
+
+    if filter.StudentID > 0 {
+        addCondition("student_id = $%d", filter.StudentID)
+    }
+    if filter.CourseID > 0 {
+        addCondition("course_id = $%d", filter.CourseID)
+    }
+    if !filter.From.IsZero() {
+        addCondition("created_at >= $%d", filter.From)
+    }
+    if !filter.To.IsZero() {
+        addCondition("created_at < $%d", filter.To)
+    }
+
+    where := ""
+    if len(conditions) > 0 {
+        where = " WHERE " + strings.Join(conditions, " AND ")
+    }
+
+    var total int
+    if err := db.queryRow(ctx, `SELECT COUNT(*) FROM audit_events`+where, args...).Scan(&total); err != nil {
+        return nil, 0, fmt.Errorf("failed to count audit events: %w", queryError(ctx, err))
+    }
+
+    query := `
+        SELECT id, actor, action, student_id, course_id, before_data, after_data,
+               COALESCE(request_id, ''), created_at
+        FROM audit_events
+    ` + where
+    args = append(args, filter.Limit)
+    query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+    rows, err := db.query(ctx, query, args...)
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to query audit events: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    events := []AuditEvent{}
+    for rows.Next() {
+        var event AuditEvent
+        var before, after []byte
+        err := rows.Scan(
+            &event.ID, &event.Actor, &event.Action, &event.StudentID, &event.CourseID,
+            &before, &after, &event.RequestID, &event.CreatedAt,
+        )
+        if err != nil {
+            return nil, 0, fmt.Errorf("failed to scan audit event: %w", queryError(ctx, err))
+        }
+        if before != nil {
+            event.Before = json.RawMessage(before)
+        }
+        if after != nil {
+            event.After = json.RawMessage(after)
+        }
+        events = append(events, event)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, 0, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return events, total, nil
+}
+
+// 序列化变更数据，nil 记为 NULL。以字符串传参，避免驱动将 []byte 按 bytea 编码
+func auditData(value any) (any, error) {
+    if value == nil {
+        return nil, nil
+    }
+    data, err := json.Marshal(value)
+    if err != nil {
+        return nil, fmt.Errorf("failed to encode audit data: %w", err)
+    }
+    return string(data), nil
+}
+
+func nullableID(id int) any {
+    if id <= 0 {
+        return nil
+    }
+    return id
+}
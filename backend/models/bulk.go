@@ -0,0 +1,379 @@
+package models
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "sort"
+
+    "github.com/lib/pq"
+)
+
+// 批量操作中每个学生的结果。Status 取值与购物车结算相同：enrolled、failed、not_enrolled
+type BulkItemResult struct {
+    StudentID  int
+    CourseID   int
+    CourseCode string
+    Status     string
+    Err        error // Status 为 failed 时的原因
+}
+
+// 批量操作的结果。Mode 与购物车结算相同，Committed 表示操作已生效
+type BulkResult struct {
+    Mode      string
+    Committed bool
+    Items     []BulkItemResult
+}
+
+// 批量操作 all_or_nothing 模式下有失败项时整体回滚事务
+var errBulkRollback = errors.New("bulk operation rollback")
+
+// 批量操作中的一项：为一个学生执行 run，失败时只回滚该项
+type bulkOperation struct {
+    studentID  int
+    courseID   int
+    courseCode string
+    run        func(tx txn) error
+}
+
+// 批量为学生选课 (管理员功能)，选课规则与学生自行选课相同
+func (db *Database) BulkEnroll(ctx context.Context, courseID int, studentIDs, sectionIDs []int, mode string) (*BulkResult, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    course, err := db.GetCourseByID(ctx, courseID)
+    if err != nil {
+        return nil, err
+    }
+    if course == nil {
+        return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, courseID)
+    }
+
+    studentIDs = slices.Clone(studentIDs)
+    sort.Ints(studentIDs)
+    studentIDs = slices.Compact(studentIDs)
+
+    ops := make([]bulkOperation, len(studentIDs))
+    for i, studentID := range studentIDs {
+        ops[i] = bulkOperation{
+            studentID:  studentID,
+            courseID:   courseID,
+            courseCode: course.CourseCode,
+            run: func(tx txn) error {
+                _, err := tx.enroll(ctx, studentID, courseID, sectionIDs)
+                return err
+            },
+        }
+    }
+
+    return db.runBulk(ctx, AuditBulkEnrolled, courseID, mode, ops)
+}
+
+// 将课程（fromSectionID 不为 0 时仅该教学班）的所有在读学生转到另一课程或教学班 (管理员功能)，
+// 如教学班合并。原选课记录标记为 moved，再按选课规则选择新课程，失败的学生保留原课程。
+// 转课的学生在事务中锁定后确定，执行期间退课的学生不再处理。
+// toCourseID 与 fromCourseID 相同时只更换 fromSectionID，学生的其他教学班保持不变
+func (db *Database) MoveEnrollments(ctx context.Context, fromCourseID, fromSectionID, toCourseID int, toSectionIDs []int, mode string) (*BulkResult, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    if fromCourseID == toCourseID && fromSectionID == 0 {
+        return nil, fmt.Errorf("%w: from_section_id is required when moving within a course", ErrInvalidSections)
+    }
+
+    fromCourse, err := db.GetCourseByID(ctx, fromCourseID)
+    if err != nil {
+        return nil, err
+    }
+    if fromCourse == nil {
+        return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, fromCourseID)
+    }
+    toCourse, err := db.GetCourseByID(ctx, toCourseID)
+    if err != nil {
+        return nil, err
+    }
+    if toCourse == nil {
+        return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, toCourseID)
+    }
+
+    load := func(tx txn) ([]bulkOperation, error) {
+        // 先按当前在读的学生加锁，与选课相同先锁学生再锁课程；加锁后重新查询，
+        // 期间退课的学生不再在读，期间新选课的学生未被锁定，均不处理
+        candidates, err := tx.moveCandidates(ctx, fromCourseID, fromSectionID)
+        if err != nil {
+            return nil, err
+        }
+        studentIDs := make([]int, len(candidates))
+        for i, c := range candidates {
+            studentIDs[i] = c.studentID
+        }
+        if err := tx.lockBulk(ctx, studentIDs, []int{fromCourseID, toCourseID}); err != nil {
+            return nil, err
+        }
+
+        candidates, err = tx.moveCandidates(ctx, fromCourseID, fromSectionID)
+        if err != nil {
+            return nil, err
+        }
+
+        var ops []bulkOperation
+        for _, c := range candidates {
+            if !slices.Contains(studentIDs, c.studentID) {
+                continue
+            }
+
+            sectionIDs := toSectionIDs
+            if fromCourseID == toCourseID {
+                sectionIDs = slices.Clone(toSectionIDs)
+                for _, id := range c.sectionIDs {
+                    if id != fromSectionID {
+                        sectionIDs = append(sectionIDs, id)
+                    }
+                }
+            }
+
+            studentID := c.studentID
+            ops = append(ops, bulkOperation{
+                studentID:  studentID,
+                courseID:   toCourseID,
+                courseCode: toCourse.CourseCode,
+                run: func(tx txn) error {
+                    _, err := tx.endEnrollment(ctx, studentID, fromCourseID, EnrollmentMoved, AuditMoved)
+                    if err != nil {
+                        return err
+                    }
+                    _, err = tx.enroll(ctx, studentID, toCourseID, sectionIDs)
+                    return err
+                },
+            })
+        }
+        return ops, nil
+    }
+
+    result, err := db.runBulkTx(ctx, AuditBulkMoved, fromCourseID, mode, load)
+    if err != nil {
+        return nil, err
+    }
+
+    // 转出的学生释放了原课程（或原教学班）的名额
+    if result.Committed {
+        db.promoteWaitlists(ctx, fromCourseID)
+    }
+    return result, nil
+}
+
+// 待转课的学生及其当前所在的教学班
+type moveCandidate struct {
+    studentID  int
+    sectionIDs []int
+}
+
+// 查询课程（fromSectionID 不为 0 时仅该教学班）当前在读的学生
+func (tx txn) moveCandidates(ctx context.Context, courseID, sectionID int) ([]moveCandidate, error) {
+    query := `
+        SELECT sc.student_id,
+               ARRAY(SELECT es.section_id FROM enrollment_sections es WHERE es.enrollment_id = sc.id ORDER BY es.section_id)
+        FROM student_courses sc
+        WHERE sc.course_id = $1 AND sc.status = 'enrolled'
+          AND ($2 = 0 OR EXISTS (SELECT 1 FROM enrollment_sections es WHERE es.enrollment_id = sc.id AND es.section_id = $2))
+        ORDER BY sc.student_id
+    `
+
+    rows, err := tx.query(ctx, query, courseID, sectionID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query enrolled students: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    var candidates []moveCandidate
+    for rows.Next() {
+        var c moveCandidate
+        var current pq.Int64Array
+        if err := rows.Scan(&c.studentID, &current); err != nil {
+            return nil, fmt.Errorf("failed to scan enrolled student: %w", queryError(ctx, err))
+        }
+        c.sectionIDs = make([]int, len(current))
+        for i, id := range current {
+            c.sectionIDs[i] = int(id)
+        }
+        candidates = append(candidates, c)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return candidates, nil
+}
+
+// 将 fromSemester 的选课（在读或已修完）复制到 toSemester 中课程代码相同的课程 (管理员功能)，
+// 如跨学期的全年课程。courseCodes 不为空时只复制这些课程。原课程的教学班按教学班代码对应到新课程
+func (db *Database) CopyEnrollments(ctx context.Context, fromSemester, toSemester string, courseCodes []string, mode string) (*BulkResult, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    if fromSemester == toSemester {
+        return nil, fmt.Errorf("%w: source and target semesters are the same", ErrInvalidSemester)
+    }
+    if courseCodes == nil {
+        courseCodes = []string{}
+    }
+
+    // 目标学期有多门课程代码相同时取ID最小的一门
+    query := `
+        SELECT sc.student_id, c.course_code, target.id,
+               ARRAY(SELECT ts.id
+                     FROM enrollment_sections es
+                     JOIN course_sections fs ON fs.id = es.section_id
+                     JOIN course_sections ts ON ts.course_id = target.id AND ts.section_code = fs.section_code
+                     WHERE es.enrollment_id = sc.id
+                     ORDER BY ts.id)
+        FROM student_courses sc
+        JOIN courses c ON c.id = sc.course_id
+        LEFT JOIN LATERAL (
+            SELECT t.id
+            FROM courses t
+            WHERE t.course_code = c.course_code AND t.semester = $2
+            ORDER BY t.id
+            LIMIT 1
+        ) target ON true
+        WHERE c.semester = $1 AND sc.status IN ('enrolled', 'completed')
+          AND (cardinality($3::text[]) = 0 OR c.course_code = ANY($3))
+        ORDER BY sc.student_id, c.course_code
+    `
+
+    rows, err := db.query(ctx, query, fromSemester, toSemester, pq.Array(courseCodes))
+    if err != nil {
+        return nil, fmt.Errorf("failed to query semester enrollments: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    var ops []bulkOperation
+    for rows.Next() {
+        var studentID int
+        var courseCode string
+        var targetID *int
+        var sections pq.Int64Array
+        if err := rows.Scan(&studentID, &courseCode, &targetID, &sections); err != nil {
+            return nil, fmt.Errorf("failed to scan semester enrollment: %w", queryError(ctx, err))
+        }
+
+        op := bulkOperation{studentID: studentID, courseCode: courseCode}
+        if targetID == nil {
+            op.run = func(tx txn) error {
+                return fmt.Errorf("%w: %s is not offered in %s", ErrCourseNotFound, courseCode, toSemester)
+            }
+        } else {
+            courseID := *targetID
+            sectionIDs := make([]int, len(sections))
+            for i, id := range sections {
+                sectionIDs[i] = int(id)
+            }
+            op.courseID = courseID
+            op.run = func(tx txn) error {
+                _, err := tx.enroll(ctx, studentID, courseID, sectionIDs)
+                return err
+            }
+        }
+        ops = append(ops, op)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+    rows.Close()
+
+    return db.runBulk(ctx, AuditBulkCopied, 0, mode, ops)
+}
+
+// 在一个事务中逐项执行批量操作，每项使用一个保存点，违反选课规则时只回滚该项。
+// all_or_nothing 模式下任何一项失败则整体回滚。先按ID顺序锁定涉及的学生和课程，避免与其他批量操作形成死锁。
+// 完成后记录一条汇总审计事件，各学生的选课和退课另有各自的审计事件
+func (db *Database) runBulk(ctx context.Context, action string, courseID int, mode string, ops []bulkOperation) (*BulkResult, error) {
+    return db.runBulkTx(ctx, action, courseID, mode, func(tx txn) ([]bulkOperation, error) {
+        var studentIDs, courseIDs []int
+        for _, op := range ops {
+            studentIDs = append(studentIDs, op.studentID)
+            if op.courseID > 0 {
+                courseIDs = append(courseIDs, op.courseID)
+            }
+        }
+        return ops, tx.lockBulk(ctx, studentIDs, courseIDs)
+    })
+}
+
+// 与 runBulk 相同，但在事务中由 load 锁定相关的学生和课程并确定要执行的操作
+func (db *Database) runBulkTx(ctx context.Context, action string, courseID int, mode string, load func(tx txn) ([]bulkOperation, error)) (*BulkResult, error) {
+    result := &BulkResult{Mode: mode, Items: []BulkItemResult{}}
+    err := db.inTx(ctx, func(tx txn) error {
+        ops, err := load(tx)
+        if err != nil {
+            return err
+        }
+
+        failed := 0
+        for _, op := range ops {
+            item := BulkItemResult{StudentID: op.studentID, CourseID: op.courseID, CourseCode: op.courseCode}
+
+            if err := tx.savepoint(ctx, "bulk_item"); err != nil {
+                return err
+            }
+            // 学生不存在时插入选课记录会违反外键约束，先锁定学生以得到 ErrStudentNotFound
+            err := tx.lockStudent(ctx, op.studentID)
+            if err == nil {
+                err = op.run(tx)
+            }
+            switch {
+            case err == nil:
+                if err := tx.releaseSavepoint(ctx, "bulk_item"); err != nil {
+                    return err
+                }
+                item.Status = CartItemEnrolled
+            case errors.Is(err, ErrStudentNotFound) || isEnrollmentRuleError(err):
+                if err := tx.rollbackToSavepoint(ctx, "bulk_item"); err != nil {
+                    return err
+                }
+                item.Status = CartItemFailed
+                item.Err = err
+                failed++
+            default:
+                return err
+            }
+            result.Items = append(result.Items, item)
+        }
+
+        if failed > 0 && mode != CheckoutBestEffort {
+            return errBulkRollback
+        }
+        result.Committed = true
+
+        summary := map[string]any{"mode": mode, "processed": len(ops), "succeeded": len(ops) - failed, "failed": failed}
+        return tx.recordAudit(ctx, action, 0, courseID, nil, summary)
+    })
+    if err != nil && !errors.Is(err, errBulkRollback) {
+        return nil, err
+    }
+
+    if !result.Committed {
+        for i := range result.Items {
+            if result.Items[i].Status == CartItemEnrolled {
+                result.Items[i].Status = CartItemNotEnrolled
+            }
+        }
+    }
+    return result, nil
+}
+
+// 按ID顺序锁定批量操作涉及的学生和课程，先学生后课程，与选课的加锁顺序一致
+func (tx txn) lockBulk(ctx context.Context, studentIDs, courseIDs []int) error {
+    _, err := tx.exec(ctx, `SELECT id FROM students WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(studentIDs))
+    if err != nil {
+        return fmt.Errorf("failed to lock students: %w", queryError(ctx, err))
+    }
+    _, err = tx.exec(ctx, `SELECT id FROM courses WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(courseIDs))
+    if err != nil {
+        return fmt.Errorf("failed to lock courses: %w", queryError(ctx, err))
+    }
+    return nil
+}
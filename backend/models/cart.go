@@ -0,0 +1,290 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// 购物车中的一门课程及选择的教学班
+type CartItem struct {
+    Course     Course
+    SectionIDs []int
+    AddedAt    time.Time
+}
+
+// 结算方式
+const (
+    CheckoutAllOrNothing = "all_or_nothing" // 任何一门课程失败则全部不选
+    CheckoutBestEffort   = "best_effort"    // 能选的课程都选上
+)
+
+// 购物车中每门课程的校验或结算结果
+const (
+    CartItemValid       = "valid"        // 校验通过
+    CartItemEnrolled    = "enrolled"     // 已选课
+    CartItemFailed      = "failed"       // 不满足选课规则
+    CartItemNotEnrolled = "not_enrolled" // 满足规则，但因其他课程失败未选课（all_or_nothing）
+)
+
+type CartItemResult struct {
+    CourseID   int
+    CourseCode string
+    Status     string
+    Err        error // Status 为 failed 时的原因
+}
+
+// 购物车的校验或结算结果。Committed 表示选课已生效
+type CartResult struct {
+    Mode      string
+    Committed bool
+    Items     []CartItemResult
+}
+
+const cartQuery = `
+    SELECT ` + courseColumns + `, section_ids, added_at
+    FROM cart_items
+    JOIN courses ON courses.id = cart_items.course_id
+    WHERE student_id = $1
+    ORDER BY added_at, course_id
+`
+
+// 校验或结算时整体回滚事务
+var errCartRollback = errors.New("cart rollback")
+
+// 获取学生的购物车，按加入顺序排列
+func (db *Database) GetCart(ctx context.Context, studentID int) ([]CartItem, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    exists, err := db.StudentExists(ctx, studentID)
+    if err != nil {
+        return nil, err
+    }
+    if !exists {
+        return nil, fmt.Errorf("%w (ID %d)", ErrStudentNotFound, studentID)
+    }
+
+    rows, err := db.query(ctx, cartQuery, studentID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query cart: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    return scanCartItems(ctx, rows)
+}
+
+// 将课程加入购物车，已在购物车中时更新选择的教学班。选课规则在校验和结算时检查
+func (db *Database) AddToCart(ctx context.Context, studentID, courseID int, sectionIDs []int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    exists, err := db.StudentExists(ctx, studentID)
+    if err != nil {
+        return err
+    }
+    if !exists {
+        return fmt.Errorf("%w (ID %d)", ErrStudentNotFound, studentID)
+    }
+
+    courseExists, err := db.CourseExists(ctx, courseID)
+    if err != nil {
+        return err
+    }
+    if !courseExists {
+        return fmt.Errorf("%w (ID %d)", ErrCourseNotFound, courseID)
+    }
+
+    if sectionIDs == nil {
+        sectionIDs = []int{}
+    }
+
+    query := `
+        INSERT INTO cart_items (student_id, course_id, section_ids)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (student_id, course_id) DO UPDATE SET section_ids = EXCLUDED.section_ids
+    `
+
+    if _, err := db.exec(ctx, query, studentID, courseID, pq.Array(sectionIDs)); err != nil {
+        return fmt.Errorf("failed to add course to cart: %w", queryError(ctx, err))
+    }
+    return nil
+}
+
+// 从购物车移除课程
+func (db *Database) RemoveFromCart(ctx context.Context, studentID, courseID int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    result, err := db.exec(ctx, `DELETE FROM cart_items WHERE student_id = $1 AND course_id = $2`, studentID, courseID)
+    if err != nil {
+        return fmt.Errorf("failed to remove course from cart: %w", queryError(ctx, err))
+    }
+
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("failed to get rows affected: %w", err)
+    }
+    if affected == 0 {
+        return fmt.Errorf("%w (student %d, course %d)", ErrNotInCart, studentID, courseID)
+    }
+    return nil
+}
+
+// 按结算的规则逐门检查购物车，前面的课程视为已选上，但不实际选课。
+// 校验不锁定课程和教学班，结果可能与随后结算时不同（如名额已被其他学生选走）
+func (db *Database) ValidateCart(ctx context.Context, studentID int) (*CartResult, error) {
+    return db.processCart(ctx, studentID, "", nil)
+}
+
+// 结算购物车：按加入顺序逐门选课。all_or_nothing 模式下任何一门失败则全部不选；
+// best_effort 模式下选上能选的课程。选上的课程从购物车移除，失败的课程保留
+func (db *Database) CheckoutCart(ctx context.Context, studentID int, mode string) (*CartResult, error) {
+    return db.processCart(ctx, studentID, mode, func(failed int) bool {
+        return failed == 0 || mode == CheckoutBestEffort
+    })
+}
+
+// 在一个事务中逐门选课，每门课程使用一个保存点，失败时只回滚该课程。commit 根据失败数决定是否提交。
+// mode 为空时只做校验，事务不加行锁且总是回滚，commit 可以为空
+func (db *Database) processCart(ctx context.Context, studentID int, mode string, commit func(failed int) bool) (*CartResult, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    result := &CartResult{Mode: mode}
+    err := db.inTx(ctx, func(tx txn) error {
+        tx.validateOnly = mode == ""
+        if err := tx.lockStudent(ctx, studentID); err != nil {
+            return err
+        }
+
+        items, err := tx.cartItems(ctx, studentID)
+        if err != nil {
+            return err
+        }
+        if len(items) == 0 {
+            return ErrCartEmpty
+        }
+
+        failed := 0
+        var enrolled []int
+        for _, item := range items {
+            itemResult := CartItemResult{CourseID: item.Course.ID, CourseCode: item.Course.CourseCode}
+
+            if err := tx.savepoint(ctx, "cart_item"); err != nil {
+                return err
+            }
+            _, err := tx.enroll(ctx, studentID, item.Course.ID, item.SectionIDs)
+            switch {
+            case err == nil:
+                if err := tx.releaseSavepoint(ctx, "cart_item"); err != nil {
+                    return err
+                }
+                itemResult.Status = CartItemValid
+                enrolled = append(enrolled, item.Course.ID)
+            case isEnrollmentRuleError(err):
+                if err := tx.rollbackToSavepoint(ctx, "cart_item"); err != nil {
+                    return err
+                }
+                itemResult.Status = CartItemFailed
+                itemResult.Err = err
+                failed++
+            default:
+                return err
+            }
+            result.Items = append(result.Items, itemResult)
+        }
+
+        if tx.validateOnly || !commit(failed) {
+            return errCartRollback
+        }
+
+        _, err = tx.exec(ctx, `DELETE FROM cart_items WHERE student_id = $1 AND course_id = ANY($2)`,
+            studentID, pq.Array(enrolled))
+        if err != nil {
+            return fmt.Errorf("failed to clear cart: %w", queryError(ctx, err))
+        }
+        result.Committed = true
+        return nil
+    })
+    if err != nil && !errors.Is(err, errCartRollback) {
+        return nil, err
+    }
+
+    for i := range result.Items {
+        item := &result.Items[i]
+        switch {
+        case item.Status != CartItemValid:
+        case result.Committed:
+            item.Status = CartItemEnrolled
+        case mode != "":
+            item.Status = CartItemNotEnrolled
+        }
+    }
+    return result, nil
+}
+
+// 事务中读取购物车，并按课程ID顺序锁定其中的课程，避免并发结算时互相等待形成死锁
+func (tx txn) cartItems(ctx context.Context, studentID int) ([]CartItem, error) {
+    if !tx.validateOnly {
+        _, err := tx.exec(ctx, `
+            SELECT c.id
+            FROM courses c
+            JOIN cart_items ci ON ci.course_id = c.id
+            WHERE ci.student_id = $1
+            ORDER BY c.id
+            FOR UPDATE OF c
+        `, studentID)
+        if err != nil {
+            return nil, fmt.Errorf("failed to lock cart courses: %w", queryError(ctx, err))
+        }
+    }
+
+    rows, err := tx.query(ctx, cartQuery, studentID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query cart: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    return scanCartItems(ctx, rows)
+}
+
+func scanCartItems(ctx context.Context, rows *sql.Rows) ([]CartItem, error) {
+    items := []CartItem{}
+    for rows.Next() {
+        var item CartItem
+        var sectionIDs pq.Int64Array
+        fields := append(courseFields(&item.Course), &sectionIDs, &item.AddedAt)
+        if err := rows.Scan(fields...); err != nil {
+            return nil, fmt.Errorf("failed to scan cart item: %w", queryError(ctx, err))
+        }
+        item.SectionIDs = make([]int, len(sectionIDs))
+        for i, id := range sectionIDs {
+            item.SectionIDs[i] = int(id)
+        }
+        items = append(items, item)
+    }
+
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return items, nil
+}
+
+// 是否为违反选课规则的错误。其他错误（如数据库故障）中止整个结算
+func isEnrollmentRuleError(err error) bool {
+    for _, target := range []error{
+        ErrCourseNotFound, ErrAlreadyEnrolled, ErrInvalidSections, ErrSectionFull, ErrCourseFull,
+        ErrPrerequisitesNotMet, ErrCreditLimit, ErrTimeClash, ErrRoundPending,
+    } {
+        if errors.Is(err, target) {
+            return true
+        }
+    }
+    return false
+}
@@ -1,122 +1,174 @@
 package models
 
 import (
+    "context"
     "database/sql"
     "fmt"
 )
 
-func (db *Database) GetAllCourses() ([]Course, error) {
+// 课程的查询列及扫描目标，与 Course 字段一一对应
+const courseColumns = `id, course_code, course_name, course_description,
+               credits, instructor, semester, time_slot, course_location, category,
+               room_id, capacity, created_at`
+
+func courseFields(c *Course) []any {
+    return []any{
+        &c.ID, &c.CourseCode, &c.CourseName, &c.CourseDescription,
+        &c.Credits, &c.Instructor, &c.Semester, &c.TimeSlot, &c.CourseLocation, &c.Category,
+        &c.RoomID, &c.Capacity, &c.CreatedAt,
+    }
+}
+
+func (db *Database) GetAllCourses(ctx context.Context) ([]Course, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
-        SELECT id, course_code, course_name, course_description,
-               credits, instructor, semester, time_slot, course_location, created_at
+        SELECT ` + courseColumns + `
         FROM courses
         ORDER BY course_code, semester
     `
     
-    rows, err := db.DB.Query(query)
+    rows, err := db.query(ctx, query)
     if err != nil {
-        return nil, fmt.Errorf("failed to query courses: %w", err)
+        return nil, fmt.Errorf("failed to query courses: %w", queryError(ctx, err))
     }
     defer rows.Close()
     
     var courses []Course
     for rows.Next() {
         var course Course
-        err := rows.Scan(
-            &course.ID, &course.CourseCode, &course.CourseName, &course.CourseDescription,
-            &course.Credits, &course.Instructor, &course.Semester, &course.TimeSlot,
-            &course.CourseLocation, &course.CreatedAt,
-        )
+        err := rows.Scan(courseFields(&course)...)
         if err != nil {
-            return nil, fmt.Errorf("failed to scan course: %w", err)
+            return nil, fmt.Errorf("failed to scan course: %w", queryError(ctx, err))
         }
         courses = append(courses, course)
     }
     
     if err = rows.Err(); err != nil {
-        return nil, fmt.Errorf("rows iteration error: %w", err)
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
     }
     
     return courses, nil
 }
 
-func (db *Database) GetCourseByID(courseID int) (*Course, error) {
+func (db *Database) GetCourseByID(ctx context.Context, courseID int) (*Course, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
-        SELECT id, course_code, course_name, course_description,
-               credits, instructor, semester, time_slot, course_location, created_at
+        SELECT ` + courseColumns + `
         FROM courses
         WHERE id = $1
     `
     
     var course Course
-    err := db.DB.QueryRow(query, courseID).Scan(
-        &course.ID, &course.CourseCode, &course.CourseName, &course.CourseDescription,
-        &course.Credits, &course.Instructor, &course.Semester, &course.TimeSlot,
-        &course.CourseLocation, &course.CreatedAt,
-    )
+    err := db.queryRow(ctx, query, courseID).Scan(courseFields(&course)...)
     
     if err != nil {
         if err == sql.ErrNoRows {
             return nil, nil // 课程不存在
         }
-        return nil, fmt.Errorf("failed to get course: %w", err)
+        return nil, fmt.Errorf("failed to get course: %w", queryError(ctx, err))
     }
     
     return &course, nil
 }
 
-func (db *Database) AddCourse(courseCode, courseName, courseDescription string, 
-                             credits int, instructor, semester, timeSlot, courseLocation string) (*Course, error) {
+// 获取课程当前在读的人数
+func (db *Database) GetEnrolledCount(ctx context.Context, courseID int) (int, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
+    var count int
+    err := db.queryRow(ctx, `SELECT COUNT(*) FROM student_courses WHERE course_id = $1 AND status = 'enrolled'`,
+        courseID).Scan(&count)
+    if err != nil {
+        return 0, fmt.Errorf("failed to count course enrollments: %w", queryError(ctx, err))
+    }
+    
+    return count, nil
+}
+
+// 添加课程。roomID 不为 0 时关联教室，并检查教室容量和同学期的时间冲突；capacity 为 0 表示不限人数
+func (db *Database) AddCourse(ctx context.Context, courseCode, courseName, courseDescription string, 
+                             credits int, instructor, semester, timeSlot, courseLocation, category string,
+                             roomID, capacity int) (*Course, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
         INSERT INTO courses (course_code, course_name, course_description, credits, 
-                           instructor, semester, time_slot, course_location)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-        RETURNING id, course_code, course_name, course_description, credits,
-                  instructor, semester, time_slot, course_location, created_at
-    `
+                           instructor, semester, time_slot, course_location, category, room_id, capacity)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING ` + courseColumns
     
     var course Course
-    err := db.DB.QueryRow(query, courseCode, courseName, courseDescription, credits,
-                         instructor, semester, timeSlot, courseLocation).Scan(
-        &course.ID, &course.CourseCode, &course.CourseName, &course.CourseDescription,
-        &course.Credits, &course.Instructor, &course.Semester, &course.TimeSlot,
-        &course.CourseLocation, &course.CreatedAt,
-    )
-    
+    err := db.inTx(ctx, func(tx txn) error {
+        if roomID > 0 {
+            var courseCapacity *int
+            if capacity > 0 {
+                courseCapacity = &capacity
+            }
+            room, err := tx.checkRoomBooking(ctx, 0, semester, timeSlot, roomID, courseCapacity)
+            if err != nil {
+                return err
+            }
+            courseLocation = room.Label()
+            // 安排在教室的课程不能不限人数，未指定容量时取教室容量
+            if capacity <= 0 {
+                capacity = room.Capacity
+            }
+        }
+        
+        err := tx.queryRow(ctx, query, courseCode, courseName, courseDescription, credits,
+                           instructor, semester, timeSlot, courseLocation, category,
+                           nullableID(roomID), nullableInt(capacity)).Scan(courseFields(&course)...)
+        if err != nil {
+            return fmt.Errorf("failed to add course: %w", queryError(ctx, err))
+        }
+        
+        if err := tx.linkInstructorsByName(ctx, course.ID, instructor); err != nil {
+            return err
+        }
+        
+        return tx.recordAudit(ctx, AuditCourseCreated, 0, course.ID, nil, course)
+    })
     if err != nil {
-        return nil, fmt.Errorf("failed to add course: %w", err)
+        return nil, err
     }
     
     return &course, nil
 }
 
-func (db *Database) SearchCourses(keyword string) ([]Course, error) {
+func (db *Database) SearchCourses(ctx context.Context, keyword string) ([]Course, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
-        SELECT id, course_code, course_name, course_description,
-               credits, instructor, semester, time_slot, course_location, created_at
+        SELECT ` + courseColumns + `
         FROM courses
         WHERE course_name ILIKE '%' || $1 || '%'
         OR course_code ILIKE '%' || $1 || '%'
-        OR instructor ILIKE '%' || $1 || '%'
+        OR EXISTS (SELECT 1
+                   FROM course_instructors ci
+                   JOIN instructors i ON i.id = ci.instructor_id
+                   WHERE ci.course_id = courses.id AND i.name ILIKE '%' || $1 || '%')
         ORDER BY course_code
     `
     
-    rows, err := db.DB.Query(query, keyword)
+    rows, err := db.query(ctx, query, keyword)
     if err != nil {
-        return nil, fmt.Errorf("failed to search courses: %w", err)
+        return nil, fmt.Errorf("failed to search courses: %w", queryError(ctx, err))
     }
     defer rows.Close()
     
     var courses []Course
     for rows.Next() {
         var course Course
-        err := rows.Scan(
-            &course.ID, &course.CourseCode, &course.CourseName, &course.CourseDescription,
-            &course.Credits, &course.Instructor, &course.Semester, &course.TimeSlot,
-            &course.CourseLocation, &course.CreatedAt,
-        )
+        err := rows.Scan(courseFields(&course)...)
         if err != nil {
-            return nil, fmt.Errorf("failed to scan course: %w", err)
+            return nil, fmt.Errorf("failed to scan course: %w", queryError(ctx, err))
         }
         courses = append(courses, course)
     }
@@ -124,13 +176,16 @@ func (db *Database) SearchCourses(keyword string) ([]Course, error) {
     return courses, nil
 }
 
-func (db *Database) CourseExists(courseID int) (bool, error) {
+func (db *Database) CourseExists(ctx context.Context, courseID int) (bool, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `SELECT COUNT(*) > 0 FROM courses WHERE id = $1`
     
     var exists bool
-    err := db.DB.QueryRow(query, courseID).Scan(&exists)
+    err := db.queryRow(ctx, query, courseID).Scan(&exists)
     if err != nil {
-        return false, fmt.Errorf("failed to check if course exists: %w", err)
+        return false, fmt.Errorf("failed to check if course exists: %w", queryError(ctx, err))
     }
     
     return exists, nil
@@ -1,15 +1,26 @@
 package models
 
 import (
+    "context"
     "database/sql"
+    "errors"
     "fmt"
-    "log"
+    "log/slog"
     "time"
+
+    "course-management/events"
+    "course-management/requestid"
+
+    "github.com/XSAM/otelsql"
     _ "github.com/lib/pq"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type Database struct {
     DB *sql.DB
+
+    queryTimeout time.Duration
+    bus          *events.Bus // 事务提交后发布选课变化
 }
 
 type Student struct {
@@ -29,14 +40,26 @@ type Course struct {
     Semester          string    `json:"semester"`
     TimeSlot          string    `json:"time_slot"`
     CourseLocation    string    `json:"course_location"`
+    Category          string    `json:"category"` // 课程类别（如 core、elective），用于培养方案的学分要求
+    RoomID            *int      `json:"room_id"`  // 关联的教室，为空时 CourseLocation 为自由填写的地点
+    Capacity          *int      `json:"capacity"` // 课程容量，为空表示不限人数
     CreatedAt         time.Time `json:"created_at"`
 }
 
+// 选课记录。退课等操作只修改状态，不删除记录，保留学生的选课历史
 type StudentCourse struct {
-    ID         int       `json:"id"`
-    StudentID  int       `json:"student_id"`
-    CourseID   int       `json:"course_id"`
-    EnrolledAt time.Time `json:"enrolled_at"`
+    ID         int        `json:"id"`
+    StudentID  int        `json:"student_id"`
+    CourseID   int        `json:"course_id"`
+    Status     string     `json:"status"`
+    EnrolledAt time.Time  `json:"enrolled_at"`
+    EndedAt    *time.Time `json:"ended_at,omitempty"` // 状态离开 enrolled 的时间
+    Sections   []string   `json:"sections,omitempty"` // 所在教学班，仅选课时填充
+
+    Grade       *string    `json:"grade,omitempty"`        // 等级成绩，P/F 课程为 P 或 F
+    GradePoints *float64   `json:"grade_points,omitempty"` // 绩点，P/F 课程为空
+    PassFail    bool       `json:"pass_fail"`
+    GradedAt    *time.Time `json:"graded_at,omitempty"`
 }
 
 type DBConfig struct {
@@ -46,6 +69,10 @@ type DBConfig struct {
     Password string
     DBName   string
     SSLMode  string
+
+    AutoMigrate  bool          // 启动时自动执行数据库迁移
+    QueryTimeout time.Duration // 单次查询的超时时间，0表示不限制
+    Tracing      bool          // 为每条SQL语句创建追踪span
 }
 
 // 连接数据库
@@ -54,7 +81,19 @@ func NewDatabase(config DBConfig) (*Database, error) {
         config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode)
     
     // 连接数据库并测试
-    db, err := sql.Open("postgres", connection)
+    var db *sql.DB
+    var err error
+    if config.Tracing {
+        db, err = otelsql.Open("postgres", connection,
+            otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+            otelsql.WithSpanOptions(otelsql.SpanOptions{
+                OmitConnResetSession: true,
+                OmitRows:             true,
+            }),
+        )
+    } else {
+        db, err = sql.Open("postgres", connection)
+    }
     if err != nil {
         return nil, fmt.Errorf("failed to open database: %w", err)
     }
@@ -67,13 +106,125 @@ func NewDatabase(config DBConfig) (*Database, error) {
     db.SetMaxIdleConns(25)
     db.SetConnMaxLifetime(5 * time.Minute)
     
-    log.Println("数据库连接成功")
+    slog.Info("数据库连接成功", "host", config.Host, "database", config.DBName)
     
-    database := &Database{DB: db}
+    database := &Database{DB: db, queryTimeout: config.QueryTimeout, bus: events.NewBus()}
     
     return database, nil
 }
 
+// ==================== 查询辅助方法 ====================
+// 所有业务查询都通过以下方法执行，以便统一附加请求上下文信息
+
+func (db *Database) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+    return db.DB.QueryContext(ctx, tagQuery(ctx, query), args...)
+}
+
+func (db *Database) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+    return db.DB.QueryRowContext(ctx, tagQuery(ctx, query), args...)
+}
+
+func (db *Database) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+    return db.DB.ExecContext(ctx, tagQuery(ctx, query), args...)
+}
+
+// 事务，提供与 Database 相同的查询辅助方法
+type txn struct {
+    *sql.Tx
+
+    pending *pendingEvents // 提交后发布的事件
+
+    // 只检查选课规则、最终回滚的事务（购物车校验）：读取时不加行锁，不记录审计，不发布事件，
+    // 避免校验阻塞其他学生的结算
+    validateOnly bool
+}
+
+// 锁定读取的行，只检查的事务不加锁
+func (tx txn) forUpdate() string {
+    if tx.validateOnly {
+        return ""
+    }
+    return "FOR UPDATE"
+}
+
+func (tx txn) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+    return tx.QueryContext(ctx, tagQuery(ctx, query), args...)
+}
+
+func (tx txn) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+    return tx.QueryRowContext(ctx, tagQuery(ctx, query), args...)
+}
+
+func (tx txn) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+    return tx.ExecContext(ctx, tagQuery(ctx, query), args...)
+}
+
+// 在事务中执行 fn，fn 返回错误时回滚，否则提交。提交成功后发布事务中产生的事件
+func (db *Database) inTx(ctx context.Context, fn func(tx txn) error) error {
+    pending, err := db.commitTx(ctx, fn)
+    if err != nil {
+        return err
+    }
+    db.publishPending(ctx, pending)
+    return nil
+}
+
+// 在事务中执行 fn 并提交，返回待发布的事件，由调用方在提交后发布
+func (db *Database) commitTx(ctx context.Context, fn func(tx txn) error) (*pendingEvents, error) {
+    tx, err := db.DB.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to begin transaction: %w", queryError(ctx, err))
+    }
+    defer tx.Rollback()
+
+    pending := &pendingEvents{}
+    if err := fn(txn{Tx: tx, pending: pending}); err != nil {
+        return nil, err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("failed to commit transaction: %w", queryError(ctx, err))
+    }
+    return pending, nil
+}
+
+// 为查询设置超时时间，调用方需在查询结果读取完毕后调用 cancel
+func (db *Database) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+    if db.queryTimeout <= 0 {
+        return context.WithCancel(ctx)
+    }
+    return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// 查询因上下文结束而失败时，转换为 ErrQueryTimeout 或 ErrQueryCanceled。
+// 驱动在取消查询时返回的是数据库错误而非 ctx.Err()，因此需要根据上下文状态判断。
+func queryError(ctx context.Context, err error) error {
+    if errors.Is(err, ErrQueryTimeout) || errors.Is(err, ErrQueryCanceled) {
+        return err
+    }
+
+    switch ctx.Err() {
+    case context.DeadlineExceeded:
+        return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+    case context.Canceled:
+        return fmt.Errorf("%w: %w", ErrQueryCanceled, err)
+    }
+    return err
+}
+
+// 为SQL语句附加请求ID注释，便于在数据库慢查询日志和 pg_stat_activity 中关联到具体请求
+func tagQuery(ctx context.Context, query string) string {
+    if id := requestid.FromContext(ctx); id != "" {
+        return "/* request_id=" + id + " */ " + query
+    }
+    return query
+}
+
+// 检查数据库连接是否可用
+func (db *Database) Ping(ctx context.Context) error {
+    return db.DB.PingContext(ctx)
+}
+
 func (db *Database) Close() error {
     return db.DB.Close()
 }
\ No newline at end of file
@@ -1,134 +1,482 @@
 package models
 
 import (
+    "context"
+    "database/sql"
     "fmt"
+    "slices"
+    "strings"
+    "time"
+
+    "course-management/events"
+
+    "github.com/lib/pq"
+)
+
+// 选课状态。只有 enrolled 表示当前在读，其余均为历史记录
+const (
+    EnrollmentEnrolled       = "enrolled"
+    EnrollmentDropped        = "dropped"          // 学生自行退课
+    EnrollmentWithdrawn      = "withdrawn"        // 退选期结束后退出课程
+    EnrollmentRemovedByAdmin = "removed_by_admin" // 管理员批量移除（如课程取消）
+    EnrollmentMoved          = "moved"            // 管理员将学生转到其他课程或教学班，转入后有新的选课记录
+    EnrollmentCompleted      = "completed"        // 已修完课程
+)
+
+// 每学期在读课程的学分上限
+const maxSemesterCredits = 20
+
+// 审批通过的选课申请可豁免的选课规则
+const (
+    OverrideCapacity           = "capacity"            // 课程或教学班已满
+    OverridePrerequisites      = "prerequisites"       // 未修完先修课程
+    OverrideRegistrationWindow = "registration_window" // 学期的选课轮次尚未分配
 )
 
-func (db *Database) GetStudentCourses(studentID int) ([]Course, error) {
+// 学生的一条选课记录及对应的课程信息
+type EnrolledCourse struct {
+    Course
+    Status     string
+    Sections   []string // 所在教学班的代码
+    EnrolledAt time.Time
+    EndedAt    *time.Time
+}
+
+// 选课记录的查询列及扫描目标，与 StudentCourse 字段一一对应
+const enrollmentColumns = `id, student_id, course_id, status, enrolled_at, ended_at,
+    grade, grade_points, pass_fail, graded_at`
+
+func enrollmentFields(e *StudentCourse) []any {
+    return []any{
+        &e.ID, &e.StudentID, &e.CourseID, &e.Status, &e.EnrolledAt, &e.EndedAt,
+        &e.Grade, &e.GradePoints, &e.PassFail, &e.GradedAt,
+    }
+}
+
+// 获取学生的选课。默认只返回当前在读的课程，includeHistory 为 true 时包含退课、完成等历史记录
+func (db *Database) GetStudentCourses(ctx context.Context, studentID int, includeHistory bool) ([]EnrolledCourse, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
         SELECT c.id, c.course_code, c.course_name, c.course_description,
-               c.credits, c.instructor, c.semester, c.time_slot, c.course_location, c.created_at
+               c.credits, c.instructor, c.semester, c.time_slot, c.course_location, c.category, c.created_at,
+               sc.status, sc.enrolled_at, sc.ended_at,
+               ARRAY(SELECT cs.section_code
+                     FROM enrollment_sections es
+                     JOIN course_sections cs ON cs.id = es.section_id
+                     WHERE es.enrollment_id = sc.id
+                     ORDER BY cs.section_type, cs.section_code)
         FROM courses c
         JOIN student_courses sc ON c.id = sc.course_id
-        WHERE sc.student_id = $1
-        ORDER BY c.course_code, c.semester
+        WHERE sc.student_id = $1 AND ($2 OR sc.status = 'enrolled')
+        ORDER BY c.course_code, c.semester, sc.enrolled_at
     `
     
-    rows, err := db.DB.Query(query, studentID)
+    rows, err := db.query(ctx, query, studentID, includeHistory)
     if err != nil {
-        return nil, fmt.Errorf("failed to query student courses: %w", err)
+        return nil, fmt.Errorf("failed to query student courses: %w", queryError(ctx, err))
     }
     defer rows.Close()
     
-    var courses []Course
+    var courses []EnrolledCourse
     for rows.Next() {
-        var course Course
+        var course EnrolledCourse
         err := rows.Scan(
             &course.ID, &course.CourseCode, &course.CourseName, &course.CourseDescription,
             &course.Credits, &course.Instructor, &course.Semester, &course.TimeSlot,
-            &course.CourseLocation, &course.CreatedAt,
+            &course.CourseLocation, &course.Category, &course.CreatedAt,
+            &course.Status, &course.EnrolledAt, &course.EndedAt, pq.Array(&course.Sections),
         )
         if err != nil {
-            return nil, fmt.Errorf("failed to scan student course: %w", err)
+            return nil, fmt.Errorf("failed to scan student course: %w", queryError(ctx, err))
         }
         courses = append(courses, course)
     }
     
     if err = rows.Err(); err != nil {
-        return nil, fmt.Errorf("rows iteration error: %w", err)
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
     }
     
     return courses, nil
 }
 
-func (db *Database) EnrollStudentInCourse(studentID, courseID int) error {
-    // 首先检查学生和课程是否存在
-    studentExists, err := db.StudentExists(studentID)
+// 学生选课。课程设置了教学班时，sectionIDs 为选择的教学班，每种类型恰好一个（只有一个可选时可省略）。
+// 选课须满足 enroll 中的选课规则
+func (db *Database) EnrollStudentInCourse(ctx context.Context, studentID, courseID int, sectionIDs []int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
+    return db.inTx(ctx, func(tx txn) error {
+        if err := tx.lockStudent(ctx, studentID); err != nil {
+            return err
+        }
+        _, err := tx.enroll(ctx, studentID, courseID, sectionIDs)
+        return err
+    })
+}
+
+// 锁定学生，同一学生的选课串行执行，学分和时间冲突检查不会被并发的选课绕过
+func (tx txn) lockStudent(ctx context.Context, studentID int) error {
+    var id int
+    err := tx.queryRow(ctx, `SELECT id FROM students WHERE id = $1 `+tx.forUpdate(), studentID).Scan(&id)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("%w (ID %d)", ErrStudentNotFound, studentID)
+    }
     if err != nil {
-        return fmt.Errorf("failed to check student existence: %w", err)
+        return fmt.Errorf("failed to lock student: %w", queryError(ctx, err))
     }
-    if !studentExists {
-        return fmt.Errorf("student with ID %d does not exist", studentID)
+    return nil
+}
+
+// 在事务中为学生选课。选课规则：课程所在学期没有未分配的选课轮次；未在读该课程；教学班选择有效；
+// 课程和教学班未满；已修完先修课程；同学期学分不超过上限；与同学期在读课程的上课时间不冲突。调用方须先锁定学生
+func (tx txn) enroll(ctx context.Context, studentID, courseID int, sectionIDs []int) (*StudentCourse, error) {
+    return tx.enrollWithOverrides(ctx, studentID, courseID, sectionIDs, nil)
+}
+
+// 按选课规则选课，但跳过 overrides 中列出的规则（见 Override 常量）
+func (tx txn) enrollWithOverrides(ctx context.Context, studentID, courseID int, sectionIDs []int, overrides []string) (*StudentCourse, error) {
+    var course Course
+    err := tx.queryRow(ctx, `SELECT `+courseColumns+` FROM courses WHERE id = $1 `+tx.forUpdate(), courseID).Scan(courseFields(&course)...)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, courseID)
     }
-    
-    courseExists, err := db.CourseExists(courseID)
     if err != nil {
-        return fmt.Errorf("failed to check course existence: %w", err)
+        return nil, fmt.Errorf("failed to lock course: %w", queryError(ctx, err))
     }
-    if !courseExists {
-        return fmt.Errorf("course with ID %d does not exist", courseID)
+    if !slices.Contains(overrides, OverrideRegistrationWindow) {
+        if err := tx.checkRoundPending(ctx, course); err != nil {
+            return nil, err
+        }
     }
     
-    // 检查是否已经选过这门课
-    enrolled, err := db.isStudentEnrolled(studentID, courseID)
+    var enrolled bool
+    err = tx.queryRow(ctx, `
+        SELECT COUNT(*) > 0
+        FROM student_courses
+        WHERE student_id = $1 AND course_id = $2 AND status = 'enrolled'
+    `, studentID, courseID).Scan(&enrolled)
     if err != nil {
-        return fmt.Errorf("failed to check enrollment status: %w", err)
+        return nil, fmt.Errorf("failed to check enrollment: %w", queryError(ctx, err))
     }
     if enrolled {
-        return fmt.Errorf("student is already enrolled in this course")
+        return nil, fmt.Errorf("%w (%s)", ErrAlreadyEnrolled, course.CourseCode)
+    }
+    
+    ignoreCapacity := slices.Contains(overrides, OverrideCapacity)
+    sections, err := tx.reserveSections(ctx, courseID, sectionIDs, ignoreCapacity)
+    if err != nil {
+        return nil, err
+    }
+    
+    if course.Capacity != nil && !ignoreCapacity {
+        var count int
+        err := tx.queryRow(ctx, `SELECT COUNT(*) FROM student_courses WHERE course_id = $1 AND status = 'enrolled'`,
+            courseID).Scan(&count)
+        if err != nil {
+            return nil, fmt.Errorf("failed to count course enrollments: %w", queryError(ctx, err))
+        }
+        if count >= *course.Capacity {
+            return nil, fmt.Errorf("%w (%s, %d seats)", ErrCourseFull, course.CourseCode, *course.Capacity)
+        }
+    }
+    
+    if !slices.Contains(overrides, OverridePrerequisites) {
+        if err := tx.checkPrerequisites(ctx, studentID, course); err != nil {
+            return nil, err
+        }
+    }
+    if err := tx.checkSemesterLoad(ctx, studentID, course, sections); err != nil {
+        return nil, err
     }
     
-    // 执行选课
     query := `
         INSERT INTO student_courses (student_id, course_id)
         VALUES ($1, $2)
+        RETURNING ` + enrollmentColumns
+    
+    var enrollment StudentCourse
+    err = tx.queryRow(ctx, query, studentID, courseID).Scan(enrollmentFields(&enrollment)...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to enroll student in course: %w", queryError(ctx, err))
+    }
+    
+    if err := tx.attachSections(ctx, enrollment.ID, sections); err != nil {
+        return nil, err
+    }
+    enrollment.Sections = sectionCodes(sections)
+    
+    // 未经递补选上课程时结束该课程的候补（递补时已先标记为 promoted），只检查的事务不写入
+    if !tx.validateOnly {
+        if _, err := tx.endWaitlistEntries(ctx, studentID, courseID, WaitlistEnrolledDirectly, ""); err != nil {
+            return nil, err
+        }
+    }
+    
+    if err := tx.recordAudit(ctx, AuditEnrolled, studentID, courseID, nil, enrollment); err != nil {
+        return nil, err
+    }
+    tx.publishEnrollment(events.Enrolled, enrollment)
+    return &enrollment, nil
+}
+
+// 检查学生是否已修完并通过课程的所有先修课程
+func (tx txn) checkPrerequisites(ctx context.Context, studentID int, course Course) error {
+    query := `
+        SELECT p.prerequisite_code
+        FROM course_prerequisites p
+        WHERE p.course_id = $1
+          AND NOT EXISTS (
+              SELECT 1
+              FROM student_courses sc
+              JOIN courses c ON c.id = sc.course_id
+              WHERE sc.student_id = $2 AND c.course_code = p.prerequisite_code
+                AND sc.status = 'completed' AND sc.grade IS DISTINCT FROM 'F')
+        ORDER BY p.prerequisite_code
     `
     
-    _, err = db.DB.Exec(query, studentID, courseID)
+    rows, err := tx.query(ctx, query, course.ID, studentID)
     if err != nil {
-        return fmt.Errorf("failed to enroll student in course: %w", err)
+        return fmt.Errorf("failed to check prerequisites: %w", queryError(ctx, err))
     }
+    defer rows.Close()
     
+    var missing []string
+    for rows.Next() {
+        var code string
+        if err := rows.Scan(&code); err != nil {
+            return fmt.Errorf("failed to scan prerequisite: %w", queryError(ctx, err))
+        }
+        missing = append(missing, code)
+    }
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+    
+    if len(missing) > 0 {
+        return fmt.Errorf("%w: %s requires %s", ErrPrerequisitesNotMet, course.CourseCode, strings.Join(missing, ", "))
+    }
     return nil
 }
 
-func (db *Database) UnenrollStudentFromCourse(studentID, courseID int) error {
+// 检查选课后同学期的学分不超过上限，且上课时间与同学期在读的课程（含所在教学班）不冲突。
+// 无法解析的上课时间不参与冲突检查
+func (tx txn) checkSemesterLoad(ctx context.Context, studentID int, course Course, sections []Section) error {
     query := `
-        DELETE FROM student_courses
-        WHERE student_id = $1 AND course_id = $2
+        SELECT c.course_code, c.credits, COALESCE(c.time_slot, ''),
+               ARRAY(SELECT COALESCE(cs.time_slot, '')
+                     FROM enrollment_sections es
+                     JOIN course_sections cs ON cs.id = es.section_id
+                     WHERE es.enrollment_id = sc.id)
+        FROM student_courses sc
+        JOIN courses c ON c.id = sc.course_id
+        WHERE sc.student_id = $1 AND sc.status = 'enrolled' AND COALESCE(c.semester, '') = $2
+        ORDER BY c.course_code
     `
     
-    result, err := db.DB.Exec(query, studentID, courseID)
-    if err != nil {
-        return fmt.Errorf("failed to unenroll student from course: %w", err)
+    var meetings []meeting
+    for _, slot := range append([]string{course.TimeSlot}, sectionTimeSlots(sections)...) {
+        if m, err := parseTimeSlot(slot); err == nil {
+            meetings = append(meetings, m...)
+        }
     }
     
-    rowsAffected, err := result.RowsAffected()
+    rows, err := tx.query(ctx, query, studentID, course.Semester)
     if err != nil {
-        return fmt.Errorf("failed to get rows affected: %w", err)
+        return fmt.Errorf("failed to query semester courses: %w", queryError(ctx, err))
     }
+    defer rows.Close()
     
-    if rowsAffected == 0 {
-        return fmt.Errorf("student is not enrolled in this course")
+    credits := course.Credits
+    for rows.Next() {
+        var code, timeSlot string
+        var courseCredits int
+        var sectionSlots []string
+        if err := rows.Scan(&code, &courseCredits, &timeSlot, pq.Array(&sectionSlots)); err != nil {
+            return fmt.Errorf("failed to scan semester course: %w", queryError(ctx, err))
+        }
+        credits += courseCredits
+        
+        for _, slot := range append([]string{timeSlot}, sectionSlots...) {
+            other, err := parseTimeSlot(slot)
+            if err != nil {
+                continue
+            }
+            if meetingsOverlap(meetings, other) {
+                return fmt.Errorf("%w: %s clashes with %s at %s", ErrTimeClash, course.CourseCode, code, slot)
+            }
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
     }
     
+    if credits > maxSemesterCredits {
+        return fmt.Errorf("%w: %d credits in %s, limit is %d", ErrCreditLimit, credits, course.Semester, maxSemesterCredits)
+    }
+    return nil
+}
+
+// 学生退课，选课记录标记为 dropped，释放的名额由候补学生递补
+func (db *Database) UnenrollStudentFromCourse(ctx context.Context, studentID, courseID int) error {
+    if err := db.endEnrollment(ctx, studentID, courseID, EnrollmentDropped, AuditDropped); err != nil {
+        return err
+    }
+    db.promoteWaitlists(ctx, courseID)
+    return nil
+}
+
+// 结束学生当前的选课 (管理员功能)，如标记为 withdrawn 或 completed。
+// 只有选课期间的退课和移除（dropped、removed_by_admin）由候补学生递补
+func (db *Database) SetEnrollmentStatus(ctx context.Context, studentID, courseID int, status string) error {
+    switch status {
+    case EnrollmentDropped, EnrollmentWithdrawn, EnrollmentRemovedByAdmin, EnrollmentCompleted:
+    default:
+        return fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+    }
+    if err := db.endEnrollment(ctx, studentID, courseID, status, AuditStatusChanged); err != nil {
+        return err
+    }
+    if status == EnrollmentDropped || status == EnrollmentRemovedByAdmin {
+        db.promoteWaitlists(ctx, courseID)
+    }
     return nil
 }
 
-func (db *Database) ClearCourseEnrollments(courseID int) error {
-    query := `DELETE FROM student_courses WHERE course_id = $1`
+// 将当前在读的选课记录改为 status，并在同一事务中记录审计事件
+func (db *Database) endEnrollment(ctx context.Context, studentID, courseID int, status, action string) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
     
-    _, err := db.DB.Exec(query, courseID)
+    return db.inTx(ctx, func(tx txn) error {
+        _, err := tx.endEnrollment(ctx, studentID, courseID, status, action)
+        return err
+    })
+}
+
+// 在事务中结束学生当前在读的选课并写入审计日志
+func (tx txn) endEnrollment(ctx context.Context, studentID, courseID int, status, action string) (*StudentCourse, error) {
+    query := `
+        UPDATE student_courses
+        SET status = $3, ended_at = CURRENT_TIMESTAMP
+        WHERE student_id = $1 AND course_id = $2 AND status = 'enrolled'
+        RETURNING ` + enrollmentColumns
+    
+    var enrollment StudentCourse
+    err := tx.queryRow(ctx, query, studentID, courseID, status).Scan(enrollmentFields(&enrollment)...)
+    if err == sql.ErrNoRows {
+        return nil, ErrNotEnrolled
+    }
     if err != nil {
-        return fmt.Errorf("failed to clear course enrollments: %w", err)
+        return nil, fmt.Errorf("failed to update enrollment status: %w", queryError(ctx, err))
     }
     
+    if err := tx.recordAudit(ctx, action, studentID, courseID, enrollment.previous(), enrollment); err != nil {
+        return nil, err
+    }
+    tx.publishEnrollment(enrollmentEventType(enrollment.Status), enrollment)
+    return &enrollment, nil
+}
+
+// 换课：在一个事务中退选 fromCourseID 并选择 toCourseID。新课程不满足任何选课规则时整体回滚，
+// 学生保留原课程。两者为同一课程时用于更换教学班。原课程（或原教学班）释放的名额由候补学生递补
+func (db *Database) SwapCourse(ctx context.Context, studentID, fromCourseID, toCourseID int, sectionIDs []int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
+    err := db.inTx(ctx, func(tx txn) error {
+        if err := tx.lockStudent(ctx, studentID); err != nil {
+            return err
+        }
+        if _, err := tx.endEnrollment(ctx, studentID, fromCourseID, EnrollmentDropped, AuditDropped); err != nil {
+            return err
+        }
+        _, err := tx.enroll(ctx, studentID, toCourseID, sectionIDs)
+        return err
+    })
+    if err != nil {
+        return err
+    }
+    
+    db.promoteWaitlists(ctx, fromCourseID)
     return nil
 }
 
-// 私有辅助方法，检查学生是否已选课
-func (db *Database) isStudentEnrolled(studentID, courseID int) (bool, error) {
+// 将所有学生从课程中移除，选课记录标记为 removed_by_admin 并逐条写入审计日志，课程的候补一并移除。返回移除的人数
+func (db *Database) ClearCourseEnrollments(ctx context.Context, courseID int) (int, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
-        SELECT COUNT(*) > 0
-        FROM student_courses
-        WHERE student_id = $1 AND course_id = $2
-    `
+        UPDATE student_courses
+        SET status = 'removed_by_admin', ended_at = CURRENT_TIMESTAMP
+        WHERE course_id = $1 AND status = 'enrolled'
+        RETURNING ` + enrollmentColumns
     
-    var enrolled bool
-    err := db.DB.QueryRow(query, studentID, courseID).Scan(&enrolled)
+    var removed []StudentCourse
+    err := db.inTx(ctx, func(tx txn) error {
+        rows, err := tx.query(ctx, query, courseID)
+        if err != nil {
+            return fmt.Errorf("failed to clear course enrollments: %w", queryError(ctx, err))
+        }
+        defer rows.Close()
+        
+        for rows.Next() {
+            var enrollment StudentCourse
+            if err := rows.Scan(enrollmentFields(&enrollment)...); err != nil {
+                return fmt.Errorf("failed to scan removed enrollment: %w", queryError(ctx, err))
+            }
+            removed = append(removed, enrollment)
+        }
+        if err := rows.Err(); err != nil {
+            return fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+        }
+        rows.Close()
+        
+        for _, enrollment := range removed {
+            err := tx.recordAudit(ctx, AuditRemovedByAdmin, enrollment.StudentID, courseID, enrollment.previous(), enrollment)
+            if err != nil {
+                return err
+            }
+            tx.publishEnrollment(events.RemovedByAdmin, enrollment)
+        }
+        
+        waitlist, err := tx.endWaitlistEntries(ctx, 0, courseID, WaitlistRemoved, "")
+        if err != nil {
+            return err
+        }
+        for _, entry := range waitlist {
+            if err := tx.recordAudit(ctx, AuditWaitlistRemoved, entry.StudentID, courseID, entry.previous(), entry); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
     if err != nil {
-        return false, fmt.Errorf("failed to check enrollment: %w", err)
+        return 0, err
     }
     
-    return enrolled, nil
+    return len(removed), nil
+}
+
+// 选课记录结束时推送给学生的事件类型
+func enrollmentEventType(status string) string {
+    switch status {
+    case EnrollmentDropped:
+        return events.Dropped
+    case EnrollmentRemovedByAdmin:
+        return events.RemovedByAdmin
+    case EnrollmentMoved:
+        return events.Moved
+    default:
+        return events.StatusChanged
+    }
+}
+
+// 状态变更前的选课记录，用于审计日志
+func (e StudentCourse) previous() StudentCourse {
+    e.Status = EnrollmentEnrolled
+    e.EndedAt = nil
+    return e
 }
\ No newline at end of file
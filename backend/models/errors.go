@@ -0,0 +1,72 @@
+package models
+
+import "errors"
+
+// 业务错误，调用方可通过 errors.Is 判断失败原因
+var (
+    ErrStudentNotFound = errors.New("student does not exist")
+    ErrCourseNotFound  = errors.New("course does not exist")
+    ErrAlreadyEnrolled = errors.New("student is already enrolled in this course")
+    ErrNotEnrolled     = errors.New("student is not enrolled in this course")
+    ErrInvalidStatus   = errors.New("invalid enrollment status")
+    ErrInvalidGrade    = errors.New("invalid grade")
+
+    ErrProgrammeNotFound  = errors.New("programme does not exist")
+    ErrDuplicateProgramme = errors.New("programme code already exists")
+    ErrAlreadyDeclared    = errors.New("student has already declared this programme")
+    ErrNotDeclared        = errors.New("student has not declared this programme")
+
+    ErrInvalidSections  = errors.New("invalid section selection")
+    ErrSectionFull      = errors.New("section is full")
+    ErrDuplicateSection = errors.New("section code already exists for this course")
+
+    ErrInstructorNotFound  = errors.New("instructor does not exist")
+    ErrDuplicateInstructor = errors.New("instructor already exists")
+    ErrAlreadyAssigned     = errors.New("instructor already teaches this course")
+    ErrNotAssigned         = errors.New("instructor does not teach this course")
+
+    ErrRoomNotFound     = errors.New("room does not exist")
+    ErrDuplicateRoom    = errors.New("room already exists")
+    ErrRoomTooSmall     = errors.New("room capacity is smaller than course capacity")
+    ErrRoomDoubleBooked = errors.New("room is already booked at this time")
+    ErrInvalidTimeSlot  = errors.New("invalid time slot")
+
+    ErrTimetableInfeasible = errors.New("no conflict-free timetable exists")
+    ErrTimetableChanged    = errors.New("timetable proposal has changed since preview")
+
+    ErrMixedSemesters  = errors.New("planned courses must be in the same semester")
+    ErrInvalidSemester = errors.New("invalid semester")
+
+    ErrCourseFull           = errors.New("course is full")
+    ErrTimeClash            = errors.New("course time clashes with an enrolled course")
+    ErrCreditLimit          = errors.New("semester credit limit exceeded")
+    ErrPrerequisitesNotMet  = errors.New("course prerequisites not met")
+    ErrInvalidPrerequisites = errors.New("invalid prerequisites")
+    ErrCartEmpty            = errors.New("cart is empty")
+    ErrNotInCart            = errors.New("course is not in the cart")
+
+    ErrRoundNotFound      = errors.New("registration round does not exist")
+    ErrDuplicateRound     = errors.New("semester already has an unallocated registration round")
+    ErrRegistrationClosed = errors.New("registration round is not accepting preferences")
+    ErrRoundNotClosed     = errors.New("registration round has not closed yet")
+    ErrRoundAllocated     = errors.New("registration round has already been allocated")
+    ErrRoundNotAllocating = errors.New("registration round allocation has not been started")
+    ErrInvalidPreferences = errors.New("invalid preferences")
+    ErrRoundPending       = errors.New("course is allocated by a registration round")
+
+    ErrPetitionNotFound  = errors.New("petition does not exist")
+    ErrPetitionNotNeeded = errors.New("student can enroll without a petition")
+    ErrNotPetitionable   = errors.New("enrollment rule cannot be overridden by petition")
+    ErrDuplicatePetition = errors.New("student already has a pending petition for this course")
+    ErrPetitionReviewed  = errors.New("petition has already been reviewed")
+
+    ErrCourseNotFull     = errors.New("course has available seats")
+    ErrAlreadyWaitlisted = errors.New("student is already on the waitlist for this course")
+    ErrNotWaitlisted     = errors.New("student is not on the waitlist for this course")
+)
+
+// 查询被中断的错误：超时（含上游截止时间）或调用方取消（如客户端断开连接）
+var (
+    ErrQueryTimeout  = errors.New("database query timed out")
+    ErrQueryCanceled = errors.New("database query canceled")
+)
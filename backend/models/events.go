@@ -0,0 +1,189 @@
+package models
+
+import (
+    "context"
+    "fmt"
+    "slices"
+    "time"
+
+    "course-management/events"
+    "course-management/logging"
+
+    "github.com/lib/pq"
+)
+
+// 事务中产生、待提交后发布的事件。courses 为在读人数可能变化的课程
+type pendingEvents struct {
+    events  []events.Event
+    courses []int
+    marks   map[string][]pendingMark
+}
+
+// 保存点创建时 pendingEvents 的长度，回滚到保存点时据此丢弃之后产生的事件
+type pendingMark struct {
+    events  int
+    courses int
+}
+
+// 获取事件总线，用于订阅选课变化
+func (db *Database) Events() *events.Bus {
+    return db.bus
+}
+
+// 记录学生的选课变化，事务提交后发布个人事件和课程人数变化
+func (tx txn) publishEnrollment(eventType string, enrollment StudentCourse) {
+    if tx.validateOnly {
+        return
+    }
+    tx.pending.events = append(tx.pending.events, events.Event{
+        Type:      eventType,
+        CourseID:  enrollment.CourseID,
+        StudentID: enrollment.StudentID,
+        Status:    enrollment.Status,
+    })
+    tx.publishSeats(enrollment.CourseID)
+}
+
+// 记录课程人数或容量的变化，事务提交后按最新数据发布
+func (tx txn) publishSeats(courseID int) {
+    if tx.validateOnly {
+        return
+    }
+    if !slices.Contains(tx.pending.courses, courseID) {
+        tx.pending.courses = append(tx.pending.courses, courseID)
+    }
+}
+
+// 创建保存点。通过保存点撤销的部分操作不会发布事件，因此事务中应使用以下方法而非直接执行 SAVEPOINT 语句
+func (tx txn) savepoint(ctx context.Context, name string) error {
+    if _, err := tx.exec(ctx, `SAVEPOINT `+name); err != nil {
+        return fmt.Errorf("failed to create savepoint: %w", queryError(ctx, err))
+    }
+    if tx.pending.marks == nil {
+        tx.pending.marks = make(map[string][]pendingMark)
+    }
+    tx.pending.marks[name] = append(tx.pending.marks[name], pendingMark{
+        events:  len(tx.pending.events),
+        courses: len(tx.pending.courses),
+    })
+    return nil
+}
+
+func (tx txn) releaseSavepoint(ctx context.Context, name string) error {
+    if _, err := tx.exec(ctx, `RELEASE SAVEPOINT `+name); err != nil {
+        return fmt.Errorf("failed to release savepoint: %w", queryError(ctx, err))
+    }
+    if marks := tx.pending.marks[name]; len(marks) > 0 {
+        tx.pending.marks[name] = marks[:len(marks)-1]
+    }
+    return nil
+}
+
+// 回滚到保存点并丢弃之后产生的事件。与 PostgreSQL 一致，回滚后保存点仍然存在
+func (tx txn) rollbackToSavepoint(ctx context.Context, name string) error {
+    if _, err := tx.exec(ctx, `ROLLBACK TO SAVEPOINT `+name); err != nil {
+        return fmt.Errorf("failed to roll back savepoint: %w", queryError(ctx, err))
+    }
+    if marks := tx.pending.marks[name]; len(marks) > 0 {
+        mark := marks[len(marks)-1]
+        tx.pending.events = tx.pending.events[:mark.events]
+        tx.pending.courses = tx.pending.courses[:mark.courses]
+    }
+    return nil
+}
+
+// 事务提交后发布事件。人数在提交后重新查询，每个事件都是当时的最新人数，
+// 并发提交时客户端可能短暂收到较旧的人数，下一次变化会更正
+func (db *Database) publishPending(ctx context.Context, pending *pendingEvents) {
+    if db.bus == nil || (len(pending.events) == 0 && len(pending.courses) == 0) {
+        return
+    }
+
+    now := time.Now().UTC()
+    published := pending.events
+    for i := range published {
+        published[i].At = now
+    }
+
+    // 请求可能已接近超时，人数查询不受请求上下文取消的影响
+    ctx, cancel := db.withTimeout(context.WithoutCancel(ctx))
+    defer cancel()
+
+    seats, err := db.GetCourseSeats(ctx, pending.courses)
+    if err != nil {
+        logging.FromContext(ctx).Warn("查询课程人数失败，未推送人数变化", "course_ids", pending.courses, "error", err)
+    }
+    for _, s := range seats {
+        published = append(published, s.event(now))
+    }
+
+    db.bus.Publish(published...)
+}
+
+// 课程的在读人数和容量
+type CourseSeats struct {
+    CourseID      int
+    EnrolledCount int
+    Capacity      *int // 为空表示不限人数
+}
+
+// 剩余名额，课程不限人数时为空
+func (s CourseSeats) AvailableSeats() *int {
+    if s.Capacity == nil {
+        return nil
+    }
+    available := max(*s.Capacity-s.EnrolledCount, 0)
+    return &available
+}
+
+func (s CourseSeats) event(at time.Time) events.Event {
+    enrolled := s.EnrolledCount
+    return events.Event{
+        Type:           events.SeatsChanged,
+        CourseID:       s.CourseID,
+        EnrolledCount:  &enrolled,
+        Capacity:       s.Capacity,
+        AvailableSeats: s.AvailableSeats(),
+        At:             at,
+    }
+}
+
+// 人数变化事件，用于客户端连接时推送订阅课程的当前人数
+func (s CourseSeats) Event() events.Event {
+    return s.event(time.Now().UTC())
+}
+
+// 查询课程的在读人数和容量，不存在的课程不返回
+func (db *Database) GetCourseSeats(ctx context.Context, courseIDs []int) ([]CourseSeats, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT c.id, c.capacity,
+               (SELECT COUNT(*) FROM student_courses sc WHERE sc.course_id = c.id AND sc.status = 'enrolled')
+        FROM courses c
+        WHERE c.id = ANY($1)
+        ORDER BY c.id
+    `
+
+    rows, err := db.query(ctx, query, pq.Array(courseIDs))
+    if err != nil {
+        return nil, fmt.Errorf("failed to query course seats: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    seats := []CourseSeats{}
+    for rows.Next() {
+        var s CourseSeats
+        if err := rows.Scan(&s.CourseID, &s.Capacity, &s.EnrolledCount); err != nil {
+            return nil, fmt.Errorf("failed to scan course seats: %w", queryError(ctx, err))
+        }
+        seats = append(seats, s)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return seats, nil
+}
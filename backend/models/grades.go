@@ -0,0 +1,207 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "math"
+
+    "course-management/events"
+)
+
+// 等级成绩对应的绩点（港大 4.3 分制）
+var gradePoints = map[string]float64{
+    "A+": 4.3, "A": 4.0, "A-": 3.7,
+    "B+": 3.3, "B": 3.0, "B-": 2.7,
+    "C+": 2.3, "C": 2.0, "C-": 1.7,
+    "D+": 1.3, "D": 1.0,
+    "F": 0,
+}
+
+// P/F 课程的成绩，不计入 GPA
+const (
+    GradePass = "P"
+    GradeFail = "F"
+)
+
+// 录入或修改学生最近一次修读该课程的成绩，选课状态同时变为 completed。
+// 返回更新后的选课记录，以及本次是否为修改已有成绩。
+func (db *Database) RecordGrade(ctx context.Context, studentID, courseID int, grade string, passFail bool) (*StudentCourse, bool, error) {
+    var points *float64
+    if passFail {
+        if grade != GradePass && grade != GradeFail {
+            return nil, false, fmt.Errorf("%w: %q (pass/fail course)", ErrInvalidGrade, grade)
+        }
+    } else {
+        value, ok := gradePoints[grade]
+        if !ok {
+            return nil, false, fmt.Errorf("%w: %q", ErrInvalidGrade, grade)
+        }
+        points = &value
+    }
+
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    // 重修时同一门课有多条记录，成绩记在最近一次在读或已完成的记录上
+    selectQuery := `
+        SELECT ` + enrollmentColumns + `
+        FROM student_courses
+        WHERE student_id = $1 AND course_id = $2 AND status IN ('enrolled', 'completed')
+        ORDER BY enrolled_at DESC, id DESC
+        LIMIT 1
+        FOR UPDATE
+    `
+
+    updateQuery := `
+        UPDATE student_courses
+        SET grade = $2, grade_points = $3, pass_fail = $4, graded_at = CURRENT_TIMESTAMP,
+            status = 'completed', ended_at = COALESCE(ended_at, CURRENT_TIMESTAMP)
+        WHERE id = $1
+        RETURNING ` + enrollmentColumns
+
+    var before, after StudentCourse
+    err := db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, selectQuery, studentID, courseID).Scan(enrollmentFields(&before)...)
+        if err == sql.ErrNoRows {
+            return ErrNotEnrolled
+        }
+        if err != nil {
+            return fmt.Errorf("failed to get enrollment: %w", queryError(ctx, err))
+        }
+
+        err = tx.queryRow(ctx, updateQuery, before.ID, grade, points, passFail).Scan(enrollmentFields(&after)...)
+        if err != nil {
+            return fmt.Errorf("failed to record grade: %w", queryError(ctx, err))
+        }
+
+        action := AuditGradeRecorded
+        if before.Grade != nil {
+            action = AuditGradeAmended
+        }
+        if err := tx.recordAudit(ctx, action, studentID, courseID, before, after); err != nil {
+            return err
+        }
+        if before.Status == EnrollmentEnrolled {
+            tx.publishEnrollment(events.StatusChanged, after)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, false, err
+    }
+
+    return &after, before.Grade != nil, nil
+}
+
+// 成绩单中的一门课程
+type TranscriptEntry struct {
+    CourseID    int
+    CourseCode  string
+    CourseName  string
+    Credits     int
+    Semester    string
+    Status      string
+    Grade       *string
+    GradePoints *float64
+    PassFail    bool
+}
+
+// 一个学期的成绩及学期 GPA
+type TranscriptSemester struct {
+    Semester      string
+    Courses       []TranscriptEntry
+    GPA           *float64 // 没有计入 GPA 的课程时为空
+    GPACredits    int      // 计入 GPA 的学分
+    CreditsEarned int      // 已获得的学分（及格的等级成绩和 P）
+}
+
+// 学生成绩单：按学期分组，GPA 按课程学分加权
+type Transcript struct {
+    Semesters     []TranscriptSemester
+    GPA           *float64
+    GPACredits    int
+    CreditsEarned int
+}
+
+// 生成学生成绩单，包含已完成、在读和已退出（withdrawn）的课程，学期按首次选课时间排序
+func (db *Database) GetTranscript(ctx context.Context, studentID int) (*Transcript, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT c.id, c.course_code, c.course_name, COALESCE(c.credits, 0), COALESCE(c.semester, ''),
+               sc.status, sc.grade, sc.grade_points, sc.pass_fail
+        FROM student_courses sc
+        JOIN courses c ON c.id = sc.course_id
+        WHERE sc.student_id = $1 AND sc.status IN ('enrolled', 'completed', 'withdrawn')
+        ORDER BY sc.enrolled_at, c.course_code
+    `
+
+    rows, err := db.query(ctx, query, studentID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query transcript: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    transcript := &Transcript{}
+    semesterIndex := make(map[string]int)
+
+    for rows.Next() {
+        var entry TranscriptEntry
+        err := rows.Scan(
+            &entry.CourseID, &entry.CourseCode, &entry.CourseName, &entry.Credits, &entry.Semester,
+            &entry.Status, &entry.Grade, &entry.GradePoints, &entry.PassFail,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan transcript entry: %w", queryError(ctx, err))
+        }
+
+        i, ok := semesterIndex[entry.Semester]
+        if !ok {
+            i = len(transcript.Semesters)
+            semesterIndex[entry.Semester] = i
+            transcript.Semesters = append(transcript.Semesters, TranscriptSemester{Semester: entry.Semester})
+        }
+        transcript.Semesters[i].Courses = append(transcript.Semesters[i].Courses, entry)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    var totalPoints float64
+    for i := range transcript.Semesters {
+        semester := &transcript.Semesters[i]
+        var semesterPoints float64
+        for _, entry := range semester.Courses {
+            if entry.Status != EnrollmentCompleted || entry.Grade == nil {
+                continue
+            }
+            if *entry.Grade != GradeFail {
+                semester.CreditsEarned += entry.Credits
+            }
+            if !entry.PassFail && entry.GradePoints != nil {
+                semesterPoints += *entry.GradePoints * float64(entry.Credits)
+                semester.GPACredits += entry.Credits
+            }
+        }
+        semester.GPA = weightedGPA(semesterPoints, semester.GPACredits)
+
+        totalPoints += semesterPoints
+        transcript.GPACredits += semester.GPACredits
+        transcript.CreditsEarned += semester.CreditsEarned
+    }
+    transcript.GPA = weightedGPA(totalPoints, transcript.GPACredits)
+
+    return transcript, nil
+}
+
+// 学分加权的 GPA，保留两位小数
+func weightedGPA(points float64, credits int) *float64 {
+    if credits <= 0 {
+        return nil
+    }
+    gpa := math.Round(points/float64(credits)*100) / 100
+    return &gpa
+}
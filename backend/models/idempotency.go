@@ -0,0 +1,125 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+)
+
+// 幂等键记录。StatusCode 为 0 表示首个请求仍在处理中
+type IdempotencyRecord struct {
+    Key          string
+    Method       string
+    Path         string
+    RequestHash  string
+    StatusCode   int
+    ContentType  string
+    ResponseBody []byte
+    ExpiresAt    time.Time
+}
+
+// 尝试占用幂等键。占用成功返回 (nil, true)，调用方应继续处理请求；
+// 键已存在则返回已有记录和 false，由调用方决定重放响应还是拒绝请求。
+func (db *Database) ClaimIdempotencyKey(ctx context.Context, key, method, path, requestHash string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    // 过期的键视为不存在
+    _, err := db.exec(ctx, `
+        DELETE FROM idempotency_keys
+        WHERE idempotency_key = $1 AND method = $2 AND path = $3 AND expires_at < NOW()
+    `, key, method, path)
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to purge expired idempotency key: %w", queryError(ctx, err))
+    }
+
+    result, err := db.exec(ctx, `
+        INSERT INTO idempotency_keys (idempotency_key, method, path, request_hash, expires_at)
+        VALUES ($1, $2, $3, $4, NOW() + make_interval(secs => $5))
+        ON CONFLICT (idempotency_key, method, path) DO NOTHING
+    `, key, method, path, requestHash, ttl.Seconds())
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to claim idempotency key: %w", queryError(ctx, err))
+    }
+
+    inserted, err := result.RowsAffected()
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to get rows affected: %w", err)
+    }
+    if inserted > 0 {
+        return nil, true, nil
+    }
+
+    query := `
+        SELECT idempotency_key, method, path, request_hash,
+               status_code, content_type, response_body, expires_at
+        FROM idempotency_keys
+        WHERE idempotency_key = $1 AND method = $2 AND path = $3
+    `
+
+    var record IdempotencyRecord
+    var statusCode sql.NullInt64
+    var contentType sql.NullString
+    err = db.queryRow(ctx, query, key, method, path).Scan(
+        &record.Key, &record.Method, &record.Path, &record.RequestHash,
+        &statusCode, &contentType, &record.ResponseBody, &record.ExpiresAt,
+    )
+    if err != nil {
+        if err == sql.ErrNoRows {
+            // 与另一个请求的释放操作并发，交由客户端重试
+            return nil, false, fmt.Errorf("idempotency key was released concurrently")
+        }
+        return nil, false, fmt.Errorf("failed to get idempotency key: %w", queryError(ctx, err))
+    }
+    record.StatusCode = int(statusCode.Int64)
+    record.ContentType = contentType.String
+
+    return &record, false, nil
+}
+
+// 保存首个请求的响应，供后续重试重放
+func (db *Database) CompleteIdempotencyKey(ctx context.Context, key, method, path string, statusCode int, contentType string, body []byte) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    _, err := db.exec(ctx, `
+        UPDATE idempotency_keys
+        SET status_code = $4, content_type = $5, response_body = $6
+        WHERE idempotency_key = $1 AND method = $2 AND path = $3
+    `, key, method, path, statusCode, contentType, body)
+    if err != nil {
+        return fmt.Errorf("failed to save idempotent response: %w", queryError(ctx, err))
+    }
+
+    return nil
+}
+
+// 释放幂等键（如请求处理失败），允许客户端使用同一个键重试
+func (db *Database) ReleaseIdempotencyKey(ctx context.Context, key, method, path string) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    _, err := db.exec(ctx, `
+        DELETE FROM idempotency_keys
+        WHERE idempotency_key = $1 AND method = $2 AND path = $3
+    `, key, method, path)
+    if err != nil {
+        return fmt.Errorf("failed to release idempotency key: %w", queryError(ctx, err))
+    }
+
+    return nil
+}
+
+// 清理所有已过期的幂等键
+func (db *Database) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    result, err := db.exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+    if err != nil {
+        return 0, fmt.Errorf("failed to purge idempotency keys: %w", queryError(ctx, err))
+    }
+
+    return result.RowsAffected()
+}
@@ -0,0 +1,487 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// 教师。与课程为多对多关系，合开的课程可关联多名教师
+type Instructor struct {
+    ID          int       `json:"id"`
+    Name        string    `json:"name"`
+    Email       string    `json:"email"`
+    CourseCount int       `json:"course_count"`
+    Unavailable []string  `json:"unavailable,omitempty"` // 不可上课的时间，排课时避开
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// 课程名单中的一名学生。候补中的学生 Status 为 RosterWaitlisted，Sections 为申请的教学班，
+// EnrolledAt 为加入候补的时间，WaitlistPosition 为候补位次
+type RosterEntry struct {
+    StudentID        int
+    Username         string
+    Email            string
+    Status           string
+    Sections         []string
+    EnrolledAt       time.Time
+    EndedAt          *time.Time
+    WaitlistPosition *int
+}
+
+// 课程名单中候补学生的状态，与选课状态一起用于筛选
+const RosterWaitlisted = "waitlisted"
+
+// 课程名单的查询条件。Status 为空表示所有状态的选课记录及排队中的候补；Sort 为 RosterSortColumns 中的键；Limit 为 0 表示不分页
+type RosterQuery struct {
+    Status string
+    Sort   string
+    Desc   bool
+    Limit  int
+    Offset int
+}
+
+// 课程名单可用的排序字段
+var RosterSortColumns = map[string]string{
+    "name":              "s.username",
+    "email":             "s.email",
+    "enrolled_at":       "r.enrolled_at",
+    "student_id":        "s.id",
+    "waitlist_position": "r.waitlist_position",
+}
+
+// 课程的选课记录和排队中的候补，候补按加入顺序编号
+const rosterCTE = `
+    WITH roster AS (
+        SELECT sc.id AS enrollment_id, sc.student_id, sc.status, sc.enrolled_at, sc.ended_at,
+               NULL::INTEGER AS waitlist_position,
+               ARRAY(SELECT cs.section_code
+                     FROM enrollment_sections es
+                     JOIN course_sections cs ON cs.id = es.section_id
+                     WHERE es.enrollment_id = sc.id
+                     ORDER BY cs.section_type, cs.section_code) AS sections
+        FROM student_courses sc
+        WHERE sc.course_id = $1
+        UNION ALL
+        SELECT NULL, w.student_id, 'waitlisted', w.created_at, NULL,
+               ROW_NUMBER() OVER (ORDER BY w.id)::INTEGER,
+               ARRAY(SELECT cs.section_code
+                     FROM course_sections cs
+                     WHERE cs.id = ANY(w.section_ids)
+                     ORDER BY cs.section_type, cs.section_code)
+        FROM waitlist_entries w
+        WHERE w.course_id = $1 AND w.status = 'waiting'
+    )
+`
+
+// 根据 courses.instructor 文本建立教师及任课关系。文本中以逗号、斜杠、& 或顿号分隔的多个名字视为合开教师。
+// 用于迁移已有数据和插入示例数据，可重复执行
+const linkCourseInstructorsSQL = `
+    INSERT INTO instructors (name)
+    SELECT DISTINCT btrim(n)
+    FROM courses, regexp_split_to_table(COALESCE(instructor, ''), '\s*[,/&、]\s*') AS n
+    WHERE btrim(n) <> ''
+    ON CONFLICT (name) DO NOTHING;
+
+    INSERT INTO course_instructors (course_id, instructor_id)
+    SELECT c.id, i.id
+    FROM courses c
+    CROSS JOIN LATERAL regexp_split_to_table(COALESCE(c.instructor, ''), '\s*[,/&、]\s*') AS n
+    JOIN instructors i ON i.name = btrim(n)
+    ON CONFLICT DO NOTHING;
+`
+
+// 获取所有教师及其任教课程数
+func (db *Database) GetAllInstructors(ctx context.Context) ([]Instructor, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT i.id, i.name, COALESCE(i.email, ''), i.created_at,
+               (SELECT COUNT(*) FROM course_instructors ci WHERE ci.instructor_id = i.id)
+        FROM instructors i
+        ORDER BY i.name
+    `
+
+    rows, err := db.query(ctx, query)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query instructors: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    instructors := []Instructor{}
+    for rows.Next() {
+        var instructor Instructor
+        err := rows.Scan(&instructor.ID, &instructor.Name, &instructor.Email,
+            &instructor.CreatedAt, &instructor.CourseCount)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan instructor: %w", queryError(ctx, err))
+        }
+        instructors = append(instructors, instructor)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return instructors, nil
+}
+
+// 获取单个教师，不存在时返回 nil
+func (db *Database) GetInstructorByID(ctx context.Context, instructorID int) (*Instructor, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT i.id, i.name, COALESCE(i.email, ''), i.created_at,
+               (SELECT COUNT(*) FROM course_instructors ci WHERE ci.instructor_id = i.id),
+               ARRAY(SELECT u.time_slot FROM instructor_unavailability u WHERE u.instructor_id = i.id ORDER BY u.id)
+        FROM instructors i
+        WHERE i.id = $1
+    `
+
+    var instructor Instructor
+    err := db.queryRow(ctx, query, instructorID).Scan(&instructor.ID, &instructor.Name,
+        &instructor.Email, &instructor.CreatedAt, &instructor.CourseCount, pq.Array(&instructor.Unavailable))
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get instructor: %w", queryError(ctx, err))
+    }
+
+    return &instructor, nil
+}
+
+// 添加教师 (管理员功能)，姓名和邮箱不能与已有教师重复
+func (db *Database) AddInstructor(ctx context.Context, name, email string) (*Instructor, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        INSERT INTO instructors (name, email)
+        VALUES ($1, NULLIF($2, ''))
+        RETURNING id, name, COALESCE(email, ''), created_at
+    `
+
+    var instructor Instructor
+    err := db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, query, name, email).Scan(
+            &instructor.ID, &instructor.Name, &instructor.Email, &instructor.CreatedAt)
+        if err != nil {
+            var pqErr *pq.Error
+            if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+                return fmt.Errorf("%w (%s)", ErrDuplicateInstructor, name)
+            }
+            return fmt.Errorf("failed to add instructor: %w", queryError(ctx, err))
+        }
+
+        return tx.recordAudit(ctx, AuditInstructorCreated, 0, 0, nil, instructor)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &instructor, nil
+}
+
+// 获取教师任教的课程
+func (db *Database) GetInstructorCourses(ctx context.Context, instructorID int) ([]Course, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT c.id, c.course_code, c.course_name, c.course_description,
+               c.credits, c.instructor, c.semester, c.time_slot, c.course_location, c.category, c.created_at
+        FROM courses c
+        JOIN course_instructors ci ON ci.course_id = c.id
+        WHERE ci.instructor_id = $1
+        ORDER BY c.course_code, c.semester
+    `
+
+    rows, err := db.query(ctx, query, instructorID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query instructor courses: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    courses := []Course{}
+    for rows.Next() {
+        var course Course
+        err := rows.Scan(
+            &course.ID, &course.CourseCode, &course.CourseName, &course.CourseDescription,
+            &course.Credits, &course.Instructor, &course.Semester, &course.TimeSlot,
+            &course.CourseLocation, &course.Category, &course.CreatedAt,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan course: %w", queryError(ctx, err))
+        }
+        courses = append(courses, course)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return courses, nil
+}
+
+// 检查教师是否任教该课程
+func (db *Database) TeachesCourse(ctx context.Context, instructorID, courseID int) (bool, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `SELECT EXISTS(SELECT 1 FROM course_instructors WHERE instructor_id = $1 AND course_id = $2)`
+
+    var teaches bool
+    err := db.queryRow(ctx, query, instructorID, courseID).Scan(&teaches)
+    if err != nil {
+        return false, fmt.Errorf("failed to check course assignment: %w", queryError(ctx, err))
+    }
+
+    return teaches, nil
+}
+
+// 获取课程当前在读学生的名单及所在教学班，按姓名排序
+func (db *Database) GetCourseRoster(ctx context.Context, courseID int) ([]RosterEntry, error) {
+    roster, _, err := db.QueryCourseRoster(ctx, courseID, RosterQuery{Status: EnrollmentEnrolled, Sort: "name"})
+    return roster, err
+}
+
+// 按条件查询课程的选课名单，同时返回符合条件的总人数（不受分页影响）
+func (db *Database) QueryCourseRoster(ctx context.Context, courseID int, q RosterQuery) ([]RosterEntry, int, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    column, ok := RosterSortColumns[q.Sort]
+    if !ok {
+        column = RosterSortColumns["name"]
+    }
+    direction := "ASC"
+    if q.Desc {
+        direction = "DESC"
+    }
+
+    var total int
+    err := db.queryRow(ctx, rosterCTE+`
+        SELECT COUNT(*)
+        FROM roster r
+        WHERE $2 = '' OR r.status = $2
+    `, courseID, q.Status).Scan(&total)
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to count course roster: %w", queryError(ctx, err))
+    }
+
+    query := rosterCTE + `
+        SELECT s.id, s.username, s.email, r.status, r.enrolled_at, r.ended_at, r.waitlist_position, r.sections
+        FROM roster r
+        JOIN students s ON s.id = r.student_id
+        WHERE $2 = '' OR r.status = $2
+        ORDER BY ` + column + ` ` + direction + `, r.enrollment_id, r.waitlist_position
+        LIMIT $3 OFFSET $4
+    `
+
+    // LIMIT NULL 表示不限制
+    var limit any
+    if q.Limit > 0 {
+        limit = q.Limit
+    }
+
+    rows, err := db.query(ctx, query, courseID, q.Status, limit, q.Offset)
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to query course roster: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    roster := []RosterEntry{}
+    for rows.Next() {
+        var entry RosterEntry
+        err := rows.Scan(&entry.StudentID, &entry.Username, &entry.Email, &entry.Status, &entry.EnrolledAt,
+            &entry.EndedAt, &entry.WaitlistPosition, pq.Array(&entry.Sections))
+        if err != nil {
+            return nil, 0, fmt.Errorf("failed to scan roster entry: %w", queryError(ctx, err))
+        }
+        roster = append(roster, entry)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, 0, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return roster, total, nil
+}
+
+// 安排教师任教课程 (管理员功能)
+func (db *Database) AssignInstructor(ctx context.Context, courseID, instructorID int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    courseExists, err := db.CourseExists(ctx, courseID)
+    if err != nil {
+        return fmt.Errorf("failed to check course existence: %w", queryError(ctx, err))
+    }
+    if !courseExists {
+        return fmt.Errorf("%w (ID %d)", ErrCourseNotFound, courseID)
+    }
+
+    instructor, err := db.GetInstructorByID(ctx, instructorID)
+    if err != nil {
+        return err
+    }
+    if instructor == nil {
+        return fmt.Errorf("%w (ID %d)", ErrInstructorNotFound, instructorID)
+    }
+
+    query := `
+        INSERT INTO course_instructors (course_id, instructor_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `
+
+    return db.inTx(ctx, func(tx txn) error {
+        result, err := tx.exec(ctx, query, courseID, instructorID)
+        if err != nil {
+            return fmt.Errorf("failed to assign instructor: %w", queryError(ctx, err))
+        }
+        inserted, err := result.RowsAffected()
+        if err != nil {
+            return fmt.Errorf("failed to get rows affected: %w", err)
+        }
+        if inserted == 0 {
+            return ErrAlreadyAssigned
+        }
+
+        if err := tx.syncInstructorLabel(ctx, courseID); err != nil {
+            return err
+        }
+        return tx.recordAudit(ctx, AuditInstructorAssigned, 0, courseID, nil,
+            map[string]any{"instructor_id": instructor.ID, "instructor_name": instructor.Name})
+    })
+}
+
+// 取消教师的任课安排 (管理员功能)
+func (db *Database) UnassignInstructor(ctx context.Context, courseID, instructorID int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `DELETE FROM course_instructors WHERE course_id = $1 AND instructor_id = $2`
+
+    return db.inTx(ctx, func(tx txn) error {
+        result, err := tx.exec(ctx, query, courseID, instructorID)
+        if err != nil {
+            return fmt.Errorf("failed to unassign instructor: %w", queryError(ctx, err))
+        }
+        deleted, err := result.RowsAffected()
+        if err != nil {
+            return fmt.Errorf("failed to get rows affected: %w", err)
+        }
+        if deleted == 0 {
+            return ErrNotAssigned
+        }
+
+        if err := tx.syncInstructorLabel(ctx, courseID); err != nil {
+            return err
+        }
+        return tx.recordAudit(ctx, AuditInstructorUnassigned, 0, courseID,
+            map[string]any{"instructor_id": instructorID}, nil)
+    })
+}
+
+// 设置教师的不可用时间 (管理员功能)，替换原有设置。时间格式与课程上课时间相同
+func (db *Database) SetInstructorUnavailability(ctx context.Context, instructorID int, timeSlots []string) error {
+    for _, timeSlot := range timeSlots {
+        if _, err := parseTimeSlot(timeSlot); err != nil {
+            return err
+        }
+    }
+
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    instructor, err := db.GetInstructorByID(ctx, instructorID)
+    if err != nil {
+        return err
+    }
+    if instructor == nil {
+        return fmt.Errorf("%w (ID %d)", ErrInstructorNotFound, instructorID)
+    }
+
+    return db.inTx(ctx, func(tx txn) error {
+        if _, err := tx.exec(ctx, `DELETE FROM instructor_unavailability WHERE instructor_id = $1`, instructorID); err != nil {
+            return fmt.Errorf("failed to clear instructor unavailability: %w", queryError(ctx, err))
+        }
+        for _, timeSlot := range timeSlots {
+            _, err := tx.exec(ctx, `INSERT INTO instructor_unavailability (instructor_id, time_slot) VALUES ($1, $2)`,
+                instructorID, timeSlot)
+            if err != nil {
+                return fmt.Errorf("failed to add instructor unavailability: %w", queryError(ctx, err))
+            }
+        }
+
+        return tx.recordAudit(ctx, AuditInstructorAvailability, 0, 0,
+            map[string]any{"instructor_id": instructorID, "unavailable": instructor.Unavailable},
+            map[string]any{"instructor_id": instructorID, "unavailable": timeSlots})
+    })
+}
+
+// 按名字关联课程的教师，教师不存在时自动创建。用于添加课程时传入的教师姓名
+func (tx txn) linkInstructorsByName(ctx context.Context, courseID int, names string) error {
+    instructorQuery := `
+        INSERT INTO instructors (name) VALUES ($1)
+        ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+        RETURNING id
+    `
+    linkQuery := `
+        INSERT INTO course_instructors (course_id, instructor_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `
+
+    for _, name := range splitInstructorNames(names) {
+        var instructorID int
+        if err := tx.queryRow(ctx, instructorQuery, name).Scan(&instructorID); err != nil {
+            return fmt.Errorf("failed to upsert instructor: %w", queryError(ctx, err))
+        }
+        if _, err := tx.exec(ctx, linkQuery, courseID, instructorID); err != nil {
+            return fmt.Errorf("failed to link instructor: %w", queryError(ctx, err))
+        }
+    }
+    return nil
+}
+
+// 任课关系变化后更新 courses.instructor。该列仅用于展示，查询和搜索以 course_instructors 为准
+func (tx txn) syncInstructorLabel(ctx context.Context, courseID int) error {
+    query := `
+        UPDATE courses
+        SET instructor = (SELECT string_agg(i.name, ', ' ORDER BY i.name)
+                          FROM course_instructors ci
+                          JOIN instructors i ON i.id = ci.instructor_id
+                          WHERE ci.course_id = $1)
+        WHERE id = $1
+    `
+
+    if _, err := tx.exec(ctx, query, courseID); err != nil {
+        return fmt.Errorf("failed to update course instructor label: %w", queryError(ctx, err))
+    }
+    return nil
+}
+
+// 拆分合开课程的教师姓名，分隔规则与 linkCourseInstructorsSQL 一致
+func splitInstructorNames(names string) []string {
+    fields := strings.FieldsFunc(names, func(r rune) bool {
+        return r == ',' || r == '/' || r == '&' || r == '、'
+    })
+
+    var result []string
+    for _, name := range fields {
+        if name = strings.TrimSpace(name); name != "" {
+            result = append(result, name)
+        }
+    }
+    return result
+}
@@ -0,0 +1,489 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "log/slog"
+)
+
+// 数据库迁移：按版本号顺序执行，已执行的版本记录在 schema_migrations 表中。
+// 所有迁移语句必须可重复执行（IF NOT EXISTS 等），以兼容手动执行过 init.sql 的数据库。
+type migration struct {
+    version int
+    name    string
+    sql     string
+}
+
+var migrations = []migration{
+    {
+        version: 1,
+        name:    "initial_schema",
+        sql: `
+            CREATE TABLE IF NOT EXISTS students (
+                id SERIAL PRIMARY KEY,
+                email VARCHAR(100) UNIQUE,
+                username VARCHAR(100) NOT NULL,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+            );
+
+            CREATE TABLE IF NOT EXISTS courses (
+                id SERIAL PRIMARY KEY,
+                course_code VARCHAR(20) NOT NULL,
+                course_name VARCHAR(200) NOT NULL,
+                course_description TEXT,
+                credits INTEGER DEFAULT 3,
+                instructor VARCHAR(100),
+                semester VARCHAR(20),
+                time_slot VARCHAR(100),
+                course_location VARCHAR(100),
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+            );
+
+            CREATE TABLE IF NOT EXISTS student_courses (
+                id SERIAL PRIMARY KEY,
+                student_id INTEGER REFERENCES students(id) ON DELETE CASCADE,
+                course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+                enrolled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                UNIQUE(student_id, course_id)
+            );
+
+            CREATE INDEX IF NOT EXISTS idx_student_courses_student_id ON student_courses(student_id);
+            CREATE INDEX IF NOT EXISTS idx_student_courses_course_id ON student_courses(course_id);
+            CREATE INDEX IF NOT EXISTS idx_students_email ON students(email);
+            CREATE INDEX IF NOT EXISTS idx_courses_code ON courses(course_code);
+            CREATE INDEX IF NOT EXISTS idx_courses_semester ON courses(semester);
+        `,
+    },
+    {
+        version: 2,
+        name:    "idempotency_keys",
+        sql: `
+            CREATE TABLE IF NOT EXISTS idempotency_keys (
+                idempotency_key VARCHAR(255) NOT NULL,
+                method VARCHAR(10) NOT NULL,
+                path VARCHAR(500) NOT NULL,
+                request_hash CHAR(64) NOT NULL,
+                status_code INTEGER,
+                content_type VARCHAR(100),
+                response_body BYTEA,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                expires_at TIMESTAMP NOT NULL,
+                PRIMARY KEY (idempotency_key, method, path)
+            );
+
+            CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+        `,
+    },
+    {
+        version: 3,
+        name:    "audit_events",
+        sql: `
+            CREATE TABLE IF NOT EXISTS audit_events (
+                id BIGSERIAL PRIMARY KEY,
+                actor VARCHAR(100) NOT NULL,
+                action VARCHAR(50) NOT NULL,
+                student_id INTEGER,
+                course_id INTEGER,
+                before_data JSONB,
+                after_data JSONB,
+                request_id VARCHAR(64),
+                created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+            );
+
+            CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+            BEGIN
+                RAISE EXCEPTION 'audit_events is append-only';
+            END;
+            $$ LANGUAGE plpgsql;
+
+            DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+            CREATE TRIGGER audit_events_append_only
+                BEFORE UPDATE OR DELETE ON audit_events
+                FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+            CREATE INDEX IF NOT EXISTS idx_audit_events_student_id ON audit_events(student_id, created_at);
+            CREATE INDEX IF NOT EXISTS idx_audit_events_course_id ON audit_events(course_id, created_at);
+            CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+        `,
+    },
+    {
+        version: 4,
+        name:    "enrollment_status",
+        sql: `
+            ALTER TABLE student_courses ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'enrolled';
+            ALTER TABLE student_courses ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP;
+
+            ALTER TABLE student_courses DROP CONSTRAINT IF EXISTS student_courses_status_check;
+            ALTER TABLE student_courses ADD CONSTRAINT student_courses_status_check
+                CHECK (status IN ('enrolled', 'dropped', 'withdrawn', 'removed_by_admin', 'completed'));
+
+            -- 同一门课可以有多条历史记录，但最多只有一条处于选课状态
+            ALTER TABLE student_courses DROP CONSTRAINT IF EXISTS student_courses_student_id_course_id_key;
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_student_courses_active
+                ON student_courses(student_id, course_id) WHERE status = 'enrolled';
+        `,
+    },
+    {
+        version: 5,
+        name:    "enrollment_grades",
+        sql: `
+            ALTER TABLE student_courses ADD COLUMN IF NOT EXISTS grade VARCHAR(2);
+            ALTER TABLE student_courses ADD COLUMN IF NOT EXISTS grade_points NUMERIC(2, 1);
+            ALTER TABLE student_courses ADD COLUMN IF NOT EXISTS pass_fail BOOLEAN NOT NULL DEFAULT false;
+            ALTER TABLE student_courses ADD COLUMN IF NOT EXISTS graded_at TIMESTAMP;
+
+            ALTER TABLE student_courses DROP CONSTRAINT IF EXISTS student_courses_grade_check;
+            ALTER TABLE student_courses ADD CONSTRAINT student_courses_grade_check
+                CHECK (grade IN ('A+', 'A', 'A-', 'B+', 'B', 'B-', 'C+', 'C', 'C-', 'D+', 'D', 'F', 'P'));
+        `,
+    },
+    {
+        version: 6,
+        name:    "programmes",
+        sql: `
+            ALTER TABLE courses ADD COLUMN IF NOT EXISTS category VARCHAR(50) NOT NULL DEFAULT '';
+
+            CREATE TABLE IF NOT EXISTS programmes (
+                id SERIAL PRIMARY KEY,
+                programme_code VARCHAR(20) NOT NULL UNIQUE,
+                programme_name VARCHAR(200) NOT NULL,
+                programme_type VARCHAR(10) NOT NULL CHECK (programme_type IN ('major', 'minor')),
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+            );
+
+            CREATE TABLE IF NOT EXISTS programme_requirements (
+                id SERIAL PRIMARY KEY,
+                programme_id INTEGER NOT NULL REFERENCES programmes(id) ON DELETE CASCADE,
+                position INTEGER NOT NULL DEFAULT 0,
+                requirement_name VARCHAR(200) NOT NULL,
+                rule_type VARCHAR(10) NOT NULL CHECK (rule_type IN ('courses', 'credits')),
+                course_codes TEXT[] NOT NULL DEFAULT '{}',
+                category VARCHAR(50) NOT NULL DEFAULT '',
+                min_courses INTEGER,
+                min_credits INTEGER
+            );
+
+            CREATE TABLE IF NOT EXISTS student_programmes (
+                student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+                programme_id INTEGER NOT NULL REFERENCES programmes(id) ON DELETE CASCADE,
+                declared_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                PRIMARY KEY (student_id, programme_id)
+            );
+
+            CREATE INDEX IF NOT EXISTS idx_courses_category ON courses(category);
+            CREATE INDEX IF NOT EXISTS idx_programme_requirements_programme_id ON programme_requirements(programme_id);
+        `,
+    },
+    {
+        version: 7,
+        name:    "course_sections",
+        sql: `
+            CREATE TABLE IF NOT EXISTS course_sections (
+                id SERIAL PRIMARY KEY,
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                section_code VARCHAR(20) NOT NULL,
+                section_type VARCHAR(10) NOT NULL CHECK (section_type IN ('lecture', 'tutorial', 'lab')),
+                instructor VARCHAR(100),
+                time_slot VARCHAR(100),
+                course_location VARCHAR(100),
+                capacity INTEGER CHECK (capacity > 0),
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                UNIQUE (course_id, section_code)
+            );
+
+            CREATE TABLE IF NOT EXISTS enrollment_sections (
+                enrollment_id INTEGER NOT NULL REFERENCES student_courses(id) ON DELETE CASCADE,
+                section_id INTEGER NOT NULL REFERENCES course_sections(id) ON DELETE CASCADE,
+                PRIMARY KEY (enrollment_id, section_id)
+            );
+
+            CREATE INDEX IF NOT EXISTS idx_course_sections_course_id ON course_sections(course_id);
+            CREATE INDEX IF NOT EXISTS idx_enrollment_sections_section_id ON enrollment_sections(section_id);
+        `,
+    },
+    {
+        version: 8,
+        name:    "instructors",
+        sql: `
+            CREATE TABLE IF NOT EXISTS instructors (
+                id SERIAL PRIMARY KEY,
+                name VARCHAR(100) UNIQUE NOT NULL,
+                email VARCHAR(255) UNIQUE,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+            );
+
+            CREATE TABLE IF NOT EXISTS course_instructors (
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+                PRIMARY KEY (course_id, instructor_id)
+            );
+
+            CREATE INDEX IF NOT EXISTS idx_course_instructors_instructor_id ON course_instructors(instructor_id);
+        ` + linkCourseInstructorsSQL,
+    },
+    {
+        version: 9,
+        name:    "rooms",
+        sql: `
+            CREATE TABLE IF NOT EXISTS rooms (
+                id SERIAL PRIMARY KEY,
+                building VARCHAR(100) NOT NULL,
+                room VARCHAR(50) NOT NULL,
+                capacity INTEGER NOT NULL CHECK (capacity > 0),
+                features TEXT[] NOT NULL DEFAULT '{}',
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                UNIQUE (building, room)
+            );
+
+            ALTER TABLE courses ADD COLUMN IF NOT EXISTS room_id INTEGER REFERENCES rooms(id) ON DELETE SET NULL;
+            ALTER TABLE courses ADD COLUMN IF NOT EXISTS capacity INTEGER CHECK (capacity > 0);
+
+            CREATE INDEX IF NOT EXISTS idx_courses_room_id ON courses(room_id);
+        `,
+    },
+    {
+        version: 10,
+        name:    "timetable",
+        sql: `
+            CREATE TABLE IF NOT EXISTS instructor_unavailability (
+                id SERIAL PRIMARY KEY,
+                instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+                time_slot VARCHAR(100) NOT NULL
+            );
+
+            ALTER TABLE course_sections ADD COLUMN IF NOT EXISTS room_id INTEGER REFERENCES rooms(id) ON DELETE SET NULL;
+
+            CREATE INDEX IF NOT EXISTS idx_instructor_unavailability_instructor_id ON instructor_unavailability(instructor_id);
+        `,
+    },
+    {
+        version: 11,
+        name:    "cart_and_prerequisites",
+        sql: `
+            CREATE TABLE IF NOT EXISTS course_prerequisites (
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                prerequisite_code VARCHAR(20) NOT NULL,
+                PRIMARY KEY (course_id, prerequisite_code)
+            );
+
+            CREATE TABLE IF NOT EXISTS cart_items (
+                student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                section_ids INTEGER[] NOT NULL DEFAULT '{}',
+                added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                PRIMARY KEY (student_id, course_id)
+            );
+
+            CREATE INDEX IF NOT EXISTS idx_cart_items_course_id ON cart_items(course_id);
+        `,
+    },
+    {
+        version: 12,
+        name:    "registration_rounds",
+        sql: `
+            CREATE TABLE IF NOT EXISTS registration_rounds (
+                id SERIAL PRIMARY KEY,
+                name VARCHAR(100) NOT NULL,
+                semester VARCHAR(20) NOT NULL,
+                opens_at TIMESTAMP NOT NULL,
+                closes_at TIMESTAMP NOT NULL,
+                seed BIGINT NOT NULL,
+                status VARCHAR(10) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'allocated')),
+                allocated_at TIMESTAMP,
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                CHECK (closes_at > opens_at)
+            );
+
+            CREATE TABLE IF NOT EXISTS round_preferences (
+                round_id INTEGER NOT NULL REFERENCES registration_rounds(id) ON DELETE CASCADE,
+                student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+                rank INTEGER NOT NULL CHECK (rank > 0),
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                section_ids INTEGER[] NOT NULL DEFAULT '{}',
+                PRIMARY KEY (round_id, student_id, rank),
+                UNIQUE (round_id, student_id, course_id)
+            );
+
+            CREATE TABLE IF NOT EXISTS round_results (
+                round_id INTEGER NOT NULL REFERENCES registration_rounds(id) ON DELETE CASCADE,
+                student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                rank INTEGER NOT NULL,
+                priority INTEGER NOT NULL,
+                earned_credits INTEGER NOT NULL,
+                ticket INTEGER NOT NULL,
+                outcome VARCHAR(10) NOT NULL CHECK (outcome IN ('enrolled', 'failed')),
+                reason TEXT NOT NULL DEFAULT '',
+                PRIMARY KEY (round_id, student_id, course_id)
+            );
+
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_registration_rounds_open_semester ON registration_rounds(semester) WHERE status = 'open';
+        `,
+    },
+    {
+        version: 13,
+        name:    "enrollment_petitions",
+        sql: `
+            CREATE TABLE IF NOT EXISTS enrollment_petitions (
+                id SERIAL PRIMARY KEY,
+                student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                section_ids INTEGER[] NOT NULL DEFAULT '{}',
+                overrides TEXT[] NOT NULL DEFAULT '{}',
+                reason TEXT NOT NULL,
+                status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+                reviewed_by VARCHAR(50),
+                review_note TEXT NOT NULL DEFAULT '',
+                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                reviewed_at TIMESTAMP
+            );
+
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_enrollment_petitions_pending ON enrollment_petitions(student_id, course_id) WHERE status = 'pending';
+            CREATE INDEX IF NOT EXISTS idx_enrollment_petitions_course_id ON enrollment_petitions(course_id);
+        `,
+    },
+    {
+        version: 14,
+        name:    "audit_events_timestamptz",
+        sql: `
+            ALTER TABLE audit_events
+                ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE current_setting('TimeZone');
+        `,
+    },
+    {
+        version: 15,
+        name:    "round_allocation_batches",
+        sql: `
+            ALTER TABLE registration_rounds DROP CONSTRAINT IF EXISTS registration_rounds_status_check;
+            ALTER TABLE registration_rounds
+                ADD CONSTRAINT registration_rounds_status_check CHECK (status IN ('open', 'allocating', 'allocated')),
+                ADD COLUMN IF NOT EXISTS allocation_error TEXT NOT NULL DEFAULT '';
+
+            DROP INDEX IF EXISTS idx_registration_rounds_open_semester;
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_registration_rounds_pending_semester ON registration_rounds(semester) WHERE status <> 'allocated';
+        `,
+    },
+    {
+        version: 16,
+        name:    "enrollment_status_moved",
+        sql: `
+            ALTER TABLE student_courses DROP CONSTRAINT IF EXISTS student_courses_status_check;
+            ALTER TABLE student_courses ADD CONSTRAINT student_courses_status_check
+                CHECK (status IN ('enrolled', 'dropped', 'withdrawn', 'removed_by_admin', 'moved', 'completed'));
+        `,
+    },
+    {
+        version: 17,
+        name:    "waitlist_entries",
+        sql: `
+            CREATE TABLE IF NOT EXISTS waitlist_entries (
+                id SERIAL PRIMARY KEY,
+                student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+                course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+                section_ids INTEGER[] NOT NULL DEFAULT '{}',
+                status VARCHAR(10) NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'promoted', 'left', 'skipped', 'removed')),
+                reason TEXT NOT NULL DEFAULT '',
+                created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+                ended_at TIMESTAMPTZ
+            );
+
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_waitlist_entries_waiting ON waitlist_entries(student_id, course_id) WHERE status = 'waiting';
+            CREATE INDEX IF NOT EXISTS idx_waitlist_entries_course_queue ON waitlist_entries(course_id, id) WHERE status = 'waiting';
+        `,
+    },
+    {
+        version: 18,
+        name:    "waitlist_status_enrolled_directly",
+        sql: `
+            ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_status_check;
+            ALTER TABLE waitlist_entries ALTER COLUMN status TYPE VARCHAR(20);
+            ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_status_check
+                CHECK (status IN ('waiting', 'promoted', 'enrolled_directly', 'left', 'skipped', 'removed'));
+        `,
+    },
+}
+
+// 迁移锁的键，防止多个实例同时启动时重复执行迁移
+const migrationLockKey = 727001
+
+// 执行所有未应用的迁移
+func (db *Database) Migrate() error {
+    _, err := db.DB.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name VARCHAR(100) NOT NULL,
+            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )
+    `)
+    if err != nil {
+        return fmt.Errorf("failed to create schema_migrations table: %w", err)
+    }
+
+    for _, m := range migrations {
+        applied, err := db.applyMigration(m)
+        if err != nil {
+            return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+        }
+        if applied {
+            slog.Info("已应用数据库迁移", "version", m.version, "name", m.name)
+        }
+    }
+
+    return nil
+}
+
+// 在单个事务中执行一条迁移，已执行过则跳过
+func (db *Database) applyMigration(m migration) (bool, error) {
+    tx, err := db.DB.Begin()
+    if err != nil {
+        return false, fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
+        return false, fmt.Errorf("failed to acquire migration lock: %w", err)
+    }
+
+    var exists bool
+    err = tx.QueryRow(`SELECT COUNT(*) > 0 FROM schema_migrations WHERE version = $1`, m.version).Scan(&exists)
+    if err != nil {
+        return false, fmt.Errorf("failed to check migration status: %w", err)
+    }
+    if exists {
+        return false, nil
+    }
+
+    if _, err := tx.Exec(m.sql); err != nil {
+        return false, err
+    }
+
+    _, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name)
+    if err != nil {
+        return false, fmt.Errorf("failed to record migration: %w", err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return false, fmt.Errorf("failed to commit migration: %w", err)
+    }
+
+    return true, nil
+}
+
+// 返回尚未应用的迁移数量，供就绪检查使用
+func (db *Database) PendingMigrations(ctx context.Context) (int, error) {
+    var current sql.NullInt64
+    err := db.DB.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&current)
+    if err != nil {
+        return 0, fmt.Errorf("failed to query schema version: %w", err)
+    }
+
+    pending := 0
+    for _, m := range migrations {
+        if !current.Valid || int64(m.version) > current.Int64 {
+            pending++
+        }
+    }
+
+    return pending, nil
+}
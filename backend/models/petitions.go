@@ -0,0 +1,298 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// 选课申请状态
+const (
+    PetitionPending  = "pending"
+    PetitionApproved = "approved"
+    PetitionRejected = "rejected"
+)
+
+// 选课申请：学生因容量、先修课程或选课轮次无法选课时提交，说明理由后由任课教师或管理员审批。
+// Overrides 为提交时检测到的未满足规则，审批通过时只豁免这些规则，其余规则照常检查
+type Petition struct {
+    ID         int        `json:"id"`
+    StudentID  int        `json:"student_id"`
+    CourseID   int        `json:"course_id"`
+    CourseCode string     `json:"course_code"`
+    SectionIDs []int      `json:"section_ids"`
+    Overrides  []string   `json:"overrides"`
+    Reason     string     `json:"reason"`
+    Status     string     `json:"status"`
+    ReviewedBy string     `json:"reviewed_by"`
+    ReviewNote string     `json:"review_note"`
+    CreatedAt  time.Time  `json:"created_at"`
+    ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+// 选课申请查询条件，零值表示不筛选。InstructorID 筛选该教师任教课程的申请
+type PetitionFilter struct {
+    StudentID    int
+    InstructorID int
+    Status       string
+}
+
+const petitionQuery = `
+    SELECT p.id, p.student_id, p.course_id, c.course_code, p.section_ids, p.overrides, p.reason,
+           p.status, COALESCE(p.reviewed_by, ''), p.review_note, p.created_at, p.reviewed_at
+    FROM enrollment_petitions p
+    JOIN courses c ON c.id = p.course_id
+`
+
+type rowScanner interface {
+    Scan(dest ...any) error
+}
+
+func scanPetition(row rowScanner) (Petition, error) {
+    var p Petition
+    var sectionIDs pq.Int64Array
+    var overrides pq.StringArray
+    err := row.Scan(&p.ID, &p.StudentID, &p.CourseID, &p.CourseCode, &sectionIDs, &overrides, &p.Reason,
+        &p.Status, &p.ReviewedBy, &p.ReviewNote, &p.CreatedAt, &p.ReviewedAt)
+    if err != nil {
+        return p, err
+    }
+    p.SectionIDs = make([]int, len(sectionIDs))
+    for i, id := range sectionIDs {
+        p.SectionIDs[i] = int(id)
+    }
+    p.Overrides = []string(overrides)
+    return p, nil
+}
+
+// 查询选课申请，按提交时间倒序
+func (db *Database) GetPetitions(ctx context.Context, filter PetitionFilter) ([]Petition, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    var conditions []string
+    var args []any
+    addCondition := func(condition string, value any) {
+        args = append(args, value)
+        conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+    }
+
+    if filter.StudentID > 0 {
+        addCondition("p.student_id = $%d", filter.StudentID)
+    }
+    if filter.InstructorID > 0 {
+        addCondition("p.course_id IN (SELECT course_id FROM course_instructors WHERE instructor_id = $%d)", filter.InstructorID)
+    }
+    if filter.Status != "" {
+        addCondition("p.status = $%d", filter.Status)
+    }
+
+    query := petitionQuery
+    if len(conditions) > 0 {
+        query += " WHERE " + strings.Join(conditions, " AND ")
+    }
+    query += " ORDER BY p.created_at DESC, p.id DESC"
+
+    rows, err := db.query(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query petitions: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    petitions := []Petition{}
+    for rows.Next() {
+        petition, err := scanPetition(rows)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan petition: %w", queryError(ctx, err))
+        }
+        petitions = append(petitions, petition)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return petitions, nil
+}
+
+// 提交选课申请。先按选课规则试选（不生效），记录需要豁免的规则：
+// 可以直接选课时返回 ErrPetitionNotNeeded；未满足的规则不能豁免（如时间冲突、学分上限）时返回 ErrNotPetitionable
+func (db *Database) SubmitPetition(ctx context.Context, studentID, courseID int, sectionIDs []int, reason string) (*Petition, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    if sectionIDs == nil {
+        sectionIDs = []int{}
+    }
+
+    var petition Petition
+    err := db.inTx(ctx, func(tx txn) error {
+        if err := tx.lockStudent(ctx, studentID); err != nil {
+            return err
+        }
+
+        var pending bool
+        err := tx.queryRow(ctx, `
+            SELECT EXISTS(SELECT 1 FROM enrollment_petitions WHERE student_id = $1 AND course_id = $2 AND status = 'pending')
+        `, studentID, courseID).Scan(&pending)
+        if err != nil {
+            return fmt.Errorf("failed to check pending petitions: %w", queryError(ctx, err))
+        }
+        if pending {
+            return fmt.Errorf("%w (student %d, course %d)", ErrDuplicatePetition, studentID, courseID)
+        }
+
+        overrides, err := tx.petitionOverrides(ctx, studentID, courseID, sectionIDs)
+        if err != nil {
+            return err
+        }
+
+        var id int
+        err = tx.queryRow(ctx, `
+            INSERT INTO enrollment_petitions (student_id, course_id, section_ids, overrides, reason)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id
+        `, studentID, courseID, pq.Array(sectionIDs), pq.Array(overrides), reason).Scan(&id)
+        if err != nil {
+            return fmt.Errorf("failed to create petition: %w", queryError(ctx, err))
+        }
+
+        petition, err = scanPetition(tx.queryRow(ctx, petitionQuery+" WHERE p.id = $1", id))
+        if err != nil {
+            return fmt.Errorf("failed to read petition: %w", queryError(ctx, err))
+        }
+
+        return tx.recordAudit(ctx, AuditPetitionSubmitted, studentID, courseID, nil, petition)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &petition, nil
+}
+
+// 审批选课申请。instructorID 不为 0 时由该教师审批，须任教该课程；为 0 时由管理员审批。
+// 批准时豁免申请记录的规则并完成选课，此时其他规则不满足（如已选满学分）则返回对应错误，申请保持待审批
+func (db *Database) ReviewPetition(ctx context.Context, petitionID, instructorID int, approve bool, note string) (*Petition, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    var reviewed Petition
+    err := db.inTx(ctx, func(tx txn) error {
+        // 先锁定学生再锁定申请，与选课的加锁顺序一致
+        var studentID int
+        err := tx.queryRow(ctx, `SELECT student_id FROM enrollment_petitions WHERE id = $1`, petitionID).Scan(&studentID)
+        if err == sql.ErrNoRows {
+            return fmt.Errorf("%w (ID %d)", ErrPetitionNotFound, petitionID)
+        }
+        if err != nil {
+            return fmt.Errorf("failed to get petition: %w", queryError(ctx, err))
+        }
+        if err := tx.lockStudent(ctx, studentID); err != nil {
+            return err
+        }
+
+        petition, err := scanPetition(tx.queryRow(ctx, petitionQuery+" WHERE p.id = $1 FOR UPDATE OF p", petitionID))
+        if err != nil {
+            return fmt.Errorf("failed to lock petition: %w", queryError(ctx, err))
+        }
+        if petition.Status != PetitionPending {
+            return fmt.Errorf("%w (ID %d, %s)", ErrPetitionReviewed, petitionID, petition.Status)
+        }
+
+        if instructorID > 0 {
+            var teaches bool
+            err := tx.queryRow(ctx, `SELECT EXISTS(SELECT 1 FROM course_instructors WHERE instructor_id = $1 AND course_id = $2)`,
+                instructorID, petition.CourseID).Scan(&teaches)
+            if err != nil {
+                return fmt.Errorf("failed to check course assignment: %w", queryError(ctx, err))
+            }
+            if !teaches {
+                return fmt.Errorf("%w (instructor %d, course %s)", ErrNotAssigned, instructorID, petition.CourseCode)
+            }
+        }
+
+        status := PetitionRejected
+        if approve {
+            status = PetitionApproved
+            _, err := tx.enrollWithOverrides(ctx, petition.StudentID, petition.CourseID, petition.SectionIDs, petition.Overrides)
+            if err != nil {
+                return err
+            }
+        }
+
+        _, err = tx.exec(ctx, `
+            UPDATE enrollment_petitions
+            SET status = $2, reviewed_by = $3, review_note = $4, reviewed_at = CURRENT_TIMESTAMP
+            WHERE id = $1
+        `, petitionID, status, actorFromContext(ctx), note)
+        if err != nil {
+            return fmt.Errorf("failed to update petition: %w", queryError(ctx, err))
+        }
+
+        reviewed, err = scanPetition(tx.queryRow(ctx, petitionQuery+" WHERE p.id = $1", petitionID))
+        if err != nil {
+            return fmt.Errorf("failed to read petition: %w", queryError(ctx, err))
+        }
+
+        action := AuditPetitionRejected
+        if approve {
+            action = AuditPetitionApproved
+        }
+        return tx.recordAudit(ctx, action, petition.StudentID, petition.CourseID, petition, reviewed)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &reviewed, nil
+}
+
+// 试选课程以确定需要豁免的规则：每次试选失败在可豁免的规则上时加入该规则重试，直到试选成功。
+// 试选在保存点中进行并随即回滚，不会产生选课记录
+func (tx txn) petitionOverrides(ctx context.Context, studentID, courseID int, sectionIDs []int) ([]string, error) {
+    overrides := []string{}
+    for {
+        if err := tx.savepoint(ctx, "petition_check"); err != nil {
+            return nil, err
+        }
+        _, enrollErr := tx.enrollWithOverrides(ctx, studentID, courseID, sectionIDs, overrides)
+        if err := tx.rollbackToSavepoint(ctx, "petition_check"); err != nil {
+            return nil, err
+        }
+
+        rule := overrideRule(enrollErr)
+        switch {
+        case enrollErr == nil && len(overrides) == 0:
+            return nil, fmt.Errorf("%w (course %d)", ErrPetitionNotNeeded, courseID)
+        case enrollErr == nil:
+            return overrides, nil
+        case rule != "" && !slices.Contains(overrides, rule):
+            overrides = append(overrides, rule)
+        case errors.Is(enrollErr, ErrCourseNotFound) || !isEnrollmentRuleError(enrollErr):
+            return nil, enrollErr
+        default:
+            return nil, fmt.Errorf("%w: %v", ErrNotPetitionable, enrollErr)
+        }
+    }
+}
+
+// 选课失败原因对应的可豁免规则，不可豁免时返回空字符串
+func overrideRule(err error) string {
+    switch {
+    case errors.Is(err, ErrCourseFull), errors.Is(err, ErrSectionFull):
+        return OverrideCapacity
+    case errors.Is(err, ErrPrerequisitesNotMet):
+        return OverridePrerequisites
+    case errors.Is(err, ErrRoundPending):
+        return OverrideRegistrationWindow
+    default:
+        return ""
+    }
+}
@@ -0,0 +1,452 @@
+package models
+
+import (
+    "container/heap"
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/lib/pq"
+)
+
+// 选课规划的偏好，均为 false 时按课程和教学班的顺序列出方案
+type PlanPreferences struct {
+    NoEarlyMornings bool // 避免 10:00 前开始的课
+    CompactDays     bool // 上课日尽量少，课间空档尽量短
+    FreeFridays     bool // 周五不上课
+}
+
+// 方案中的一门课程及选择的教学班
+type PlannedCourse struct {
+    CourseID   int
+    CourseCode string
+    CourseName string
+    TimeSlot   string
+    Sections   []Section
+}
+
+// 方案的时间特征，用于按偏好排序
+type PlanStats struct {
+    EarlyStarts   int // 10:00 前开始的课次
+    FridayClasses int // 周五的课次
+    TeachingDays  int // 有课的天数
+    IdleMinutes   int // 同一天两次课之间的空档总时长
+}
+
+// 一个无时间冲突的选课方案。Penalty 越小越符合偏好
+type Plan struct {
+    Courses []PlannedCourse
+    Stats   PlanStats
+    Penalty int
+}
+
+// 规划结果。Total 为找到的无冲突方案数；搜索超过节点上限时 Truncated 为 true，
+// 此时 Total 和 Plans 只涵盖已搜索的部分组合，Notes 中有相应说明。
+// 没有方案时 Reasons 说明原因，Notes 还列出时间未知、未参与冲突检查的课程或教学班
+type PlanResult struct {
+    Semester  string
+    Plans     []Plan
+    Total     int
+    Truncated bool
+    Reasons   []string
+    Notes     []string
+}
+
+// 搜索最多访问的节点数（部分或完整的组合），避免课程和教学班很多时耗时过长
+const maxPlanSearchNodes = 200000
+
+const earlyMorningEnd = 10 * 60
+
+// 为学生列出所选课程的所有无时间冲突的教学班组合，按偏好排序后返回前 limit 个（limit 为 0 时返回全部）。
+// 课程须属于同一学期；学生同学期已在读的其他课程视为固定安排，方案不能与之冲突。已满的教学班不参与规划
+func (db *Database) PlanSchedule(ctx context.Context, studentID int, courseIDs []int, prefs PlanPreferences, limit int) (*PlanResult, error) {
+    exists, err := db.StudentExists(ctx, studentID)
+    if err != nil {
+        return nil, err
+    }
+    if !exists {
+        return nil, fmt.Errorf("%w (ID %d)", ErrStudentNotFound, studentID)
+    }
+
+    result := &PlanResult{}
+    var courses []planCourse
+    seen := make(map[int]bool)
+    for _, courseID := range courseIDs {
+        if seen[courseID] {
+            continue
+        }
+        seen[courseID] = true
+
+        course, err := db.GetCourseByID(ctx, courseID)
+        if err != nil {
+            return nil, err
+        }
+        if course == nil {
+            return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, courseID)
+        }
+        if len(courses) == 0 {
+            result.Semester = course.Semester
+        } else if course.Semester != result.Semester {
+            return nil, fmt.Errorf("%w: %s is in %s, %s is in %s", ErrMixedSemesters,
+                course.CourseCode, course.Semester, courses[0].course.CourseCode, result.Semester)
+        }
+
+        sections, err := db.GetCourseSections(ctx, courseID)
+        if err != nil {
+            return nil, err
+        }
+        courses = append(courses, planCourse{course: *course, sections: sections})
+    }
+
+    fixed, err := db.enrolledMeetings(ctx, studentID, result.Semester, seen, &result.Notes)
+    if err != nil {
+        return nil, err
+    }
+
+    for i := range courses {
+        courses[i].buildOptions(fixed, &result.Notes)
+        if len(courses[i].options) == 0 {
+            result.Reasons = append(result.Reasons, courses[i].emptyReason)
+        }
+    }
+    if len(result.Reasons) > 0 {
+        return result, nil
+    }
+
+    // 先安排可选组合少的课程，尽早排除冲突
+    order := make([]int, len(courses))
+    for i := range order {
+        order[i] = i
+    }
+    sort.SliceStable(order, func(i, j int) bool {
+        return len(courses[order[i]].options) < len(courses[order[j]].options)
+    })
+
+    // 只保留 Penalty 最小的 limit 个方案，相同时保留先找到的
+    best := &planHeap{}
+    nodes := 0
+    chosen := make([]int, len(courses))
+    var search func(depth int, busy []meeting)
+    search = func(depth int, busy []meeting) {
+        if result.Truncated {
+            return
+        }
+        nodes++
+        if nodes > maxPlanSearchNodes {
+            result.Truncated = true
+            return
+        }
+        if depth == len(order) {
+            seq := result.Total
+            result.Total++
+            _, penalty := scorePlan(busy, prefs)
+            if limit > 0 && best.Len() == limit && !best.better(penalty, seq, (*best)[0]) {
+                return
+            }
+            heap.Push(best, rankedPlan{plan: buildPlan(courses, chosen, busy, prefs), seq: seq})
+            if limit > 0 && best.Len() > limit {
+                heap.Pop(best)
+            }
+            return
+        }
+
+        c := order[depth]
+        for i, option := range courses[c].options {
+            if meetingsOverlap(option.meetings, busy) {
+                continue
+            }
+            chosen[c] = i
+            search(depth+1, append(busy[:len(busy):len(busy)], option.meetings...))
+        }
+    }
+    search(0, fixed)
+
+    if result.Truncated {
+        result.Notes = append(result.Notes, fmt.Sprintf(
+            "search stopped after %d partial combinations; the plans are ranked among the %d found so far and better ones may exist",
+            maxPlanSearchNodes, result.Total))
+    }
+    if result.Total == 0 {
+        if !result.Truncated {
+            result.Reasons = clashReasons(courses)
+        }
+        return result, nil
+    }
+
+    ranked := *best
+    sort.Slice(ranked, func(i, j int) bool {
+        return ranked.better(ranked[i].plan.Penalty, ranked[i].seq, ranked[j])
+    })
+    for _, r := range ranked {
+        result.Plans = append(result.Plans, r.plan)
+    }
+
+    return result, nil
+}
+
+// 排序中的方案，seq 为找到的先后顺序
+type rankedPlan struct {
+    plan Plan
+    seq  int
+}
+
+// 堆顶为当前保留的方案中最差的一个，新方案更好时替换它
+type planHeap []rankedPlan
+
+func (h planHeap) Len() int           { return len(h) }
+func (h planHeap) Less(i, j int) bool { return h.better(h[j].plan.Penalty, h[j].seq, h[i]) }
+func (h planHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *planHeap) Push(x any)        { *h = append(*h, x.(rankedPlan)) }
+
+func (h *planHeap) Pop() any {
+    old := *h
+    last := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return last
+}
+
+// Penalty 更小，或相同但更早找到的方案更好
+func (h planHeap) better(penalty, seq int, other rankedPlan) bool {
+    if penalty != other.plan.Penalty {
+        return penalty < other.plan.Penalty
+    }
+    return seq < other.seq
+}
+
+// 规划中的一门课程
+type planCourse struct {
+    course      Course
+    sections    []Section
+    options     []planOption
+    emptyReason string
+}
+
+// 课程的一种教学班组合（每种类型一个）及其全部上课时间，含课程本身的时间
+type planOption struct {
+    sections []Section
+    meetings []meeting
+}
+
+// 枚举课程的教学班组合，去掉内部冲突或与已在读课程冲突的组合
+func (c *planCourse) buildOptions(fixed []meeting, notes *[]string) {
+    // 只通过教学班上课的课程可以不填课程本身的时间
+    var base []meeting
+    if c.course.TimeSlot != "" || len(c.sections) == 0 {
+        base = knownMeetings(c.course.CourseCode, c.course.TimeSlot, notes)
+    }
+
+    var groups [][]Section
+    for _, sectionType := range []string{SectionLecture, SectionTutorial, SectionLab} {
+        var group []Section
+        total := 0
+        for _, section := range c.sections {
+            if section.SectionType != sectionType {
+                continue
+            }
+            total++
+            if section.Capacity != nil && section.EnrolledCount >= *section.Capacity {
+                continue
+            }
+            group = append(group, section)
+        }
+        if total > 0 && len(group) == 0 {
+            c.emptyReason = fmt.Sprintf("every %s section of %s is full", sectionType, c.course.CourseCode)
+            return
+        }
+        if len(group) > 0 {
+            groups = append(groups, group)
+        }
+    }
+
+    sectionMeetings := make(map[int][]meeting)
+    for _, group := range groups {
+        for _, section := range group {
+            label := c.course.CourseCode + " " + section.SectionCode
+            sectionMeetings[section.ID] = knownMeetings(label, section.TimeSlot, notes)
+        }
+    }
+
+    clashesFixed := false
+    var expand func(i int, picked []Section, meetings []meeting)
+    expand = func(i int, picked []Section, meetings []meeting) {
+        if i == len(groups) {
+            if meetingsOverlap(meetings, fixed) {
+                clashesFixed = true
+                return
+            }
+            c.options = append(c.options, planOption{
+                sections: append([]Section(nil), picked...),
+                meetings: meetings,
+            })
+            return
+        }
+        for _, section := range groups[i] {
+            m := sectionMeetings[section.ID]
+            if meetingsOverlap(m, meetings) {
+                continue
+            }
+            expand(i+1, append(picked, section), append(meetings[:len(meetings):len(meetings)], m...))
+        }
+    }
+    expand(0, nil, base)
+
+    switch {
+    case len(c.options) > 0:
+    case clashesFixed:
+        c.emptyReason = fmt.Sprintf("every section combination of %s clashes with the student's enrolled courses", c.course.CourseCode)
+    default:
+        c.emptyReason = fmt.Sprintf("the sections of %s clash with each other or with its lectures", c.course.CourseCode)
+    }
+}
+
+// 解析上课时间。时间为空或无法识别时记录到 notes，该课程或教学班不参与冲突检查
+func knownMeetings(label, timeSlot string, notes *[]string) []meeting {
+    meetings, err := parseTimeSlot(timeSlot)
+    if err != nil {
+        if strings.TrimSpace(timeSlot) == "" {
+            *notes = append(*notes, fmt.Sprintf("%s has no time slot and was not checked for clashes", label))
+        } else {
+            *notes = append(*notes, fmt.Sprintf("%s has an unrecognised time slot %q and was not checked for clashes", label, timeSlot))
+        }
+        return nil
+    }
+    return meetings
+}
+
+// 学生同学期在读课程（不含正在规划的课程）的上课时间，包括所在教学班的时间
+func (db *Database) enrolledMeetings(ctx context.Context, studentID int, semester string, planned map[int]bool, notes *[]string) ([]meeting, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT c.id, c.course_code, COALESCE(c.time_slot, ''),
+               ARRAY(SELECT COALESCE(cs.time_slot, '')
+                     FROM enrollment_sections es
+                     JOIN course_sections cs ON cs.id = es.section_id
+                     WHERE es.enrollment_id = sc.id)
+        FROM student_courses sc
+        JOIN courses c ON c.id = sc.course_id
+        WHERE sc.student_id = $1 AND sc.status = 'enrolled' AND COALESCE(c.semester, '') = $2
+        ORDER BY c.course_code
+    `
+
+    rows, err := db.query(ctx, query, studentID, semester)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query enrolled courses: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    var meetings []meeting
+    for rows.Next() {
+        var courseID int
+        var courseCode, timeSlot string
+        var sectionSlots []string
+        if err := rows.Scan(&courseID, &courseCode, &timeSlot, pq.Array(&sectionSlots)); err != nil {
+            return nil, fmt.Errorf("failed to scan enrolled course: %w", queryError(ctx, err))
+        }
+        if planned[courseID] {
+            continue
+        }
+        meetings = append(meetings, knownMeetings(courseCode, timeSlot, notes)...)
+        for _, slot := range sectionSlots {
+            meetings = append(meetings, knownMeetings(courseCode, slot, notes)...)
+        }
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return meetings, nil
+}
+
+func buildPlan(courses []planCourse, chosen []int, busy []meeting, prefs PlanPreferences) Plan {
+    plan := Plan{Courses: make([]PlannedCourse, len(courses))}
+    for i, c := range courses {
+        plan.Courses[i] = PlannedCourse{
+            CourseID:   c.course.ID,
+            CourseCode: c.course.CourseCode,
+            CourseName: c.course.CourseName,
+            TimeSlot:   c.course.TimeSlot,
+            Sections:   c.options[chosen[i]].sections,
+        }
+    }
+
+    plan.Stats, plan.Penalty = scorePlan(busy, prefs)
+    return plan
+}
+
+// 按偏好计算一周上课时间的罚分
+func scorePlan(busy []meeting, prefs PlanPreferences) (PlanStats, int) {
+    stats := planStats(busy)
+    penalty := 0
+    if prefs.NoEarlyMornings {
+        penalty += 10 * stats.EarlyStarts
+    }
+    if prefs.FreeFridays {
+        penalty += 10 * stats.FridayClasses
+    }
+    if prefs.CompactDays {
+        penalty += 5*stats.TeachingDays + stats.IdleMinutes/30
+    }
+    return stats, penalty
+}
+
+// 统计一周的上课时间：早课、周五课次、上课天数和课间空档
+func planStats(meetings []meeting) PlanStats {
+    var stats PlanStats
+    byDay := make(map[int][]meeting)
+    for _, m := range meetings {
+        if m.Start < earlyMorningEnd {
+            stats.EarlyStarts++
+        }
+        if m.Day == 4 {
+            stats.FridayClasses++
+        }
+        byDay[m.Day] = append(byDay[m.Day], m)
+    }
+
+    stats.TeachingDays = len(byDay)
+    for _, day := range byDay {
+        sort.Slice(day, func(i, j int) bool { return day[i].Start < day[j].Start })
+        end := day[0].End
+        for _, m := range day[1:] {
+            if m.Start > end {
+                stats.IdleMinutes += m.Start - end
+            }
+            if m.End > end {
+                end = m.End
+            }
+        }
+    }
+    return stats
+}
+
+// 没有方案时找出任何组合都互相冲突的课程
+func clashReasons(courses []planCourse) []string {
+    var reasons []string
+    for i := range courses {
+        for j := i + 1; j < len(courses); j++ {
+            if alwaysClash(courses[i].options, courses[j].options) {
+                reasons = append(reasons, fmt.Sprintf("%s and %s clash in every section combination",
+                    courses[i].course.CourseCode, courses[j].course.CourseCode))
+            }
+        }
+    }
+    if len(reasons) == 0 {
+        reasons = append(reasons, "no section combination fits all selected courses at the same time")
+    }
+    return reasons
+}
+
+func alwaysClash(a, b []planOption) bool {
+    for _, x := range a {
+        for _, y := range b {
+            if !meetingsOverlap(x.meetings, y.meetings) {
+                return false
+            }
+        }
+    }
+    return true
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"container/heap"
+	"testing"
+)
+
+func TestPlanStats(t *testing.T) {
+    tests := []struct {
+        name     string
+        meetings []meeting
+        want     PlanStats
+    }{
+        {
+            name: "empty week",
+            want: PlanStats{},
+        },
+        {
+            name: "early start and friday",
+            meetings: []meeting{
+                {Day: 0, Start: 9 * 60, End: 10 * 60},
+                {Day: 4, Start: 14 * 60, End: 16 * 60},
+            },
+            want: PlanStats{EarlyStarts: 1, FridayClasses: 1, TeachingDays: 2},
+        },
+        {
+            name: "idle gap counted once per day",
+            meetings: []meeting{
+                {Day: 1, Start: 16 * 60, End: 17 * 60},
+                {Day: 1, Start: 10 * 60, End: 12 * 60},
+                {Day: 1, Start: 13 * 60, End: 14 * 60},
+            },
+            want: PlanStats{TeachingDays: 1, IdleMinutes: 60 + 120},
+        },
+        {
+            name: "overlapping meetings leave no gap",
+            meetings: []meeting{
+                {Day: 2, Start: 10 * 60, End: 13 * 60},
+                {Day: 2, Start: 11 * 60, End: 12 * 60},
+                {Day: 2, Start: 13 * 60, End: 14 * 60},
+            },
+            want: PlanStats{TeachingDays: 1},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := planStats(tt.meetings); got != tt.want {
+                t.Errorf("planStats = %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestBuildPlanPenalty(t *testing.T) {
+    // 周一 9:00-10:00 早课，周一 13:00-14:00，周五 10:00-11:00
+    busy := []meeting{
+        {Day: 0, Start: 9 * 60, End: 10 * 60},
+        {Day: 0, Start: 13 * 60, End: 14 * 60},
+        {Day: 4, Start: 10 * 60, End: 11 * 60},
+    }
+    courses := []planCourse{{
+        course:  Course{ID: 1, CourseCode: "COMP1117"},
+        options: []planOption{{sections: []Section{{ID: 7, SectionCode: "T1"}}, meetings: busy}},
+    }}
+
+    tests := []struct {
+        name  string
+        prefs PlanPreferences
+        want  int
+    }{
+        {name: "no preferences", want: 0},
+        {name: "no early mornings", prefs: PlanPreferences{NoEarlyMornings: true}, want: 10},
+        {name: "free fridays", prefs: PlanPreferences{FreeFridays: true}, want: 10},
+        {name: "compact days", prefs: PlanPreferences{CompactDays: true}, want: 5*2 + 180/30},
+        {name: "all preferences", prefs: PlanPreferences{NoEarlyMornings: true, CompactDays: true, FreeFridays: true}, want: 10 + 10 + 16},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            plan := buildPlan(courses, []int{0}, busy, tt.prefs)
+            if plan.Penalty != tt.want {
+                t.Errorf("Penalty = %d, want %d", plan.Penalty, tt.want)
+            }
+            if len(plan.Courses) != 1 || plan.Courses[0].CourseCode != "COMP1117" || plan.Courses[0].Sections[0].ID != 7 {
+                t.Errorf("Courses = %+v, want COMP1117 with section 7", plan.Courses)
+            }
+        })
+    }
+}
+
+func TestPlanHeapKeepsBest(t *testing.T) {
+    penalties := []int{30, 10, 20, 10, 40, 0, 20}
+    best := &planHeap{}
+    for seq, penalty := range penalties {
+        heap.Push(best, rankedPlan{plan: Plan{Penalty: penalty}, seq: seq})
+        if best.Len() > 3 {
+            heap.Pop(best)
+        }
+    }
+
+    var got []int
+    for best.Len() > 0 {
+        got = append(got, heap.Pop(best).(rankedPlan).seq)
+    }
+    // 出堆顺序从差到好：seq 3 (10)、seq 1 (10)、seq 5 (0)
+    want := []int{3, 1, 5}
+    if len(got) != len(want) {
+        t.Fatalf("kept %v, want %v", got, want)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("kept %v, want %v", got, want)
+        }
+    }
+}
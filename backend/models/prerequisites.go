@@ -0,0 +1,113 @@
+package models
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/lib/pq"
+)
+
+// 获取课程的先修课程代码。先修课程按课程代码记录，修完任一学期的该课程均可
+func (db *Database) GetCoursePrerequisites(ctx context.Context, courseID int) ([]string, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT prerequisite_code
+        FROM course_prerequisites
+        WHERE course_id = $1
+        ORDER BY prerequisite_code
+    `
+
+    rows, err := db.query(ctx, query, courseID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query prerequisites: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    codes := []string{}
+    for rows.Next() {
+        var code string
+        if err := rows.Scan(&code); err != nil {
+            return nil, fmt.Errorf("failed to scan prerequisite: %w", queryError(ctx, err))
+        }
+        codes = append(codes, code)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return codes, nil
+}
+
+// 设置课程的先修课程 (管理员功能)，整体替换原有设置。课程代码须对应已有课程，且不能是课程本身
+func (db *Database) SetCoursePrerequisites(ctx context.Context, courseID int, codes []string) ([]string, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    course, err := db.GetCourseByID(ctx, courseID)
+    if err != nil {
+        return nil, err
+    }
+    if course == nil {
+        return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, courseID)
+    }
+
+    seen := make(map[string]bool)
+    normalized := []string{}
+    for _, code := range codes {
+        code = strings.ToUpper(strings.TrimSpace(code))
+        if code == "" || seen[code] {
+            continue
+        }
+        if code == course.CourseCode {
+            return nil, fmt.Errorf("%w: %s cannot be its own prerequisite", ErrInvalidPrerequisites, code)
+        }
+        seen[code] = true
+        normalized = append(normalized, code)
+    }
+    sort.Strings(normalized)
+
+    before, err := db.GetCoursePrerequisites(ctx, courseID)
+    if err != nil {
+        return nil, err
+    }
+
+    err = db.inTx(ctx, func(tx txn) error {
+        var unknown []string
+        err := tx.queryRow(ctx, `
+            SELECT ARRAY(
+                SELECT code FROM unnest($1::text[]) AS code
+                WHERE NOT EXISTS (SELECT 1 FROM courses WHERE course_code = code)
+                ORDER BY code)
+        `, pq.Array(normalized)).Scan(pq.Array(&unknown))
+        if err != nil {
+            return fmt.Errorf("failed to check prerequisite codes: %w", queryError(ctx, err))
+        }
+        if len(unknown) > 0 {
+            return fmt.Errorf("%w: unknown course code %s", ErrInvalidPrerequisites, strings.Join(unknown, ", "))
+        }
+
+        if _, err := tx.exec(ctx, `DELETE FROM course_prerequisites WHERE course_id = $1`, courseID); err != nil {
+            return fmt.Errorf("failed to clear prerequisites: %w", queryError(ctx, err))
+        }
+        for _, code := range normalized {
+            _, err := tx.exec(ctx, `INSERT INTO course_prerequisites (course_id, prerequisite_code) VALUES ($1, $2)`,
+                courseID, code)
+            if err != nil {
+                return fmt.Errorf("failed to add prerequisite: %w", queryError(ctx, err))
+            }
+        }
+
+        return tx.recordAudit(ctx, AuditPrerequisitesUpdated, 0, courseID,
+            map[string]any{"prerequisites": before}, map[string]any{"prerequisites": normalized})
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return normalized, nil
+}
@@ -0,0 +1,491 @@
+package models
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "sort"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// 培养方案类型
+const (
+    ProgrammeMajor = "major"
+    ProgrammeMinor = "minor"
+)
+
+// 毕业要求的规则类型
+const (
+    // 修完 CourseCodes 中至少 MinCourses 门课程（MinCourses 为 0 表示全部）
+    RuleCourses = "courses"
+    // 修满 MinCredits 学分，可按 Category 和 CourseCodes 限定计入的课程
+    RuleCredits = "credits"
+)
+
+// 毕业要求的完成状态
+const (
+    RequirementSatisfied  = "satisfied"
+    RequirementInProgress = "in_progress" // 算上在读课程即可满足
+    RequirementMissing    = "missing"
+)
+
+// 培养方案（主修/辅修）及其毕业要求
+type Programme struct {
+    ID           int           `json:"id"`
+    Code         string        `json:"programme_code"`
+    Name         string        `json:"programme_name"`
+    Type         string        `json:"programme_type"`
+    Requirements []Requirement `json:"requirements"`
+    CreatedAt    time.Time     `json:"created_at"`
+}
+
+// 一条毕业要求
+type Requirement struct {
+    ID          int      `json:"id"`
+    Name        string   `json:"requirement_name"`
+    RuleType    string   `json:"rule_type"`
+    CourseCodes []string `json:"course_codes"`
+    Category    string   `json:"category"`
+    MinCourses  int      `json:"min_courses"`
+    MinCredits  int      `json:"min_credits"`
+}
+
+// 单条毕业要求的审核结果
+type RequirementProgress struct {
+    Requirement       Requirement
+    Status            string
+    CompletedCourses  []string // 已修完且计入该要求的课程代码
+    InProgressCourses []string // 在读且计入该要求的课程代码
+    MissingCourses    []string // courses 规则中尚未修读的课程代码
+    CreditsCompleted  int
+    CreditsInProgress int
+}
+
+// 学生在一个培养方案下的毕业审核结果
+type DegreeAudit struct {
+    Programme    Programme
+    DeclaredAt   time.Time
+    Status       string
+    Requirements []RequirementProgress
+}
+
+// 添加培养方案及其毕业要求
+func (db *Database) AddProgramme(ctx context.Context, programme Programme) (*Programme, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    programmeQuery := `
+        INSERT INTO programmes (programme_code, programme_name, programme_type)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at
+    `
+
+    requirementQuery := `
+        INSERT INTO programme_requirements (programme_id, position, requirement_name, rule_type,
+                                            course_codes, category, min_courses, min_credits)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `
+
+    err := db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, programmeQuery, programme.Code, programme.Name, programme.Type).Scan(
+            &programme.ID, &programme.CreatedAt)
+        if err != nil {
+            var pqErr *pq.Error
+            if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+                return fmt.Errorf("%w (%s)", ErrDuplicateProgramme, programme.Code)
+            }
+            return fmt.Errorf("failed to add programme: %w", queryError(ctx, err))
+        }
+
+        for i := range programme.Requirements {
+            requirement := &programme.Requirements[i]
+            if requirement.CourseCodes == nil {
+                requirement.CourseCodes = []string{}
+            }
+            err := tx.queryRow(ctx, requirementQuery, programme.ID, i, requirement.Name, requirement.RuleType,
+                pq.Array(requirement.CourseCodes), requirement.Category,
+                nullableInt(requirement.MinCourses), nullableInt(requirement.MinCredits),
+            ).Scan(&requirement.ID)
+            if err != nil {
+                return fmt.Errorf("failed to add programme requirement: %w", queryError(ctx, err))
+            }
+        }
+
+        return tx.recordAudit(ctx, AuditProgrammeCreated, 0, 0, nil, programme)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &programme, nil
+}
+
+// 获取所有培养方案及其毕业要求
+func (db *Database) GetAllProgrammes(ctx context.Context) ([]Programme, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    return db.queryProgrammes(ctx, `
+        SELECT id, programme_code, programme_name, programme_type, created_at
+        FROM programmes
+        ORDER BY programme_code
+    `)
+}
+
+// 获取单个培养方案，不存在时返回 nil
+func (db *Database) GetProgrammeByID(ctx context.Context, programmeID int) (*Programme, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    programmes, err := db.queryProgrammes(ctx, `
+        SELECT id, programme_code, programme_name, programme_type, created_at
+        FROM programmes
+        WHERE id = $1
+    `, programmeID)
+    if err != nil {
+        return nil, err
+    }
+    if len(programmes) == 0 {
+        return nil, nil // 培养方案不存在
+    }
+
+    return &programmes[0], nil
+}
+
+// 学生修读培养方案
+func (db *Database) DeclareProgramme(ctx context.Context, studentID, programmeID int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    studentExists, err := db.StudentExists(ctx, studentID)
+    if err != nil {
+        return fmt.Errorf("failed to check student existence: %w", queryError(ctx, err))
+    }
+    if !studentExists {
+        return fmt.Errorf("%w (ID %d)", ErrStudentNotFound, studentID)
+    }
+
+    programme, err := db.GetProgrammeByID(ctx, programmeID)
+    if err != nil {
+        return err
+    }
+    if programme == nil {
+        return fmt.Errorf("%w (ID %d)", ErrProgrammeNotFound, programmeID)
+    }
+
+    query := `
+        INSERT INTO student_programmes (student_id, programme_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `
+
+    return db.inTx(ctx, func(tx txn) error {
+        result, err := tx.exec(ctx, query, studentID, programmeID)
+        if err != nil {
+            return fmt.Errorf("failed to declare programme: %w", queryError(ctx, err))
+        }
+        inserted, err := result.RowsAffected()
+        if err != nil {
+            return fmt.Errorf("failed to get rows affected: %w", err)
+        }
+        if inserted == 0 {
+            return ErrAlreadyDeclared
+        }
+
+        return tx.recordAudit(ctx, AuditProgrammeDeclared, studentID, 0, nil,
+            map[string]any{"programme_id": programme.ID, "programme_code": programme.Code})
+    })
+}
+
+// 学生退出培养方案
+func (db *Database) UndeclareProgramme(ctx context.Context, studentID, programmeID int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `DELETE FROM student_programmes WHERE student_id = $1 AND programme_id = $2`
+
+    return db.inTx(ctx, func(tx txn) error {
+        result, err := tx.exec(ctx, query, studentID, programmeID)
+        if err != nil {
+            return fmt.Errorf("failed to undeclare programme: %w", queryError(ctx, err))
+        }
+        deleted, err := result.RowsAffected()
+        if err != nil {
+            return fmt.Errorf("failed to get rows affected: %w", err)
+        }
+        if deleted == 0 {
+            return ErrNotDeclared
+        }
+
+        return tx.recordAudit(ctx, AuditProgrammeUndeclared, studentID, 0,
+            map[string]any{"programme_id": programmeID}, nil)
+    })
+}
+
+// 学生在读课程与已修课程的汇总，按课程代码去重（重修的课程只计一次）
+type courseRecords struct {
+    passed     map[string]int // 课程代码 -> 学分
+    inProgress map[string]int
+    categories map[string]string
+}
+
+// 对学生修读的每个培养方案进行毕业审核
+func (db *Database) GetDegreeAudit(ctx context.Context, studentID int) ([]DegreeAudit, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    programmes, err := db.queryProgrammes(ctx, `
+        SELECT p.id, p.programme_code, p.programme_name, p.programme_type, p.created_at
+        FROM programmes p
+        JOIN student_programmes sp ON sp.programme_id = p.id
+        WHERE sp.student_id = $1
+        ORDER BY p.programme_type, p.programme_code
+    `, studentID)
+    if err != nil {
+        return nil, err
+    }
+
+    declared := make(map[int]time.Time)
+    rows, err := db.query(ctx, `SELECT programme_id, declared_at FROM student_programmes WHERE student_id = $1`, studentID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query student programmes: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var programmeID int
+        var declaredAt time.Time
+        if err := rows.Scan(&programmeID, &declaredAt); err != nil {
+            return nil, fmt.Errorf("failed to scan student programme: %w", queryError(ctx, err))
+        }
+        declared[programmeID] = declaredAt
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    records, err := db.studentCourseRecords(ctx, studentID)
+    if err != nil {
+        return nil, err
+    }
+
+    audits := make([]DegreeAudit, len(programmes))
+    for i, programme := range programmes {
+        audit := DegreeAudit{
+            Programme:  programme,
+            DeclaredAt: declared[programme.ID],
+            Status:     RequirementSatisfied,
+        }
+        for _, requirement := range programme.Requirements {
+            progress := records.evaluate(requirement)
+            audit.Requirements = append(audit.Requirements, progress)
+            audit.Status = worseStatus(audit.Status, progress.Status)
+        }
+        audits[i] = audit
+    }
+
+    return audits, nil
+}
+
+// 查询学生已修完（未挂科）和在读的课程
+func (db *Database) studentCourseRecords(ctx context.Context, studentID int) (*courseRecords, error) {
+    query := `
+        SELECT c.course_code, COALESCE(c.credits, 0), c.category, sc.status
+        FROM student_courses sc
+        JOIN courses c ON c.id = sc.course_id
+        WHERE sc.student_id = $1
+          AND (sc.status = 'enrolled' OR (sc.status = 'completed' AND sc.grade IS DISTINCT FROM 'F'))
+    `
+
+    rows, err := db.query(ctx, query, studentID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query course records: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    records := &courseRecords{
+        passed:     make(map[string]int),
+        inProgress: make(map[string]int),
+        categories: make(map[string]string),
+    }
+    for rows.Next() {
+        var code, category, status string
+        var credits int
+        if err := rows.Scan(&code, &credits, &category, &status); err != nil {
+            return nil, fmt.Errorf("failed to scan course record: %w", queryError(ctx, err))
+        }
+        records.categories[code] = category
+        if status == EnrollmentCompleted {
+            records.passed[code] = credits
+        } else {
+            records.inProgress[code] = credits
+        }
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    // 已修完的课程再次在读（重修）时不重复计算
+    for code := range records.passed {
+        delete(records.inProgress, code)
+    }
+
+    return records, nil
+}
+
+// 根据学生的课程记录判断一条毕业要求的完成情况
+func (r *courseRecords) evaluate(requirement Requirement) RequirementProgress {
+    progress := RequirementProgress{
+        Requirement:       requirement,
+        CompletedCourses:  []string{},
+        InProgressCourses: []string{},
+        MissingCourses:    []string{},
+    }
+
+    switch requirement.RuleType {
+    case RuleCourses:
+        for _, code := range requirement.CourseCodes {
+            if credits, ok := r.passed[code]; ok {
+                progress.CompletedCourses = append(progress.CompletedCourses, code)
+                progress.CreditsCompleted += credits
+            } else if credits, ok := r.inProgress[code]; ok {
+                progress.InProgressCourses = append(progress.InProgressCourses, code)
+                progress.CreditsInProgress += credits
+            } else {
+                progress.MissingCourses = append(progress.MissingCourses, code)
+            }
+        }
+
+        required := requirement.MinCourses
+        if required <= 0 {
+            required = len(requirement.CourseCodes)
+        }
+        progress.Status = requirementStatus(len(progress.CompletedCourses), len(progress.InProgressCourses), required)
+
+    case RuleCredits:
+        matches := func(code string) bool {
+            if requirement.Category != "" && r.categories[code] != requirement.Category {
+                return false
+            }
+            if len(requirement.CourseCodes) > 0 && !slices.Contains(requirement.CourseCodes, code) {
+                return false
+            }
+            return true
+        }
+        for code, credits := range r.passed {
+            if matches(code) {
+                progress.CompletedCourses = append(progress.CompletedCourses, code)
+                progress.CreditsCompleted += credits
+            }
+        }
+        for code, credits := range r.inProgress {
+            if matches(code) {
+                progress.InProgressCourses = append(progress.InProgressCourses, code)
+                progress.CreditsInProgress += credits
+            }
+        }
+        sort.Strings(progress.CompletedCourses)
+        sort.Strings(progress.InProgressCourses)
+
+        progress.Status = requirementStatus(progress.CreditsCompleted, progress.CreditsInProgress, requirement.MinCredits)
+    }
+
+    return progress
+}
+
+func requirementStatus(completed, inProgress, required int) string {
+    switch {
+    case completed >= required:
+        return RequirementSatisfied
+    case completed+inProgress >= required:
+        return RequirementInProgress
+    default:
+        return RequirementMissing
+    }
+}
+
+// 培养方案的总体状态取各要求中最差的一项
+func worseStatus(a, b string) string {
+    rank := map[string]int{RequirementSatisfied: 0, RequirementInProgress: 1, RequirementMissing: 2}
+    if rank[b] > rank[a] {
+        return b
+    }
+    return a
+}
+
+// 查询培养方案并加载各自的毕业要求
+func (db *Database) queryProgrammes(ctx context.Context, query string, args ...any) ([]Programme, error) {
+    rows, err := db.query(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query programmes: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    programmes := []Programme{}
+    index := make(map[int]int)
+    var ids []int64
+    for rows.Next() {
+        var programme Programme
+        err := rows.Scan(&programme.ID, &programme.Code, &programme.Name, &programme.Type, &programme.CreatedAt)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan programme: %w", queryError(ctx, err))
+        }
+        programme.Requirements = []Requirement{}
+        index[programme.ID] = len(programmes)
+        ids = append(ids, int64(programme.ID))
+        programmes = append(programmes, programme)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+    rows.Close()
+
+    if len(programmes) == 0 {
+        return programmes, nil
+    }
+
+    requirementQuery := `
+        SELECT id, programme_id, requirement_name, rule_type, course_codes, category,
+               COALESCE(min_courses, 0), COALESCE(min_credits, 0)
+        FROM programme_requirements
+        WHERE programme_id = ANY($1)
+        ORDER BY programme_id, position, id
+    `
+
+    requirementRows, err := db.query(ctx, requirementQuery, pq.Array(ids))
+    if err != nil {
+        return nil, fmt.Errorf("failed to query programme requirements: %w", queryError(ctx, err))
+    }
+    defer requirementRows.Close()
+
+    for requirementRows.Next() {
+        var requirement Requirement
+        var programmeID int
+        err := requirementRows.Scan(
+            &requirement.ID, &programmeID, &requirement.Name, &requirement.RuleType,
+            pq.Array(&requirement.CourseCodes), &requirement.Category,
+            &requirement.MinCourses, &requirement.MinCredits,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan programme requirement: %w", queryError(ctx, err))
+        }
+        i := index[programmeID]
+        programmes[i].Requirements = append(programmes[i].Requirements, requirement)
+    }
+    if err = requirementRows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return programmes, nil
+}
+
+// 0 表示未设置，记为 NULL
+func nullableInt(value int) any {
+    if value <= 0 {
+        return nil
+    }
+    return value
+}
@@ -0,0 +1,273 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// 教室。课程关联教室后，course_location 显示为教室名称，排课时检查容量和时间冲突
+type Room struct {
+    ID        int       `json:"id"`
+    Building  string    `json:"building"`
+    Room      string    `json:"room"`
+    Capacity  int       `json:"capacity"`
+    Features  []string  `json:"features"` // 设施，如 projector、computers
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// 教室的显示名称，如 "CYC LT1"
+func (r Room) Label() string {
+    return r.Building + " " + r.Room
+}
+
+// 教室查询条件，零值表示不限制
+type RoomFilter struct {
+    MinCapacity int
+    Feature     string
+}
+
+// 课程排课信息的修改，字段为 nil 表示不修改
+type CourseSchedule struct {
+    TimeSlot *string
+    RoomID   *int
+    Capacity *int
+}
+
+const roomColumns = `id, building, room, capacity, features, created_at`
+
+func roomFields(r *Room) []any {
+    return []any{&r.ID, &r.Building, &r.Room, &r.Capacity, pq.Array(&r.Features), &r.CreatedAt}
+}
+
+// 获取教室列表，按楼宇和教室号排序
+func (db *Database) GetRooms(ctx context.Context, filter RoomFilter) ([]Room, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT ` + roomColumns + `
+        FROM rooms
+        WHERE capacity >= $1 AND ($2 = '' OR $2 = ANY(features))
+        ORDER BY building, room
+    `
+
+    rows, err := db.query(ctx, query, filter.MinCapacity, filter.Feature)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query rooms: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    rooms := []Room{}
+    for rows.Next() {
+        var room Room
+        if err := rows.Scan(roomFields(&room)...); err != nil {
+            return nil, fmt.Errorf("failed to scan room: %w", queryError(ctx, err))
+        }
+        rooms = append(rooms, room)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return rooms, nil
+}
+
+// 获取单个教室，不存在时返回 nil
+func (db *Database) GetRoomByID(ctx context.Context, roomID int) (*Room, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `SELECT ` + roomColumns + ` FROM rooms WHERE id = $1`
+
+    var room Room
+    err := db.queryRow(ctx, query, roomID).Scan(roomFields(&room)...)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get room: %w", queryError(ctx, err))
+    }
+
+    return &room, nil
+}
+
+// 添加教室 (管理员功能)，同一楼宇内教室号不能重复
+func (db *Database) AddRoom(ctx context.Context, room Room) (*Room, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    if room.Features == nil {
+        room.Features = []string{}
+    }
+
+    query := `
+        INSERT INTO rooms (building, room, capacity, features)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `
+
+    err := db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, query, room.Building, room.Room, room.Capacity, pq.Array(room.Features)).Scan(
+            &room.ID, &room.CreatedAt)
+        if err != nil {
+            var pqErr *pq.Error
+            if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+                return fmt.Errorf("%w (%s)", ErrDuplicateRoom, room.Label())
+            }
+            return fmt.Errorf("failed to add room: %w", queryError(ctx, err))
+        }
+
+        return tx.recordAudit(ctx, AuditRoomCreated, 0, 0, nil, room)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &room, nil
+}
+
+// 修改课程的上课时间、教室或容量 (管理员功能)，修改后的安排需通过教室容量和时间冲突检查。扩容后由候补学生递补
+func (db *Database) UpdateCourseSchedule(ctx context.Context, courseID int, schedule CourseSchedule) (*Course, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    selectQuery := `SELECT ` + courseColumns + ` FROM courses WHERE id = $1 FOR UPDATE`
+
+    updateQuery := `
+        UPDATE courses
+        SET time_slot = $2, room_id = $3, capacity = $4, course_location = $5
+        WHERE id = $1
+        RETURNING ` + courseColumns
+
+    var before, after Course
+    err := db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, selectQuery, courseID).Scan(courseFields(&before)...)
+        if err == sql.ErrNoRows {
+            return fmt.Errorf("%w (ID %d)", ErrCourseNotFound, courseID)
+        }
+        if err != nil {
+            return fmt.Errorf("failed to get course: %w", queryError(ctx, err))
+        }
+
+        after = before
+        if schedule.TimeSlot != nil {
+            after.TimeSlot = *schedule.TimeSlot
+        }
+        if schedule.RoomID != nil {
+            after.RoomID = schedule.RoomID
+        }
+        if schedule.Capacity != nil {
+            after.Capacity = schedule.Capacity
+        }
+
+        if after.RoomID != nil {
+            room, err := tx.checkRoomBooking(ctx, courseID, after.Semester, after.TimeSlot, *after.RoomID, after.Capacity)
+            if err != nil {
+                return err
+            }
+            after.CourseLocation = room.Label()
+            // 安排在教室的课程不能不限人数，未指定容量时取教室容量
+            if after.Capacity == nil {
+                after.Capacity = &room.Capacity
+            }
+        }
+
+        err = tx.queryRow(ctx, updateQuery, courseID, after.TimeSlot, after.RoomID, after.Capacity,
+            after.CourseLocation).Scan(courseFields(&after)...)
+        if err != nil {
+            return fmt.Errorf("failed to update course schedule: %w", queryError(ctx, err))
+        }
+
+        if err := tx.recordAudit(ctx, AuditCourseUpdated, 0, courseID, before, after); err != nil {
+            return err
+        }
+        tx.publishSeats(courseID)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    if capacityIncreased(before.Capacity, after.Capacity) {
+        db.promoteWaitlists(ctx, courseID)
+    }
+    return &after, nil
+}
+
+// 课程容量是否增加，nil 表示不限人数
+func capacityIncreased(before, after *int) bool {
+    switch {
+    case before == nil:
+        return false
+    case after == nil:
+        return true
+    default:
+        return *after > *before
+    }
+}
+
+// 检查课程能否安排在该教室：教室容量不小于课程容量，且同学期没有其他课程或教学班在重叠的时间使用该教室。
+// 教室行加锁，同一教室的排课串行执行。courseID 为 0 表示新建课程，capacity 为 nil 时调用方应将课程容量设为教室容量
+func (tx txn) checkRoomBooking(ctx context.Context, courseID int, semester, timeSlot string, roomID int, capacity *int) (*Room, error) {
+    var room Room
+    err := tx.queryRow(ctx, `SELECT `+roomColumns+` FROM rooms WHERE id = $1 FOR UPDATE`, roomID).Scan(roomFields(&room)...)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("%w (ID %d)", ErrRoomNotFound, roomID)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to lock room: %w", queryError(ctx, err))
+    }
+
+    if capacity != nil && *capacity > room.Capacity {
+        return nil, fmt.Errorf("%w: %s seats %d, course needs %d", ErrRoomTooSmall, room.Label(), room.Capacity, *capacity)
+    }
+
+    meetings, err := parseTimeSlot(timeSlot)
+    if err != nil {
+        return nil, err
+    }
+
+    // 自动排课会为教学班安排教室，教学班占用的时间同样不能与课程重叠
+    query := `
+        SELECT course_code, COALESCE(time_slot, '')
+        FROM courses
+        WHERE room_id = $1 AND id <> $2 AND COALESCE(semester, '') = $3
+        UNION ALL
+        SELECT c.course_code || ' ' || s.section_code, COALESCE(s.time_slot, '')
+        FROM course_sections s
+        JOIN courses c ON c.id = s.course_id
+        WHERE s.room_id = $1 AND COALESCE(c.semester, '') = $3
+    `
+
+    rows, err := tx.query(ctx, query, roomID, courseID, semester)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query room bookings: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var courseCode, otherSlot string
+        if err := rows.Scan(&courseCode, &otherSlot); err != nil {
+            return nil, fmt.Errorf("failed to scan room booking: %w", queryError(ctx, err))
+        }
+        // 无法解析的旧数据不参与冲突检查
+        other, err := parseTimeSlot(otherSlot)
+        if err != nil {
+            continue
+        }
+        if meetingsOverlap(meetings, other) {
+            return nil, fmt.Errorf("%w: %s at %s", ErrRoomDoubleBooked, courseCode, otherSlot)
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return &room, nil
+}
@@ -0,0 +1,747 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "math/rand"
+    "sort"
+    "time"
+
+    "course-management/logging"
+
+    "github.com/lib/pq"
+)
+
+// 选课轮次状态。轮次分配完成前，该学期的课程只能通过提交志愿选课
+const (
+    RoundOpen       = "open"
+    RoundAllocating = "allocating"
+    RoundAllocated  = "allocated"
+)
+
+// 志愿分配结果
+const (
+    RoundOutcomeEnrolled = "enrolled"
+    RoundOutcomeFailed   = "failed"
+)
+
+// 每个学生在一个轮次中最多提交的志愿数
+const maxRoundPreferences = 10
+
+// 每批分配的志愿数。每批在一个事务中完成，失败时只回滚当前批次。每个志愿的选课检查需要十次左右的查询，
+// 批次较小使每批在数秒内提交，锁定的学生和课程也能尽快释放
+const roundAllocationBatch = 50
+
+// 选课轮次：学生在 OpensAt 到 ClosesAt 之间提交按顺序排列的志愿，窗口关闭后由管理员执行分配。
+// Seed 决定同等优先级学生的抽签顺序，分配后公开，相同的数据和 Seed 总是得到相同的结果。
+// AllocationError 为最近一次分配中断的原因，重新执行分配时清空
+type RegistrationRound struct {
+    ID              int        `json:"id"`
+    Name            string     `json:"name"`
+    Semester        string     `json:"semester"`
+    OpensAt         time.Time  `json:"opens_at"`
+    ClosesAt        time.Time  `json:"closes_at"`
+    Seed            int64      `json:"seed"`
+    Status          string     `json:"status"`
+    AllocationError string     `json:"allocation_error"`
+    AllocatedAt     *time.Time `json:"allocated_at"`
+    CreatedAt       time.Time  `json:"created_at"`
+}
+
+// 轮次当前是否接受志愿
+func (r RegistrationRound) AcceptsPreferences(now time.Time) bool {
+    return r.Status == RoundOpen && !now.Before(r.OpensAt) && now.Before(r.ClosesAt)
+}
+
+// 学生的一个志愿，Rank 从 1 开始，越小越优先
+type RoundPreference struct {
+    Rank       int    `json:"rank"`
+    CourseID   int    `json:"course_id"`
+    CourseCode string `json:"course_code"`
+    CourseName string `json:"course_name"`
+    SectionIDs []int  `json:"section_ids"`
+}
+
+// 一个志愿的分配结果及决定顺序的依据，用于公示和核查
+type RoundResult struct {
+    StudentID     int    `json:"student_id"`
+    CourseID      int    `json:"course_id"`
+    CourseCode    string `json:"course_code"`
+    Rank          int    `json:"rank"`
+    Priority      int    `json:"priority"`       // 0 表示课程属于学生主修专业的培养方案
+    EarnedCredits int    `json:"earned_credits"` // 已修学分，代表年级
+    Ticket        int    `json:"ticket"`         // 抽签号，由 Seed 决定
+    Outcome       string `json:"outcome"`
+    Reason        string `json:"reason"`
+}
+
+// 分配结果汇总。NewlyEnrolled 为本次执行选上的志愿数，中断后重新执行时不含之前批次的结果
+type AllocationSummary struct {
+    RoundID       int   `json:"round_id"`
+    Seed          int64 `json:"seed"`
+    Requests      int   `json:"requests"`
+    Enrolled      int   `json:"enrolled"`
+    Failed        int   `json:"failed"`
+    NewlyEnrolled int   `json:"-"`
+}
+
+const roundColumns = `id, name, semester, opens_at, closes_at, seed, status, allocation_error, allocated_at, created_at`
+
+func roundFields(r *RegistrationRound) []any {
+    return []any{&r.ID, &r.Name, &r.Semester, &r.OpensAt, &r.ClosesAt, &r.Seed, &r.Status, &r.AllocationError,
+        &r.AllocatedAt, &r.CreatedAt}
+}
+
+// 获取所有选课轮次，最近的在前
+func (db *Database) GetRounds(ctx context.Context) ([]RegistrationRound, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    rows, err := db.query(ctx, `SELECT `+roundColumns+` FROM registration_rounds ORDER BY opens_at DESC, id DESC`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query registration rounds: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    rounds := []RegistrationRound{}
+    for rows.Next() {
+        var round RegistrationRound
+        if err := rows.Scan(roundFields(&round)...); err != nil {
+            return nil, fmt.Errorf("failed to scan registration round: %w", queryError(ctx, err))
+        }
+        rounds = append(rounds, round)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return rounds, nil
+}
+
+// 获取单个选课轮次，不存在时返回 nil
+func (db *Database) GetRoundByID(ctx context.Context, roundID int) (*RegistrationRound, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    var round RegistrationRound
+    err := db.queryRow(ctx, `SELECT `+roundColumns+` FROM registration_rounds WHERE id = $1`, roundID).Scan(roundFields(&round)...)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get registration round: %w", queryError(ctx, err))
+    }
+
+    return &round, nil
+}
+
+// 获取处于分配中的轮次ID，用于服务器启动时继续上次中断的分配
+func (db *Database) GetAllocatingRoundIDs(ctx context.Context) ([]int, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    rows, err := db.query(ctx, `SELECT id FROM registration_rounds WHERE status = $1 ORDER BY id`, RoundAllocating)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query allocating rounds: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    var roundIDs []int
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("failed to scan round id: %w", queryError(ctx, err))
+        }
+        roundIDs = append(roundIDs, id)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return roundIDs, nil
+}
+
+// 创建选课轮次 (管理员功能)。Seed 为 0 时随机生成。同一学期同时只能有一个未分配的轮次
+func (db *Database) CreateRound(ctx context.Context, round RegistrationRound) (*RegistrationRound, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    if round.Seed == 0 {
+        round.Seed = rand.Int63()
+    }
+
+    query := `
+        INSERT INTO registration_rounds (name, semester, opens_at, closes_at, seed)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING ` + roundColumns
+
+    var created RegistrationRound
+    err := db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, query, round.Name, round.Semester, round.OpensAt.UTC(), round.ClosesAt.UTC(), round.Seed).Scan(
+            roundFields(&created)...)
+        if err != nil {
+            var pqErr *pq.Error
+            if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+                return fmt.Errorf("%w (%s)", ErrDuplicateRound, round.Semester)
+            }
+            return fmt.Errorf("failed to create registration round: %w", queryError(ctx, err))
+        }
+
+        return tx.recordAudit(ctx, AuditRoundCreated, 0, 0, nil, created)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &created, nil
+}
+
+// 获取学生在轮次中的志愿，按顺序排列
+func (db *Database) GetRoundPreferences(ctx context.Context, roundID, studentID int) ([]RoundPreference, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT p.rank, p.course_id, c.course_code, c.course_name, p.section_ids
+        FROM round_preferences p
+        JOIN courses c ON c.id = p.course_id
+        WHERE p.round_id = $1 AND p.student_id = $2
+        ORDER BY p.rank
+    `
+
+    rows, err := db.query(ctx, query, roundID, studentID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query round preferences: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    preferences := []RoundPreference{}
+    for rows.Next() {
+        var preference RoundPreference
+        var sectionIDs pq.Int64Array
+        err := rows.Scan(&preference.Rank, &preference.CourseID, &preference.CourseCode, &preference.CourseName, &sectionIDs)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan round preference: %w", queryError(ctx, err))
+        }
+        preference.SectionIDs = make([]int, len(sectionIDs))
+        for i, id := range sectionIDs {
+            preference.SectionIDs[i] = int(id)
+        }
+        preferences = append(preferences, preference)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return preferences, nil
+}
+
+// 提交学生的志愿，整体替换之前提交的志愿，列表顺序即志愿顺序。只能在轮次开放期间提交，
+// 课程须属于轮次的学期且不能重复。提交空列表表示放弃本轮
+func (db *Database) SetRoundPreferences(ctx context.Context, roundID, studentID int, preferences []RoundPreference) ([]RoundPreference, error) {
+    if len(preferences) > maxRoundPreferences {
+        return nil, fmt.Errorf("%w: at most %d preferences", ErrInvalidPreferences, maxRoundPreferences)
+    }
+
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    round, err := db.GetRoundByID(ctx, roundID)
+    if err != nil {
+        return nil, err
+    }
+    if round == nil {
+        return nil, fmt.Errorf("%w (ID %d)", ErrRoundNotFound, roundID)
+    }
+    if !round.AcceptsPreferences(time.Now()) {
+        return nil, fmt.Errorf("%w (%s)", ErrRegistrationClosed, round.Name)
+    }
+
+    exists, err := db.StudentExists(ctx, studentID)
+    if err != nil {
+        return nil, err
+    }
+    if !exists {
+        return nil, fmt.Errorf("%w (ID %d)", ErrStudentNotFound, studentID)
+    }
+
+    seen := make(map[int]bool)
+    for _, preference := range preferences {
+        if seen[preference.CourseID] {
+            return nil, fmt.Errorf("%w: course %d listed more than once", ErrInvalidPreferences, preference.CourseID)
+        }
+        seen[preference.CourseID] = true
+
+        course, err := db.GetCourseByID(ctx, preference.CourseID)
+        if err != nil {
+            return nil, err
+        }
+        if course == nil {
+            return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, preference.CourseID)
+        }
+        if course.Semester != round.Semester {
+            return nil, fmt.Errorf("%w: %s is not offered in %s", ErrInvalidPreferences, course.CourseCode, round.Semester)
+        }
+    }
+
+    insertQuery := `
+        INSERT INTO round_preferences (round_id, student_id, rank, course_id, section_ids)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+
+    err = db.inTx(ctx, func(tx txn) error {
+        _, err := tx.exec(ctx, `DELETE FROM round_preferences WHERE round_id = $1 AND student_id = $2`, roundID, studentID)
+        if err != nil {
+            return fmt.Errorf("failed to clear round preferences: %w", queryError(ctx, err))
+        }
+
+        for i, preference := range preferences {
+            sectionIDs := preference.SectionIDs
+            if sectionIDs == nil {
+                sectionIDs = []int{}
+            }
+            if _, err := tx.exec(ctx, insertQuery, roundID, studentID, i+1, preference.CourseID, pq.Array(sectionIDs)); err != nil {
+                return fmt.Errorf("failed to add round preference: %w", queryError(ctx, err))
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return db.GetRoundPreferences(ctx, roundID, studentID)
+}
+
+// 轮次的分配结果，studentID 不为 0 时只返回该学生的结果
+func (db *Database) GetRoundResults(ctx context.Context, roundID, studentID int) ([]RoundResult, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT r.student_id, r.course_id, c.course_code, r.rank, r.priority, r.earned_credits, r.ticket, r.outcome, r.reason
+        FROM round_results r
+        JOIN courses c ON c.id = r.course_id
+        WHERE r.round_id = $1 AND ($2 = 0 OR r.student_id = $2)
+        ORDER BY r.student_id, r.rank
+    `
+
+    rows, err := db.query(ctx, query, roundID, studentID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query round results: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    results := []RoundResult{}
+    for rows.Next() {
+        var r RoundResult
+        err := rows.Scan(&r.StudentID, &r.CourseID, &r.CourseCode, &r.Rank, &r.Priority, &r.EarnedCredits, &r.Ticket, &r.Outcome, &r.Reason)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan round result: %w", queryError(ctx, err))
+        }
+        results = append(results, r)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return results, nil
+}
+
+// 开始分配轮次的志愿 (管理员功能)，须在窗口关闭后执行。轮次标记为分配中，之后由 AllocateRound 分批完成分配。
+// 分配中断的轮次可以再次调用，从未处理的志愿继续
+func (db *Database) StartRoundAllocation(ctx context.Context, roundID int) (*RegistrationRound, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        UPDATE registration_rounds SET status = $2, allocation_error = ''
+        WHERE id = $1
+        RETURNING ` + roundColumns
+
+    var started RegistrationRound
+    err := db.inTx(ctx, func(tx txn) error {
+        round, err := tx.lockRound(ctx, roundID)
+        if err != nil {
+            return err
+        }
+        if round.Status == RoundAllocated {
+            return fmt.Errorf("%w (%s)", ErrRoundAllocated, round.Name)
+        }
+        if time.Now().Before(round.ClosesAt) {
+            return fmt.Errorf("%w: %s closes at %s", ErrRoundNotClosed, round.Name, round.ClosesAt.Format(time.RFC3339))
+        }
+
+        if err := tx.queryRow(ctx, query, roundID, RoundAllocating).Scan(roundFields(&started)...); err != nil {
+            return fmt.Errorf("failed to update registration round: %w", queryError(ctx, err))
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &started, nil
+}
+
+// 分批完成分配中轮次的分配，适合在请求之外执行。
+// 按志愿顺序逐轮处理：先处理所有学生的第一志愿，再处理第二志愿，依此类推。同一轮中，
+// 课程属于学生主修专业培养方案的优先，其次已修学分多的优先，最后按抽签号。
+// 每个志愿按与选课相同的规则选课（容量、先修课程、学分上限、时间冲突等），不满足时记为失败并继续。
+// 处理顺序在开始时计算一次，之后按该顺序每批使用单独的事务和超时，已提交批次的结果保留；
+// 中断时原因记录到轮次，重新执行从下一个未处理的志愿继续，处理顺序与一次完成时相同
+func (db *Database) AllocateRound(ctx context.Context, roundID int) (*AllocationSummary, error) {
+    pending, err := db.pendingRoundRequests(ctx, roundID)
+    if err != nil {
+        db.recordAllocationError(ctx, roundID, err)
+        return nil, err
+    }
+
+    enrolled := 0
+    for {
+        n := min(len(pending), roundAllocationBatch)
+        batchEnrolled, summary, err := db.allocateRoundBatch(ctx, roundID, pending[:n], n == len(pending))
+        if err != nil {
+            db.recordAllocationError(ctx, roundID, err)
+            return nil, err
+        }
+        enrolled += batchEnrolled
+        if summary != nil {
+            summary.NewlyEnrolled = enrolled
+            return summary, nil
+        }
+        pending = pending[n:]
+    }
+}
+
+// 按处理顺序返回分配中轮次尚未处理的志愿。抽签号和顺序按全部志愿计算，已处理的志愿总是排在前面
+func (db *Database) pendingRoundRequests(ctx context.Context, roundID int) ([]roundRequest, error) {
+    round, err := db.GetRoundByID(ctx, roundID)
+    if err != nil {
+        return nil, err
+    }
+    if round == nil {
+        return nil, fmt.Errorf("%w (ID %d)", ErrRoundNotFound, roundID)
+    }
+    switch round.Status {
+    case RoundAllocated:
+        return nil, fmt.Errorf("%w (%s)", ErrRoundAllocated, round.Name)
+    case RoundOpen:
+        return nil, fmt.Errorf("%w (%s)", ErrRoundNotAllocating, round.Name)
+    }
+
+    requests, err := db.roundRequests(ctx, roundID)
+    if err != nil {
+        return nil, err
+    }
+    orderRoundRequests(requests, round.Seed)
+
+    var pending []roundRequest
+    for _, request := range requests {
+        if !request.processed {
+            pending = append(pending, request)
+        }
+    }
+    return pending, nil
+}
+
+// 分配一批志愿，返回本批选上的志愿数。last 为 true 时这是最后一批，处理后将轮次标记为已分配并返回汇总。
+// 同时执行的另一次分配已处理的志愿会被跳过
+func (db *Database) allocateRoundBatch(ctx context.Context, roundID int, batch []roundRequest, last bool) (int, *AllocationSummary, error) {
+    ctx, cancel := db.withBatchTimeout(ctx, len(batch))
+    defer cancel()
+
+    enrolled := 0
+    var summary *AllocationSummary
+    err := db.inTx(ctx, func(tx txn) error {
+        // 锁定轮次，同时执行的分配逐批串行
+        round, err := tx.lockRound(ctx, roundID)
+        if err != nil {
+            return err
+        }
+        switch round.Status {
+        case RoundAllocated:
+            return fmt.Errorf("%w (%s)", ErrRoundAllocated, round.Name)
+        case RoundOpen:
+            return fmt.Errorf("%w (%s)", ErrRoundNotAllocating, round.Name)
+        }
+
+        batch, err := tx.unprocessedRoundRequests(ctx, roundID, batch)
+        if err != nil {
+            return err
+        }
+
+        studentIDs := make([]int, 0, len(batch))
+        for _, request := range batch {
+            studentIDs = append(studentIDs, request.StudentID)
+        }
+        _, err = tx.exec(ctx, `SELECT id FROM students WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(studentIDs))
+        if err != nil {
+            return fmt.Errorf("failed to lock students: %w", queryError(ctx, err))
+        }
+
+        resultQuery := `
+            INSERT INTO round_results (round_id, student_id, course_id, rank, priority, earned_credits, ticket, outcome, reason)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        `
+
+        for _, request := range batch {
+            if err := tx.savepoint(ctx, "round_request"); err != nil {
+                return err
+            }
+
+            result := request.RoundResult
+            _, err := tx.enrollWithOverrides(ctx, request.StudentID, request.CourseID, request.sectionIDs,
+                []string{OverrideRegistrationWindow})
+            switch {
+            case err == nil:
+                if err := tx.releaseSavepoint(ctx, "round_request"); err != nil {
+                    return err
+                }
+                result.Outcome = RoundOutcomeEnrolled
+                enrolled++
+            case isEnrollmentRuleError(err):
+                if err := tx.rollbackToSavepoint(ctx, "round_request"); err != nil {
+                    return err
+                }
+                result.Outcome = RoundOutcomeFailed
+                result.Reason = err.Error()
+            default:
+                return err
+            }
+
+            _, err = tx.exec(ctx, resultQuery, roundID, result.StudentID, result.CourseID, result.Rank, result.Priority,
+                result.EarnedCredits, result.Ticket, result.Outcome, result.Reason)
+            if err != nil {
+                return fmt.Errorf("failed to record round result: %w", queryError(ctx, err))
+            }
+        }
+        if !last {
+            return nil
+        }
+
+        // 所有志愿处理完毕，之后该学期的选课不再受轮次限制
+        _, err = tx.exec(ctx, `UPDATE registration_rounds SET status = $2, allocated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+            roundID, RoundAllocated)
+        if err != nil {
+            return fmt.Errorf("failed to update registration round: %w", queryError(ctx, err))
+        }
+
+        summary = &AllocationSummary{RoundID: roundID, Seed: round.Seed}
+        err = tx.queryRow(ctx, `
+            SELECT COUNT(*) FILTER (WHERE outcome = 'enrolled'), COUNT(*) FILTER (WHERE outcome = 'failed')
+            FROM round_results
+            WHERE round_id = $1
+        `, roundID).Scan(&summary.Enrolled, &summary.Failed)
+        if err != nil {
+            return fmt.Errorf("failed to count round results: %w", queryError(ctx, err))
+        }
+        summary.Requests = summary.Enrolled + summary.Failed
+        return tx.recordAudit(ctx, AuditRoundAllocated, 0, 0, nil, summary)
+    })
+    if err != nil {
+        return 0, nil, err
+    }
+
+    return enrolled, summary, nil
+}
+
+// 分配一批志愿的超时时间。一批的查询在同一事务中依次执行，单次查询的超时不足以完成整批，
+// 因此在一次查询超时（锁定和汇总）之外，每个志愿再按十分之一的查询超时计算
+func (db *Database) withBatchTimeout(ctx context.Context, requests int) (context.Context, context.CancelFunc) {
+    if db.queryTimeout <= 0 {
+        return context.WithCancel(ctx)
+    }
+    return context.WithTimeout(ctx, db.queryTimeout+time.Duration(requests)*db.queryTimeout/10)
+}
+
+// 去掉批次中已有分配结果的志愿（由同时执行的另一次分配处理），须在锁定轮次后调用
+func (tx txn) unprocessedRoundRequests(ctx context.Context, roundID int, batch []roundRequest) ([]roundRequest, error) {
+    studentIDs := make([]int, 0, len(batch))
+    for _, request := range batch {
+        studentIDs = append(studentIDs, request.StudentID)
+    }
+
+    rows, err := tx.query(ctx, `SELECT student_id, course_id FROM round_results WHERE round_id = $1 AND student_id = ANY($2)`,
+        roundID, pq.Array(studentIDs))
+    if err != nil {
+        return nil, fmt.Errorf("failed to query round results: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    type key struct{ studentID, courseID int }
+    processed := make(map[key]bool)
+    for rows.Next() {
+        var k key
+        if err := rows.Scan(&k.studentID, &k.courseID); err != nil {
+            return nil, fmt.Errorf("failed to scan round result: %w", queryError(ctx, err))
+        }
+        processed[k] = true
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    unprocessed := make([]roundRequest, 0, len(batch))
+    for _, request := range batch {
+        if !processed[key{request.StudentID, request.CourseID}] {
+            unprocessed = append(unprocessed, request)
+        }
+    }
+    return unprocessed, nil
+}
+
+// 记录分配中断的原因，供管理员查看轮次时了解情况。请求可能已取消，记录不受其影响
+func (db *Database) recordAllocationError(ctx context.Context, roundID int, cause error) {
+    ctx, cancel := db.withTimeout(context.WithoutCancel(ctx))
+    defer cancel()
+
+    _, err := db.exec(ctx, `UPDATE registration_rounds SET allocation_error = $2 WHERE id = $1 AND status = $3`,
+        roundID, cause.Error(), RoundAllocating)
+    if err != nil {
+        logging.FromContext(ctx).Warn("记录分配中断原因失败", "round_id", roundID, "error", err)
+    }
+}
+
+// 事务中锁定轮次
+func (tx txn) lockRound(ctx context.Context, roundID int) (*RegistrationRound, error) {
+    var round RegistrationRound
+    err := tx.queryRow(ctx, `SELECT `+roundColumns+` FROM registration_rounds WHERE id = $1 FOR UPDATE`, roundID).Scan(
+        roundFields(&round)...)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("%w (ID %d)", ErrRoundNotFound, roundID)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to lock registration round: %w", queryError(ctx, err))
+    }
+    return &round, nil
+}
+
+// 待分配的志愿及其排序依据。processed 表示之前的批次已处理该志愿
+type roundRequest struct {
+    RoundResult
+    sectionIDs []int
+    processed  bool
+}
+
+// 读取轮次的所有志愿，并计算学生对每门课程的优先级和已修学分。已修学分按学生汇总一次
+func (db *Database) roundRequests(ctx context.Context, roundID int) ([]roundRequest, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        WITH earned AS (
+            SELECT sc.student_id, SUM(ec.credits) AS credits
+            FROM student_courses sc
+            JOIN courses ec ON ec.id = sc.course_id
+            WHERE sc.status = 'completed' AND sc.grade IS DISTINCT FROM 'F'
+              AND sc.student_id IN (SELECT student_id FROM round_preferences WHERE round_id = $1)
+            GROUP BY sc.student_id
+        )
+        SELECT p.student_id, p.course_id, c.course_code, p.rank, p.section_ids,
+               CASE WHEN EXISTS (
+                   SELECT 1
+                   FROM student_programmes sp
+                   JOIN programmes pg ON pg.id = sp.programme_id
+                   JOIN programme_requirements r ON r.programme_id = pg.id
+                   WHERE sp.student_id = p.student_id AND pg.programme_type = 'major'
+                     AND (c.course_code = ANY(r.course_codes)
+                          OR (r.rule_type = 'credits' AND r.category <> '' AND r.category = c.category))
+               ) THEN 0 ELSE 1 END,
+               COALESCE(e.credits, 0),
+               rr.student_id IS NOT NULL
+        FROM round_preferences p
+        JOIN courses c ON c.id = p.course_id
+        LEFT JOIN earned e ON e.student_id = p.student_id
+        LEFT JOIN round_results rr
+            ON rr.round_id = p.round_id AND rr.student_id = p.student_id AND rr.course_id = p.course_id
+        WHERE p.round_id = $1
+    `
+
+    rows, err := db.query(ctx, query, roundID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query round preferences: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    var requests []roundRequest
+    for rows.Next() {
+        var r roundRequest
+        var sectionIDs pq.Int64Array
+        err := rows.Scan(&r.StudentID, &r.CourseID, &r.CourseCode, &r.Rank, &sectionIDs, &r.Priority, &r.EarnedCredits,
+            &r.processed)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan round preference: %w", queryError(ctx, err))
+        }
+        r.sectionIDs = make([]int, len(sectionIDs))
+        for i, id := range sectionIDs {
+            r.sectionIDs[i] = int(id)
+        }
+        requests = append(requests, r)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return requests, nil
+}
+
+// 为每个学生抽签并确定志愿的处理顺序。抽签号由 seed 对按ID排序的学生做随机排列得到，
+// 因此只取决于 seed 和参与的学生
+func orderRoundRequests(requests []roundRequest, seed int64) {
+    var students []int
+    seen := make(map[int]bool)
+    for _, r := range requests {
+        if !seen[r.StudentID] {
+            seen[r.StudentID] = true
+            students = append(students, r.StudentID)
+        }
+    }
+    sort.Ints(students)
+
+    tickets := make(map[int]int, len(students))
+    for i, p := range rand.New(rand.NewSource(seed)).Perm(len(students)) {
+        tickets[students[i]] = p + 1
+    }
+
+    for i := range requests {
+        requests[i].Ticket = tickets[requests[i].StudentID]
+    }
+
+    sort.Slice(requests, func(i, j int) bool {
+        a, b := requests[i], requests[j]
+        switch {
+        case a.Rank != b.Rank:
+            return a.Rank < b.Rank
+        case a.Priority != b.Priority:
+            return a.Priority < b.Priority
+        case a.EarnedCredits != b.EarnedCredits:
+            return a.EarnedCredits > b.EarnedCredits
+        case a.Ticket != b.Ticket:
+            return a.Ticket < b.Ticket
+        }
+        return a.CourseID < b.CourseID
+    })
+}
+
+// 学期是否有尚未分配完成的选课轮次。此时该学期的课程只能通过轮次选课
+func (tx txn) checkRoundPending(ctx context.Context, course Course) error {
+    var name string
+    err := tx.queryRow(ctx, `SELECT name FROM registration_rounds WHERE semester = $1 AND status <> 'allocated'`,
+        course.Semester).Scan(&name)
+    if err == sql.ErrNoRows {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("failed to check registration rounds: %w", queryError(ctx, err))
+    }
+    return fmt.Errorf("%w: %s is allocated by %s", ErrRoundPending, course.CourseCode, name)
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func roundTestRequests() []roundRequest {
+    request := func(studentID, courseID, rank, priority, earnedCredits int) roundRequest {
+        return roundRequest{RoundResult: RoundResult{
+            StudentID: studentID, CourseID: courseID, Rank: rank, Priority: priority, EarnedCredits: earnedCredits,
+        }}
+    }
+    var requests []roundRequest
+    // 十名学生的第一、第二志愿，优先级和已修学分都相同，只能由抽签决定顺序
+    for student := 1; student <= 10; student++ {
+        requests = append(requests, request(student, 100, 1, 1, 30), request(student, 200, 2, 1, 30))
+    }
+    // 主修专业课程和已修学分多的学生优先
+    return append(requests,
+        request(11, 100, 1, 0, 0),
+        request(12, 100, 1, 1, 90),
+        request(13, 100, 2, 0, 120),
+    )
+}
+
+func TestOrderRoundRequestsDeterministic(t *testing.T) {
+    first := roundTestRequests()
+    orderRoundRequests(first, 20240801)
+
+    // 输入顺序不同也得到相同的顺序和抽签号
+    shuffled := roundTestRequests()
+    for i, j := 0, len(shuffled)-1; i < j; i, j = i+1, j-1 {
+        shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+    }
+    orderRoundRequests(shuffled, 20240801)
+
+    if !reflect.DeepEqual(first, shuffled) {
+        t.Fatalf("same seed gave different orders:\n%v\n%v", first, shuffled)
+    }
+}
+
+func TestOrderRoundRequestsSortOrder(t *testing.T) {
+    requests := roundTestRequests()
+    orderRoundRequests(requests, 7)
+
+    tickets := make(map[int]int)
+    for i, r := range requests {
+        if r.Ticket < 1 || r.Ticket > 13 {
+            t.Fatalf("student %d got ticket %d, want 1..13", r.StudentID, r.Ticket)
+        }
+        if ticket, ok := tickets[r.StudentID]; ok && ticket != r.Ticket {
+            t.Fatalf("student %d has tickets %d and %d", r.StudentID, ticket, r.Ticket)
+        }
+        tickets[r.StudentID] = r.Ticket
+
+        if i == 0 {
+            continue
+        }
+        prev := requests[i-1]
+        switch {
+        case prev.Rank != r.Rank:
+            if prev.Rank > r.Rank {
+                t.Errorf("rank %d before rank %d", prev.Rank, r.Rank)
+            }
+        case prev.Priority != r.Priority:
+            if prev.Priority > r.Priority {
+                t.Errorf("priority %d before priority %d within rank %d", prev.Priority, r.Priority, r.Rank)
+            }
+        case prev.EarnedCredits != r.EarnedCredits:
+            if prev.EarnedCredits < r.EarnedCredits {
+                t.Errorf("%d credits before %d credits within rank %d", prev.EarnedCredits, r.EarnedCredits, r.Rank)
+            }
+        case prev.Ticket > r.Ticket:
+            t.Errorf("ticket %d before ticket %d", prev.Ticket, r.Ticket)
+        }
+    }
+
+    if requests[0].StudentID != 11 || requests[1].StudentID != 12 {
+        t.Errorf("first requests are students %d and %d, want the major student 11 then the senior student 12",
+            requests[0].StudentID, requests[1].StudentID)
+    }
+}
+
+func TestOrderRoundRequestsSeedChangesTieBreaks(t *testing.T) {
+    order := func(seed int64) []int {
+        requests := roundTestRequests()
+        orderRoundRequests(requests, seed)
+        var students []int
+        for _, r := range requests {
+            if r.Rank == 1 && r.Priority == 1 && r.EarnedCredits == 30 {
+                students = append(students, r.StudentID)
+            }
+        }
+        return students
+    }
+
+    first := order(1)
+    for seed := int64(2); seed <= 5; seed++ {
+        if !reflect.DeepEqual(first, order(seed)) {
+            return
+        }
+    }
+    t.Fatalf("seeds 1 to 5 all gave the tie-break order %v", first)
+}
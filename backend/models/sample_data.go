@@ -1,72 +1,79 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+
+	"github.com/lib/pq"
 )
 
 // 检查并插入示例数据
-func (db *Database) InitializeSampleData() error {
-    log.Println("检查数据库是否需要初始化示例数据...")
+func (db *Database) InitializeSampleData(ctx context.Context) error {
+    slog.Debug("检查数据库是否需要初始化示例数据")
     
     // 检查是否已有数据
-    needsData, err := db.needsSampleData()
+    needsData, err := db.needsSampleData(ctx)
     if err != nil {
-        return fmt.Errorf("检查数据库状态失败: %w", err)
+        return fmt.Errorf("检查数据库状态失败: %w", queryError(ctx, err))
     }
     
     if !needsData {
-        log.Println("数据库中已有数据，跳过示例数据插入")
+        slog.Info("数据库中已有数据，跳过示例数据插入")
         return nil
     }
     
-    log.Println("数据库为空，开始插入示例数据...")
+    slog.Info("数据库为空，开始插入示例数据")
     
     // 开始事务
-    tx, err := db.DB.Begin()
+    tx, err := db.DB.BeginTx(ctx, nil)
     if err != nil {
-        return fmt.Errorf("开始事务失败: %w", err)
+        return fmt.Errorf("开始事务失败: %w", queryError(ctx, err))
     }
     defer tx.Rollback()
     
     // 插入示例数据
-    if err := db.insertSampleStudents(tx); err != nil {
-        return fmt.Errorf("插入示例学生失败: %w", err)
+    if err := db.insertSampleStudents(ctx, tx); err != nil {
+        return fmt.Errorf("插入示例学生失败: %w", queryError(ctx, err))
+    }
+    
+    if err := db.insertSampleRooms(ctx, tx); err != nil {
+        return fmt.Errorf("插入示例教室失败: %w", queryError(ctx, err))
     }
     
-    if err := db.insertSampleCourses(tx); err != nil {
-        return fmt.Errorf("插入示例课程失败: %w", err)
+    if err := db.insertSampleCourses(ctx, tx); err != nil {
+        return fmt.Errorf("插入示例课程失败: %w", queryError(ctx, err))
     }
     
-    if err := db.insertSampleEnrollments(tx); err != nil {
-        return fmt.Errorf("插入示例选课记录失败: %w", err)
+    if err := db.insertSampleEnrollments(ctx, tx); err != nil {
+        return fmt.Errorf("插入示例选课记录失败: %w", queryError(ctx, err))
+    }
+    
+    if err := db.insertSampleProgrammes(ctx, tx); err != nil {
+        return fmt.Errorf("插入示例培养方案失败: %w", queryError(ctx, err))
     }
     
     // 提交事务
     if err := tx.Commit(); err != nil {
-        return fmt.Errorf("提交事务失败: %w", err)
+        return fmt.Errorf("提交事务失败: %w", queryError(ctx, err))
     }
     
-    log.Println("✅ 示例数据插入成功！")
-    log.Println("   - 6名示例学生")
-    log.Println("   - 8门示例课程")
-    log.Println("   - 多条选课记录")
-    log.Println("   💡 您可以随时通过API添加或删除数据")
+    slog.Info("示例数据插入成功，可随时通过API添加或删除数据")
     
     return nil
 }
 
 // 检查是否需要插入示例数据
-func (db *Database) needsSampleData() (bool, error) {
+func (db *Database) needsSampleData(ctx context.Context) (bool, error) {
     var studentCount, courseCount int
     
-    err := db.DB.QueryRow("SELECT COUNT(*) FROM students").Scan(&studentCount)
+    err := db.queryRow(ctx, "SELECT COUNT(*) FROM students").Scan(&studentCount)
     if err != nil {
         return false, err
     }
     
-    err = db.DB.QueryRow("SELECT COUNT(*) FROM courses").Scan(&courseCount)
+    err = db.queryRow(ctx, "SELECT COUNT(*) FROM courses").Scan(&courseCount)
     if err != nil {
         return false, err
     }
@@ -76,7 +83,7 @@ func (db *Database) needsSampleData() (bool, error) {
 }
 
 // 插入示例学生
-func (db *Database) insertSampleStudents(tx *sql.Tx) error {
+func (db *Database) insertSampleStudents(ctx context.Context, tx *sql.Tx) error {
     students := []struct {
         email    string
         username string
@@ -92,18 +99,49 @@ func (db *Database) insertSampleStudents(tx *sql.Tx) error {
     query := `INSERT INTO students (email, username) VALUES ($1, $2)`
     
     for _, student := range students {
-        _, err := tx.Exec(query, student.email, student.username)
+        _, err := tx.ExecContext(ctx, query, student.email, student.username)
         if err != nil {
             return fmt.Errorf("插入学生 %s 失败: %w", student.username, err)
         }
     }
     
-    log.Printf("✅ 插入了 %d 名示例学生", len(students))
+    slog.Info("已插入示例学生", "count", len(students))
+    return nil
+}
+
+// 插入示例教室
+func (db *Database) insertSampleRooms(ctx context.Context, tx *sql.Tx) error {
+    rooms := []struct {
+        building string
+        room     string
+        capacity int
+        features []string
+    }{
+        {"CYC", "LT1", 200, []string{"projector", "recording"}},
+        {"CYC", "LT2", 150, []string{"projector", "recording"}},
+        {"CYC", "LT3", 150, []string{"projector"}},
+        {"CYC", "LT4", 120, []string{"projector"}},
+        {"CYC", "LT5", 120, []string{"projector"}},
+        {"CYC", "LT6", 100, []string{"projector"}},
+        {"CB", "Lab 1", 40, []string{"projector", "computers"}},
+        {"Math Building", "LT1", 180, []string{"projector", "recording"}},
+    }
+    
+    query := `INSERT INTO rooms (building, room, capacity, features) VALUES ($1, $2, $3, $4)`
+    
+    for _, room := range rooms {
+        _, err := tx.ExecContext(ctx, query, room.building, room.room, room.capacity, pq.Array(room.features))
+        if err != nil {
+            return fmt.Errorf("插入教室 %s %s 失败: %w", room.building, room.room, err)
+        }
+    }
+    
+    slog.Info("已插入示例教室", "count", len(rooms))
     return nil
 }
 
 // 插入示例课程
-func (db *Database) insertSampleCourses(tx *sql.Tx) error {
+func (db *Database) insertSampleCourses(ctx context.Context, tx *sql.Tx) error {
     courses := []struct {
         courseCode        string
         courseName        string
@@ -113,71 +151,88 @@ func (db *Database) insertSampleCourses(tx *sql.Tx) error {
         semester          string
         timeSlot          string
         courseLocation    string
+        category          string
     }{
         {
             "COMP1117", "Computer Programming",
             "Introduction to computer programming using Python", 3,
-            "Prof. Chen", "2024 Spring", "Mon 9:00-12:00", "CYC LT1",
+            "Prof. Chen", "2024 Spring", "Mon 9:00-12:00", "CYC LT1", "core",
         },
         {
             "COMP2119", "Data Structures and Algorithms",
             "Fundamental data structures and algorithms", 4,
-            "Prof. Li", "2024 Spring", "Wed 14:00-17:00", "CYC LT2",
+            "Prof. Li", "2024 Spring", "Wed 14:00-17:00", "CYC LT2", "core",
         },
         {
             "COMP3234", "Database Systems",
             "Principles of database design and implementation", 3,
-            "Prof. Wang", "2024 Spring", "Fri 10:00-13:00", "CYC LT3",
+            "Prof. Wang", "2024 Spring", "Fri 10:00-13:00", "CYC LT3", "core",
         },
         {
             "COMP3278", "Web Development",
             "Full-stack web development with modern technologies", 3,
-            "Prof. Zhang", "2024 Spring", "Tue 14:00-17:00", "Lab 1",
+            "Prof. Zhang", "2024 Spring", "Tue 14:00-17:00", "CB Lab 1", "elective",
         },
         {
             "COMP4331", "Machine Learning",
             "Introduction to machine learning algorithms", 4,
-            "Prof. Liu", "2024 Spring", "Thu 9:00-12:00", "CYC LT4",
+            "Prof. Liu", "2024 Spring", "Thu 9:00-12:00", "CYC LT4", "elective",
         },
         {
             "COMP3322", "Software Engineering",
             "Software development lifecycle and methodologies", 3,
-            "Prof. Zhao", "2024 Spring", "Mon 14:00-17:00", "CYC LT5",
+            "Prof. Zhao", "2024 Spring", "Mon 14:00-17:00", "CYC LT5", "elective",
         },
         {
             "COMP3297", "Computer Networks",
             "Network protocols and distributed systems", 3,
-            "Prof. Wu", "2024 Spring", "Wed 10:00-13:00", "CYC LT6",
+            "Prof. Wu", "2024 Spring", "Wed 10:00-13:00", "CYC LT6", "elective",
         },
         {
             "MATH1013", "Calculus and Linear Algebra",
             "Mathematical foundations for computer science", 4,
-            "Prof. Yang", "2024 Spring", "Fri 9:00-12:00", "Math Building LT1",
+            "Prof. Yang", "2024 Spring", "Fri 9:00-12:00", "Math Building LT1", "math",
         },
     }
     
     query := `
         INSERT INTO courses (course_code, course_name, course_description, credits, 
-                           instructor, semester, time_slot, course_location)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                           instructor, semester, time_slot, course_location, category)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
     `
     
     for _, course := range courses {
-        _, err := tx.Exec(query,
+        _, err := tx.ExecContext(ctx, query,
             course.courseCode, course.courseName, course.courseDescription,
             course.credits, course.instructor, course.semester,
-            course.timeSlot, course.courseLocation)
+            course.timeSlot, course.courseLocation, course.category)
         if err != nil {
             return fmt.Errorf("插入课程 %s 失败: %w", course.courseName, err)
         }
     }
     
-    log.Printf("✅ 插入了 %d 门示例课程", len(courses))
+    // 按地点名称关联示例教室，课程容量取教室容量
+    _, err := tx.ExecContext(ctx, `
+        UPDATE courses c
+        SET room_id = r.id, capacity = COALESCE(c.capacity, r.capacity)
+        FROM rooms r
+        WHERE c.course_location = r.building || ' ' || r.room
+    `)
+    if err != nil {
+        return fmt.Errorf("关联示例教室失败: %w", err)
+    }
+    
+    // 根据课程的教师姓名建立教师及任课关系
+    if _, err := tx.ExecContext(ctx, linkCourseInstructorsSQL); err != nil {
+        return fmt.Errorf("插入示例教师失败: %w", err)
+    }
+    
+    slog.Info("已插入示例课程", "count", len(courses))
     return nil
 }
 
 // 插入示例选课记录
-func (db *Database) insertSampleEnrollments(tx *sql.Tx) error {
+func (db *Database) insertSampleEnrollments(ctx context.Context, tx *sql.Tx) error {
     // 预定义的选课关系 (student_id, course_id)
     enrollments := []struct {
         studentID int
@@ -205,37 +260,110 @@ func (db *Database) insertSampleEnrollments(tx *sql.Tx) error {
     query := `INSERT INTO student_courses (student_id, course_id) VALUES ($1, $2)`
     
     for _, enrollment := range enrollments {
-        _, err := tx.Exec(query, enrollment.studentID, enrollment.courseID)
+        _, err := tx.ExecContext(ctx, query, enrollment.studentID, enrollment.courseID)
         if err != nil {
             return fmt.Errorf("插入选课记录 (学生ID:%d, 课程ID:%d) 失败: %w", 
                              enrollment.studentID, enrollment.courseID, err)
         }
     }
     
-    log.Printf("✅ 插入了 %d 条示例选课记录", len(enrollments))
+    slog.Info("已插入示例选课记录", "count", len(enrollments))
+    return nil
+}
+
+// 插入示例培养方案，并让前两名学生修读
+func (db *Database) insertSampleProgrammes(ctx context.Context, tx *sql.Tx) error {
+    var programmeID int
+    err := tx.QueryRowContext(ctx, `
+        INSERT INTO programmes (programme_code, programme_name, programme_type)
+        VALUES ('BSC-CS', 'Bachelor of Science in Computer Science', 'major')
+        RETURNING id
+    `).Scan(&programmeID)
+    if err != nil {
+        return fmt.Errorf("插入培养方案失败: %w", err)
+    }
+    
+    requirements := []struct {
+        name        string
+        ruleType    string
+        courseCodes []string
+        category    string
+        minCourses  int
+        minCredits  int
+    }{
+        {"专业核心课程", RuleCourses, []string{"COMP1117", "COMP2119", "COMP3234"}, "", 0, 0},
+        {"数学基础", RuleCourses, []string{"MATH1013"}, "", 0, 0},
+        {"专业选修课（至少6学分）", RuleCredits, nil, "elective", 0, 6},
+        {"毕业总学分", RuleCredits, nil, "", 0, 20},
+    }
+    
+    query := `
+        INSERT INTO programme_requirements (programme_id, position, requirement_name, rule_type,
+                                            course_codes, category, min_courses, min_credits)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `
+    
+    for i, requirement := range requirements {
+        courseCodes := requirement.courseCodes
+        if courseCodes == nil {
+            courseCodes = []string{}
+        }
+        _, err := tx.ExecContext(ctx, query, programmeID, i, requirement.name, requirement.ruleType,
+            pq.Array(courseCodes), requirement.category,
+            nullableInt(requirement.minCourses), nullableInt(requirement.minCredits))
+        if err != nil {
+            return fmt.Errorf("插入毕业要求 %s 失败: %w", requirement.name, err)
+        }
+    }
+    
+    _, err = tx.ExecContext(ctx, `
+        INSERT INTO student_programmes (student_id, programme_id) VALUES (1, $1), (2, $1)
+    `, programmeID)
+    if err != nil {
+        return fmt.Errorf("插入学生培养方案失败: %w", err)
+    }
+    
+    slog.Info("已插入示例培养方案", "requirements", len(requirements))
     return nil
 }
 
 // 清空所有数据 (可选功能，用于重置数据库)
-func (db *Database) ClearAllData() error {
-    log.Println("⚠️  正在清空所有数据...")
+func (db *Database) ClearAllData(ctx context.Context) error {
+    slog.Warn("正在清空所有数据")
     
     // 开始事务
-    tx, err := db.DB.Begin()
+    tx, err := db.DB.BeginTx(ctx, nil)
     if err != nil {
-        return fmt.Errorf("开始事务失败: %w", err)
+        return fmt.Errorf("开始事务失败: %w", queryError(ctx, err))
     }
     defer tx.Rollback()
     
-    // 按依赖关系顺序删除数据
+    // 按依赖关系顺序删除数据。审计日志禁止逐行删除，使用 TRUNCATE 清空
     queries := []string{
+        "TRUNCATE audit_events RESTART IDENTITY",
+        "DELETE FROM idempotency_keys",
+        "DELETE FROM student_programmes",
+        "DELETE FROM programme_requirements",
+        "DELETE FROM programmes",
+        "DELETE FROM enrollment_petitions",
+        "DELETE FROM round_results",
+        "DELETE FROM round_preferences",
+        "DELETE FROM registration_rounds",
+        "DELETE FROM cart_items",
+        "DELETE FROM course_prerequisites",
+        "DELETE FROM enrollment_sections",
         "DELETE FROM student_courses",
+        "DELETE FROM course_sections",
+        "DELETE FROM course_instructors",
+        "DELETE FROM instructor_unavailability",
+        "DELETE FROM instructors",
         "DELETE FROM students",
         "DELETE FROM courses",
+        "DELETE FROM rooms",
     }
     
     for _, query := range queries {
-        _, err := tx.Exec(query)
+        _, err := tx.ExecContext(ctx, query)
         if err != nil {
             return fmt.Errorf("清空数据失败 (%s): %w", query, err)
         }
@@ -246,25 +374,36 @@ func (db *Database) ClearAllData() error {
         "ALTER SEQUENCE students_id_seq RESTART WITH 1",
         "ALTER SEQUENCE courses_id_seq RESTART WITH 1", 
         "ALTER SEQUENCE student_courses_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE programmes_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE programme_requirements_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE course_sections_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE instructors_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE instructor_unavailability_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE rooms_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE registration_rounds_id_seq RESTART WITH 1",
+        "ALTER SEQUENCE enrollment_petitions_id_seq RESTART WITH 1",
     }
     
     for _, query := range resetQueries {
-        _, err := tx.Exec(query)
+        _, err := tx.ExecContext(ctx, query)
         if err != nil {
             return fmt.Errorf("重置序列失败 (%s): %w", query, err)
         }
     }
     
     if err := tx.Commit(); err != nil {
-        return fmt.Errorf("提交清空操作失败: %w", err)
+        return fmt.Errorf("提交清空操作失败: %w", queryError(ctx, err))
     }
     
-    log.Println("✅ 所有数据已清空，ID序列已重置")
+    slog.Warn("所有数据已清空，ID序列已重置")
     return nil
 }
 
 // 获取数据库统计信息
-func (db *Database) GetDataStats() (map[string]int, error) {
+func (db *Database) GetDataStats(ctx context.Context) (map[string]int, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     stats := make(map[string]int)
     
     // 获取各表数据量
@@ -272,11 +411,16 @@ func (db *Database) GetDataStats() (map[string]int, error) {
         "students":         "SELECT COUNT(*) FROM students",
         "courses":          "SELECT COUNT(*) FROM courses", 
         "student_courses":  "SELECT COUNT(*) FROM student_courses",
+        "audit_events":     "SELECT COUNT(*) FROM audit_events",
+        "programmes":       "SELECT COUNT(*) FROM programmes",
+        "course_sections":  "SELECT COUNT(*) FROM course_sections",
+        "instructors":      "SELECT COUNT(*) FROM instructors",
+        "rooms":            "SELECT COUNT(*) FROM rooms",
     }
     
     for name, query := range queries {
         var count int
-        err := db.DB.QueryRow(query).Scan(&count)
+        err := db.queryRow(ctx, query).Scan(&count)
         if err != nil {
             return nil, fmt.Errorf("查询%s统计失败: %w", name, err)
         }
@@ -0,0 +1,247 @@
+package models
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// 教学班类型。课程设置了某种类型的教学班时，选课必须在该类型中恰好选择一个
+const (
+    SectionLecture  = "lecture"
+    SectionTutorial = "tutorial"
+    SectionLab      = "lab"
+)
+
+// 课程下的教学班（讲课班、辅导班或实验班），各自有上课时间、地点、教师和容量
+type Section struct {
+    ID             int       `json:"id"`
+    CourseID       int       `json:"course_id"`
+    SectionCode    string    `json:"section_code"`
+    SectionType    string    `json:"section_type"`
+    Instructor     string    `json:"instructor"`
+    TimeSlot       string    `json:"time_slot"`
+    CourseLocation string    `json:"course_location"`
+    Capacity       *int      `json:"capacity"` // 为空表示不限人数
+    RoomID         *int      `json:"room_id"`  // 排课后关联的教室
+    EnrolledCount  int       `json:"enrolled_count"`
+    CreatedAt      time.Time `json:"created_at"`
+}
+
+// 获取课程的所有教学班及当前选课人数
+func (db *Database) GetCourseSections(ctx context.Context, courseID int) ([]Section, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := `
+        SELECT s.id, s.course_id, s.section_code, s.section_type,
+               COALESCE(s.instructor, ''), COALESCE(s.time_slot, ''), COALESCE(s.course_location, ''),
+               s.capacity, s.room_id, s.created_at,
+               (SELECT COUNT(*)
+                FROM enrollment_sections es
+                JOIN student_courses sc ON sc.id = es.enrollment_id
+                WHERE es.section_id = s.id AND sc.status = 'enrolled')
+        FROM course_sections s
+        WHERE s.course_id = $1
+        ORDER BY s.section_type, s.section_code
+    `
+
+    rows, err := db.query(ctx, query, courseID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query course sections: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    sections := []Section{}
+    for rows.Next() {
+        var section Section
+        err := rows.Scan(
+            &section.ID, &section.CourseID, &section.SectionCode, &section.SectionType,
+            &section.Instructor, &section.TimeSlot, &section.CourseLocation,
+            &section.Capacity, &section.RoomID, &section.CreatedAt, &section.EnrolledCount,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan course section: %w", queryError(ctx, err))
+        }
+        sections = append(sections, section)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return sections, nil
+}
+
+// 为课程添加教学班 (管理员功能)
+func (db *Database) AddSection(ctx context.Context, section Section) (*Section, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    courseExists, err := db.CourseExists(ctx, section.CourseID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to check course existence: %w", queryError(ctx, err))
+    }
+    if !courseExists {
+        return nil, fmt.Errorf("%w (ID %d)", ErrCourseNotFound, section.CourseID)
+    }
+
+    query := `
+        INSERT INTO course_sections (course_id, section_code, section_type, instructor,
+                                     time_slot, course_location, capacity)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at
+    `
+
+    err = db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, query, section.CourseID, section.SectionCode, section.SectionType,
+            section.Instructor, section.TimeSlot, section.CourseLocation, section.Capacity,
+        ).Scan(&section.ID, &section.CreatedAt)
+        if err != nil {
+            var pqErr *pq.Error
+            if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+                return fmt.Errorf("%w (%s)", ErrDuplicateSection, section.SectionCode)
+            }
+            return fmt.Errorf("failed to add section: %w", queryError(ctx, err))
+        }
+
+        return tx.recordAudit(ctx, AuditSectionCreated, 0, section.CourseID, nil, section)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &section, nil
+}
+
+// 锁定课程的所有教学班并按学生的选择确定要加入的教学班，同时检查容量。
+// 同一课程的选课因此串行执行，容量检查不会被并发的选课绕过。
+// 课程没有教学班时返回空列表。ignoreCapacity 为 true 时不检查容量（审批通过的选课申请）。
+func (tx txn) reserveSections(ctx context.Context, courseID int, requested []int, ignoreCapacity bool) ([]Section, error) {
+    query := `
+        SELECT id, course_id, section_code, section_type, COALESCE(time_slot, ''), capacity
+        FROM course_sections
+        WHERE course_id = $1
+        ORDER BY id
+        ` + tx.forUpdate()
+
+    rows, err := tx.query(ctx, query, courseID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to lock course sections: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    var sections []Section
+    for rows.Next() {
+        var section Section
+        err := rows.Scan(&section.ID, &section.CourseID, &section.SectionCode, &section.SectionType,
+            &section.TimeSlot, &section.Capacity)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan course section: %w", queryError(ctx, err))
+        }
+        sections = append(sections, section)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+    rows.Close()
+
+    chosen, err := chooseSections(sections, requested)
+    if err != nil {
+        return nil, err
+    }
+
+    countQuery := `
+        SELECT COUNT(*)
+        FROM enrollment_sections es
+        JOIN student_courses sc ON sc.id = es.enrollment_id
+        WHERE es.section_id = $1 AND sc.status = 'enrolled'
+    `
+
+    for i := range chosen {
+        section := &chosen[i]
+        err := tx.queryRow(ctx, countQuery, section.ID).Scan(&section.EnrolledCount)
+        if err != nil {
+            return nil, fmt.Errorf("failed to count section enrollments: %w", queryError(ctx, err))
+        }
+        if section.Capacity != nil && section.EnrolledCount >= *section.Capacity && !ignoreCapacity {
+            return nil, fmt.Errorf("%w (%s)", ErrSectionFull, section.SectionCode)
+        }
+    }
+
+    return chosen, nil
+}
+
+// 将选课记录关联到教学班
+func (tx txn) attachSections(ctx context.Context, enrollmentID int, sections []Section) error {
+    query := `INSERT INTO enrollment_sections (enrollment_id, section_id) VALUES ($1, $2)`
+
+    for _, section := range sections {
+        if _, err := tx.exec(ctx, query, enrollmentID, section.ID); err != nil {
+            return fmt.Errorf("failed to attach section: %w", queryError(ctx, err))
+        }
+    }
+    return nil
+}
+
+// 校验学生选择的教学班：每种类型恰好一个。某类型只有一个教学班时可以不选，自动加入。
+func chooseSections(sections []Section, requested []int) ([]Section, error) {
+    byID := make(map[int]Section, len(sections))
+    byType := make(map[string][]Section)
+    for _, section := range sections {
+        byID[section.ID] = section
+        byType[section.SectionType] = append(byType[section.SectionType], section)
+    }
+
+    picked := make(map[string]Section)
+    for _, id := range requested {
+        section, ok := byID[id]
+        if !ok {
+            return nil, fmt.Errorf("%w: section %d does not belong to this course", ErrInvalidSections, id)
+        }
+        if existing, ok := picked[section.SectionType]; ok && existing.ID != id {
+            return nil, fmt.Errorf("%w: more than one %s section selected", ErrInvalidSections, section.SectionType)
+        }
+        picked[section.SectionType] = section
+    }
+
+    for sectionType, options := range byType {
+        if _, ok := picked[sectionType]; ok {
+            continue
+        }
+        if len(options) > 1 {
+            return nil, fmt.Errorf("%w: a %s section must be selected", ErrInvalidSections, sectionType)
+        }
+        picked[sectionType] = options[0]
+    }
+
+    chosen := make([]Section, 0, len(picked))
+    for _, section := range picked {
+        chosen = append(chosen, section)
+    }
+    sort.Slice(chosen, func(i, j int) bool { return chosen[i].ID < chosen[j].ID })
+
+    return chosen, nil
+}
+
+// 教学班的上课时间列表，用于时间冲突检查
+func sectionTimeSlots(sections []Section) []string {
+    slots := make([]string, len(sections))
+    for i, section := range sections {
+        slots[i] = section.TimeSlot
+    }
+    return slots
+}
+
+// 教学班代码列表，用于审计日志和选课信息展示
+func sectionCodes(sections []Section) []string {
+    codes := make([]string, len(sections))
+    for i, section := range sections {
+        codes[i] = section.SectionCode
+    }
+    return codes
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChooseSections(t *testing.T) {
+    lecture := Section{ID: 1, SectionCode: "L1", SectionType: "lecture"}
+    tutorial1 := Section{ID: 2, SectionCode: "T1", SectionType: "tutorial"}
+    tutorial2 := Section{ID: 3, SectionCode: "T2", SectionType: "tutorial"}
+    lab := Section{ID: 4, SectionCode: "B1", SectionType: "lab"}
+    sections := []Section{tutorial2, lab, lecture, tutorial1}
+
+    tests := []struct {
+        name      string
+        sections  []Section
+        requested []int
+        want      []int
+        wantErr   bool
+    }{
+        {name: "no sections", want: []int{}},
+        {name: "one per type", sections: sections, requested: []int{3, 1, 4}, want: []int{1, 3, 4}},
+        {name: "single sections picked automatically", sections: sections, requested: []int{2}, want: []int{1, 2, 4}},
+        {name: "same section twice", sections: sections, requested: []int{2, 2}, want: []int{1, 2, 4}},
+        {name: "only single sections", sections: []Section{lecture, lab}, want: []int{1, 4}},
+        {name: "type with several sections left out", sections: sections, requested: []int{1}, wantErr: true},
+        {name: "two sections of one type", sections: sections, requested: []int{2, 3}, wantErr: true},
+        {name: "section of another course", sections: sections, requested: []int{2, 99}, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            chosen, err := chooseSections(tt.sections, tt.requested)
+            if tt.wantErr {
+                if !errors.Is(err, ErrInvalidSections) {
+                    t.Fatalf("chooseSections error = %v, want ErrInvalidSections", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("chooseSections error = %v", err)
+            }
+            got := make([]int, len(chosen))
+            for i, section := range chosen {
+                got[i] = section.ID
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("chooseSections = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
@@ -1,20 +1,24 @@
 package models
 
 import (
+    "context"
     "database/sql"
     "fmt"
 )
 
-func (db *Database) GetAllStudents() ([]Student, error) {
+func (db *Database) GetAllStudents(ctx context.Context) ([]Student, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
         SELECT id, email, username, created_at
         FROM students
         ORDER BY username
     `
     
-    rows, err := db.DB.Query(query)
+    rows, err := db.query(ctx, query)
     if err != nil {
-        return nil, fmt.Errorf("failed to query students: %w", err)
+        return nil, fmt.Errorf("failed to query students: %w", queryError(ctx, err))
     }
     defer rows.Close()
     
@@ -23,19 +27,22 @@ func (db *Database) GetAllStudents() ([]Student, error) {
         var student Student
         err := rows.Scan(&student.ID, &student.Email, &student.Username, &student.CreatedAt)
         if err != nil {
-            return nil, fmt.Errorf("failed to scan student: %w", err)
+            return nil, fmt.Errorf("failed to scan student: %w", queryError(ctx, err))
         }
         students = append(students, student)
     }
     
     if err = rows.Err(); err != nil {
-        return nil, fmt.Errorf("rows iteration error: %w", err)
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
     }
     
     return students, nil
 }
 
-func (db *Database) GetStudentByID(studentID int) (*Student, error) {
+func (db *Database) GetStudentByID(ctx context.Context, studentID int) (*Student, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
         SELECT id, email, username, created_at
         FROM students
@@ -43,20 +50,23 @@ func (db *Database) GetStudentByID(studentID int) (*Student, error) {
     `
     
     var student Student
-    err := db.DB.QueryRow(query, studentID).Scan(
+    err := db.queryRow(ctx, query, studentID).Scan(
         &student.ID, &student.Email, &student.Username, &student.CreatedAt)
     
     if err != nil {
         if err == sql.ErrNoRows {
             return nil, nil // 学生不存在
         }
-        return nil, fmt.Errorf("failed to get student: %w", err)
+        return nil, fmt.Errorf("failed to get student: %w", queryError(ctx, err))
     }
     
     return &student, nil
 }
 
-func (db *Database) AddStudent(email, username string) (*Student, error) {
+func (db *Database) AddStudent(ctx context.Context, email, username string) (*Student, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `
         INSERT INTO students (email, username)
         VALUES ($1, $2)
@@ -64,23 +74,32 @@ func (db *Database) AddStudent(email, username string) (*Student, error) {
     `
     
     var student Student
-    err := db.DB.QueryRow(query, email, username).Scan(
-        &student.ID, &student.Email, &student.Username, &student.CreatedAt)
-    
+    err := db.inTx(ctx, func(tx txn) error {
+        err := tx.queryRow(ctx, query, email, username).Scan(
+            &student.ID, &student.Email, &student.Username, &student.CreatedAt)
+        if err != nil {
+            return fmt.Errorf("failed to add student: %w", queryError(ctx, err))
+        }
+        
+        return tx.recordAudit(ctx, AuditStudentCreated, student.ID, 0, nil, student)
+    })
     if err != nil {
-        return nil, fmt.Errorf("failed to add student: %w", err)
+        return nil, err
     }
     
     return &student, nil
 }
 
-func (db *Database) StudentExists(studentID int) (bool, error) {
+func (db *Database) StudentExists(ctx context.Context, studentID int) (bool, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+    
     query := `SELECT COUNT(*) > 0 FROM students WHERE id = $1`
     
     var exists bool
-    err := db.DB.QueryRow(query, studentID).Scan(&exists)
+    err := db.queryRow(ctx, query, studentID).Scan(&exists)
     if err != nil {
-        return false, fmt.Errorf("failed to check if student exists: %w", err)
+        return false, fmt.Errorf("failed to check if student exists: %w", queryError(ctx, err))
     }
     
     return exists, nil
@@ -0,0 +1,98 @@
+package models
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// 一次上课时间：星期几（0 表示周一）及一天内的起止分钟
+type meeting struct {
+    Day   int
+    Start int
+    End   int
+}
+
+func (m meeting) overlaps(other meeting) bool {
+    return m.Day == other.Day && m.Start < other.End && other.Start < m.End
+}
+
+var (
+    clockSlotPattern  = regexp.MustCompile(`^(Mon|Tue|Wed|Thu|Fri|Sat|Sun)\s+(\d{1,2}):(\d{2})\s*-\s*(\d{1,2}):(\d{2})$`)
+    periodSlotPattern = regexp.MustCompile(`^周([一二三四五六日天])\s*(\d{1,2})\s*-\s*(\d{1,2})\s*节$`)
+    slotSeparator     = regexp.MustCompile(`\s*[,，;；、]\s*`)
+
+    englishWeekdays = map[string]int{"Mon": 0, "Tue": 1, "Wed": 2, "Thu": 3, "Fri": 4, "Sat": 5, "Sun": 6}
+    chineseWeekdays = map[string]int{"一": 0, "二": 1, "三": 2, "四": 3, "五": 4, "六": 5, "日": 6, "天": 6}
+)
+
+// 按节次排课时的作息：第 1 节 8:00 开始，每节 50 分钟，课间 10 分钟
+const (
+    firstPeriodStart = 8 * 60
+    periodLength     = 50
+    periodInterval   = 60
+)
+
+// 解析课程的上课时间。支持 "Mon 9:00-12:00" 和 "周一3-4节" 两种写法，多次上课以逗号或分号分隔
+func parseTimeSlot(timeSlot string) ([]meeting, error) {
+    timeSlot = strings.TrimSpace(timeSlot)
+    if timeSlot == "" {
+        return nil, fmt.Errorf("%w: empty", ErrInvalidTimeSlot)
+    }
+
+    var meetings []meeting
+    for _, part := range slotSeparator.Split(timeSlot, -1) {
+        m, ok := parseMeeting(part)
+        if !ok || m.Start >= m.End {
+            return nil, fmt.Errorf("%w: %q", ErrInvalidTimeSlot, part)
+        }
+        meetings = append(meetings, m)
+    }
+    return meetings, nil
+}
+
+func parseMeeting(text string) (meeting, bool) {
+    if match := clockSlotPattern.FindStringSubmatch(text); match != nil {
+        startHour, _ := strconv.Atoi(match[2])
+        startMinute, _ := strconv.Atoi(match[3])
+        endHour, _ := strconv.Atoi(match[4])
+        endMinute, _ := strconv.Atoi(match[5])
+        // 结束时间最晚为 24:00
+        if startHour > 23 || endHour > 24 || startMinute > 59 || endMinute > 59 || (endHour == 24 && endMinute > 0) {
+            return meeting{}, false
+        }
+        return meeting{
+            Day:   englishWeekdays[match[1]],
+            Start: startHour*60 + startMinute,
+            End:   endHour*60 + endMinute,
+        }, true
+    }
+
+    if match := periodSlotPattern.FindStringSubmatch(text); match != nil {
+        first, _ := strconv.Atoi(match[2])
+        last, _ := strconv.Atoi(match[3])
+        if first < 1 || last < first {
+            return meeting{}, false
+        }
+        return meeting{
+            Day:   chineseWeekdays[match[1]],
+            Start: firstPeriodStart + (first-1)*periodInterval,
+            End:   firstPeriodStart + (last-1)*periodInterval + periodLength,
+        }, true
+    }
+
+    return meeting{}, false
+}
+
+// 两个上课时间是否有重叠
+func meetingsOverlap(a, b []meeting) bool {
+    for _, x := range a {
+        for _, y := range b {
+            if x.overlaps(y) {
+                return true
+            }
+        }
+    }
+    return false
+}
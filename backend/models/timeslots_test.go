@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTimeSlot(t *testing.T) {
+    tests := []struct {
+        name     string
+        timeSlot string
+        want     []meeting
+        wantErr  bool
+    }{
+        {
+            name:     "clock format",
+            timeSlot: "Mon 9:00-12:00",
+            want:     []meeting{{Day: 0, Start: 9 * 60, End: 12 * 60}},
+        },
+        {
+            name:     "period format",
+            timeSlot: "周三3-4节",
+            // 第 3 节 10:00 开始，第 4 节 11:50 结束
+            want: []meeting{{Day: 2, Start: 10 * 60, End: 11*60 + 50}},
+        },
+        {
+            name:     "mixed formats and separators",
+            timeSlot: "Tue 14:30 - 16:00， 周日 1-1 节; Fri 8:00-9:00",
+            want: []meeting{
+                {Day: 1, Start: 14*60 + 30, End: 16 * 60},
+                {Day: 6, Start: 8 * 60, End: 8*60 + 50},
+                {Day: 4, Start: 8 * 60, End: 9 * 60},
+            },
+        },
+        {
+            name:     "ends at midnight",
+            timeSlot: "Sat 22:00-24:00",
+            want:     []meeting{{Day: 5, Start: 22 * 60, End: 24 * 60}},
+        },
+        {name: "empty", timeSlot: "  ", wantErr: true},
+        {name: "unknown weekday", timeSlot: "Mun 9:00-10:00", wantErr: true},
+        {name: "missing minutes", timeSlot: "Mon 9-10", wantErr: true},
+        {name: "start hour out of range", timeSlot: "Mon 24:00-24:30", wantErr: true},
+        {name: "end hour out of range", timeSlot: "Mon 23:00-25:00", wantErr: true},
+        {name: "minute out of range", timeSlot: "Mon 9:60-10:00", wantErr: true},
+        {name: "start equals end", timeSlot: "Mon 9:00-9:00", wantErr: true},
+        {name: "start after end", timeSlot: "Mon 12:00-9:00", wantErr: true},
+        {name: "period zero", timeSlot: "周一0-2节", wantErr: true},
+        {name: "periods reversed", timeSlot: "周一4-3节", wantErr: true},
+        {name: "one malformed part", timeSlot: "Mon 9:00-10:00, sometime", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := parseTimeSlot(tt.timeSlot)
+            if tt.wantErr {
+                if !errors.Is(err, ErrInvalidTimeSlot) {
+                    t.Fatalf("parseTimeSlot(%q) error = %v, want ErrInvalidTimeSlot", tt.timeSlot, err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("parseTimeSlot(%q) error = %v", tt.timeSlot, err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("parseTimeSlot(%q) = %+v, want %+v", tt.timeSlot, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseMeeting(t *testing.T) {
+    tests := []struct {
+        text   string
+        want   meeting
+        wantOK bool
+    }{
+        {text: "Sun 0:00-0:30", want: meeting{Day: 6, Start: 0, End: 30}, wantOK: true},
+        {text: "Thu 23:59-24:00", want: meeting{Day: 3, Start: 23*60 + 59, End: 24 * 60}, wantOK: true},
+        {text: "周天 10-12 节", want: meeting{Day: 6, Start: 17 * 60, End: 19*60 + 50}, wantOK: true},
+        // 起止先后由 parseTimeSlot 检查
+        {text: "Mon 10:00-9:00", want: meeting{Day: 0, Start: 10 * 60, End: 9 * 60}, wantOK: true},
+        {text: "Mon 9:00-24:01"},
+        {text: "Monday 9:00-10:00"},
+        {text: "周八1-2节"},
+        {text: ""},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.text, func(t *testing.T) {
+            got, ok := parseMeeting(tt.text)
+            if ok != tt.wantOK || got != tt.want {
+                t.Errorf("parseMeeting(%q) = %+v, %v, want %+v, %v", tt.text, got, ok, tt.want, tt.wantOK)
+            }
+        })
+    }
+}
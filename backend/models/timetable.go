@@ -0,0 +1,499 @@
+package models
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "sort"
+    "strings"
+
+    "course-management/timetable"
+
+    "github.com/lib/pq"
+)
+
+// 未设置或无法解析原有上课时间时，各类单元每周的默认课时（分钟）
+var defaultDurations = map[string]int{
+    "":              180, // 课程本身的讲课
+    SectionLecture:  120,
+    SectionTutorial: 60,
+    SectionLab:      120,
+}
+
+// 实验班需要有电脑的教室
+var sectionFeatures = map[string][]string{
+    SectionLab: {"computers"},
+}
+
+// 排课方案中的一项：课程本身或其中一个教学班的上课时间和教室
+type TimetableEntry struct {
+    CourseID         int
+    SectionID        int // 0 表示课程本身
+    Label            string
+    TimeSlot         string
+    RoomID           int
+    Location         string
+    PreviousTimeSlot string
+    PreviousLocation string
+}
+
+func (e TimetableEntry) Changed() bool {
+    return e.TimeSlot != e.PreviousTimeSlot || e.Location != e.PreviousLocation
+}
+
+// 一个学期的排课方案。无解时 Reasons 说明原因；Fingerprint 标识方案内容，确认排课时用于检查方案未变化
+type TimetableProposal struct {
+    Semester    string
+    Feasible    bool
+    Entries     []TimetableEntry
+    Reasons     []string
+    Fingerprint string
+}
+
+// 待排课单元在数据库中的来源
+type timetableUnit struct {
+    entry       TimetableEntry
+    courseCode  string
+    category    string
+    sectionType string // 课程本身为空
+    meetings    []int  // 每周各次上课对应的求解单元下标
+}
+
+// 为学期内的所有课程和教学班生成排课方案，不修改数据。约束包括：
+// 同一教室不重叠；同一教师任教的课程不重叠；同一培养方案同一年级的课程不重叠（年级取课程代码的第一位数字）；
+// 课程的讲课与教学班、不同类型的教学班之间不重叠；教室容量和设施满足要求；避开教师不可用时间。
+// 每周上课多次的课程或教学班按原有上课时间分别安排每次上课，各次上课使用同一教室
+func (db *Database) GenerateTimetable(ctx context.Context, semester string) (*TimetableProposal, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    units, problem, err := db.loadTimetableProblem(ctx, semester)
+    if err != nil {
+        return nil, err
+    }
+
+    // 求解与查询共用超时时间，避免长时间搜索占用请求
+    proposal := &TimetableProposal{Semester: semester}
+    result, err := timetable.Solve(ctx, problem)
+    if err != nil {
+        return nil, fmt.Errorf("timetable search stopped after %d steps: %w", result.Steps, queryError(ctx, err))
+    }
+    if !result.Feasible {
+        proposal.Reasons = result.Reasons
+        return proposal, nil
+    }
+
+    // 同一课程或教学班的各次上课使用同一教室，按时间先后合并为一个 time_slot
+    proposal.Feasible = true
+    for _, u := range units {
+        slots := make([]timetable.Slot, 0, len(u.meetings))
+        for _, m := range u.meetings {
+            slots = append(slots, result.Placements[m].Slot)
+        }
+        sort.Slice(slots, func(i, j int) bool {
+            if slots[i].Day != slots[j].Day {
+                return slots[i].Day < slots[j].Day
+            }
+            return slots[i].Start < slots[j].Start
+        })
+        parts := make([]string, len(slots))
+        for i, slot := range slots {
+            parts[i] = slot.String()
+        }
+
+        entry := u.entry
+        room := problem.Rooms[result.Placements[u.meetings[0]].Room]
+        entry.TimeSlot = strings.Join(parts, ", ")
+        entry.RoomID = room.ID
+        entry.Location = room.Label
+        proposal.Entries = append(proposal.Entries, entry)
+    }
+    proposal.Fingerprint = timetableFingerprint(proposal)
+
+    return proposal, nil
+}
+
+// 重新生成排课方案并写入课程和教学班。fingerprint 不为空时须与预览时的方案一致，否则返回 ErrTimetableChanged
+func (db *Database) ApplyTimetable(ctx context.Context, semester, fingerprint string) (*TimetableProposal, error) {
+    proposal, err := db.GenerateTimetable(ctx, semester)
+    if err != nil {
+        return nil, err
+    }
+    if !proposal.Feasible {
+        return proposal, fmt.Errorf("%w: %s", ErrTimetableInfeasible, strings.Join(proposal.Reasons, "; "))
+    }
+    if fingerprint != "" && fingerprint != proposal.Fingerprint {
+        return proposal, ErrTimetableChanged
+    }
+
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    courseBefore := `SELECT ` + courseColumns + ` FROM courses WHERE id = $1 FOR UPDATE`
+    // 不限人数的课程安排到教室后取教室容量
+    courseUpdate := `
+        UPDATE courses
+        SET time_slot = $2, room_id = $3, course_location = $4,
+            capacity = COALESCE(capacity, (SELECT capacity FROM rooms WHERE id = $3))
+        WHERE id = $1
+        RETURNING ` + courseColumns
+
+    sectionUpdate := `
+        UPDATE course_sections
+        SET time_slot = $2, room_id = $3, course_location = $4
+        WHERE id = $1
+    `
+
+    err = db.inTx(ctx, func(tx txn) error {
+        for _, entry := range proposal.Entries {
+            if !entry.Changed() {
+                continue
+            }
+
+            if entry.SectionID > 0 {
+                if _, err := tx.exec(ctx, sectionUpdate, entry.SectionID, entry.TimeSlot, entry.RoomID, entry.Location); err != nil {
+                    return fmt.Errorf("failed to update section schedule: %w", queryError(ctx, err))
+                }
+                err := tx.recordAudit(ctx, AuditSectionUpdated, 0, entry.CourseID,
+                    map[string]any{"section_id": entry.SectionID, "time_slot": entry.PreviousTimeSlot, "course_location": entry.PreviousLocation},
+                    map[string]any{"section_id": entry.SectionID, "time_slot": entry.TimeSlot, "course_location": entry.Location, "room_id": entry.RoomID})
+                if err != nil {
+                    return err
+                }
+                continue
+            }
+
+            var before, after Course
+            if err := tx.queryRow(ctx, courseBefore, entry.CourseID).Scan(courseFields(&before)...); err != nil {
+                return fmt.Errorf("failed to get course: %w", queryError(ctx, err))
+            }
+            err := tx.queryRow(ctx, courseUpdate, entry.CourseID, entry.TimeSlot, entry.RoomID, entry.Location).Scan(
+                courseFields(&after)...)
+            if err != nil {
+                return fmt.Errorf("failed to update course schedule: %w", queryError(ctx, err))
+            }
+            if err := tx.recordAudit(ctx, AuditCourseUpdated, 0, entry.CourseID, before, after); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return proposal, nil
+}
+
+// 读取学期内的课程、教学班、教室和各类约束，转换为求解器的输入
+func (db *Database) loadTimetableProblem(ctx context.Context, semester string) ([]timetableUnit, timetable.Problem, error) {
+    problem := timetable.Problem{Grid: timetable.DefaultGrid}
+
+    courseQuery := `
+        SELECT c.id, c.course_code, c.category, COALESCE(c.time_slot, ''), COALESCE(c.course_location, ''), c.capacity,
+               (SELECT COUNT(*) FROM student_courses sc WHERE sc.course_id = c.id AND sc.status = 'enrolled')
+        FROM courses c
+        WHERE COALESCE(c.semester, '') = $1
+        ORDER BY c.course_code, c.id
+    `
+
+    var units []timetableUnit
+    courseUnit := make(map[int]int) // 课程ID -> units 下标
+
+    rows, err := db.query(ctx, courseQuery, semester)
+    if err != nil {
+        return nil, problem, fmt.Errorf("failed to query semester courses: %w", queryError(ctx, err))
+    }
+    for rows.Next() {
+        var u timetableUnit
+        var capacity *int
+        var enrolled int
+        err := rows.Scan(&u.entry.CourseID, &u.courseCode, &u.category, &u.entry.PreviousTimeSlot,
+            &u.entry.PreviousLocation, &capacity, &enrolled)
+        if err != nil {
+            rows.Close()
+            return nil, problem, fmt.Errorf("failed to scan course: %w", queryError(ctx, err))
+        }
+        u.entry.Label = u.courseCode
+        addMeetingUnits(&problem, &u, unitSize(capacity, enrolled))
+        courseUnit[u.entry.CourseID] = len(units)
+        units = append(units, u)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, problem, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    sectionQuery := `
+        SELECT s.id, s.course_id, s.section_code, s.section_type,
+               COALESCE(s.time_slot, ''), COALESCE(s.course_location, ''), s.capacity,
+               (SELECT COUNT(*)
+                FROM enrollment_sections es
+                JOIN student_courses sc ON sc.id = es.enrollment_id
+                WHERE es.section_id = s.id AND sc.status = 'enrolled')
+        FROM course_sections s
+        JOIN courses c ON c.id = s.course_id
+        WHERE COALESCE(c.semester, '') = $1
+        ORDER BY c.course_code, s.section_type, s.section_code
+    `
+
+    rows, err = db.query(ctx, sectionQuery, semester)
+    if err != nil {
+        return nil, problem, fmt.Errorf("failed to query semester sections: %w", queryError(ctx, err))
+    }
+    for rows.Next() {
+        var u timetableUnit
+        var sectionCode string
+        var capacity *int
+        var enrolled int
+        err := rows.Scan(&u.entry.SectionID, &u.entry.CourseID, &sectionCode, &u.sectionType,
+            &u.entry.PreviousTimeSlot, &u.entry.PreviousLocation, &capacity, &enrolled)
+        if err != nil {
+            rows.Close()
+            return nil, problem, fmt.Errorf("failed to scan section: %w", queryError(ctx, err))
+        }
+        u.courseCode = units[courseUnit[u.entry.CourseID]].courseCode
+        u.entry.Label = u.courseCode + " " + sectionCode
+        addMeetingUnits(&problem, &u, unitSize(capacity, enrolled))
+        units = append(units, u)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, problem, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    rooms, err := db.GetRooms(ctx, RoomFilter{})
+    if err != nil {
+        return nil, problem, err
+    }
+    for _, room := range rooms {
+        problem.Rooms = append(problem.Rooms, timetable.Room{
+            ID:       room.ID,
+            Label:    room.Label(),
+            Capacity: room.Capacity,
+            Features: room.Features,
+        })
+    }
+
+    // 同一课程的讲课与教学班、不同类型的教学班之间不能重叠；同类型的教学班供学生选择其一，可以重叠
+    for i := range units {
+        for j := i + 1; j < len(units); j++ {
+            a, b := units[i], units[j]
+            if a.entry.CourseID != b.entry.CourseID || (a.sectionType == b.sectionType) {
+                continue
+            }
+            addPairwiseConflicts(&problem, units, []int{i, j}, "same course "+a.courseCode)
+        }
+    }
+
+    if err := db.addInstructorConstraints(ctx, semester, units, courseUnit, &problem); err != nil {
+        return nil, problem, err
+    }
+    if err := db.addProgrammeConstraints(ctx, units, courseUnit, &problem); err != nil {
+        return nil, problem, err
+    }
+
+    return units, problem, nil
+}
+
+// 同一教师任教的课程不能重叠，课程避开其任课教师的不可用时间
+func (db *Database) addInstructorConstraints(ctx context.Context, semester string, units []timetableUnit, courseUnit map[int]int, problem *timetable.Problem) error {
+    query := `
+        SELECT ci.course_id, i.name,
+               ARRAY(SELECT u.time_slot FROM instructor_unavailability u WHERE u.instructor_id = i.id ORDER BY u.id)
+        FROM course_instructors ci
+        JOIN instructors i ON i.id = ci.instructor_id
+        JOIN courses c ON c.id = ci.course_id
+        WHERE COALESCE(c.semester, '') = $1
+        ORDER BY i.name, ci.course_id
+    `
+
+    rows, err := db.query(ctx, query, semester)
+    if err != nil {
+        return fmt.Errorf("failed to query course instructors: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    taught := make(map[string][]int) // 教师 -> units 下标
+    var names []string
+    for rows.Next() {
+        var courseID int
+        var name string
+        var unavailable []string
+        if err := rows.Scan(&courseID, &name, pq.Array(&unavailable)); err != nil {
+            return fmt.Errorf("failed to scan course instructor: %w", queryError(ctx, err))
+        }
+
+        unit := courseUnit[courseID]
+        for _, timeSlot := range unavailable {
+            // 写入时已校验格式
+            meetings, _ := parseTimeSlot(timeSlot)
+            for _, m := range meetings {
+                for _, index := range units[unit].meetings {
+                    problem.Units[index].Blocked = append(problem.Units[index].Blocked,
+                        timetable.Slot{Day: m.Day, Start: m.Start, End: m.End})
+                }
+            }
+        }
+
+        if _, ok := taught[name]; !ok {
+            names = append(names, name)
+        }
+        taught[name] = append(taught[name], unit)
+    }
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    for _, name := range names {
+        addPairwiseConflicts(problem, units, taught[name], "both taught by "+name)
+    }
+    return nil
+}
+
+// 同一培养方案同一年级的课程不能重叠，学生才能按方案修读
+func (db *Database) addProgrammeConstraints(ctx context.Context, units []timetableUnit, courseUnit map[int]int, problem *timetable.Problem) error {
+    query := `
+        SELECT p.programme_code, r.rule_type, r.course_codes, r.category
+        FROM programme_requirements r
+        JOIN programmes p ON p.id = r.programme_id
+        ORDER BY p.programme_code, r.position
+    `
+
+    rows, err := db.query(ctx, query)
+    if err != nil {
+        return fmt.Errorf("failed to query programme requirements: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    members := make(map[string]map[int]bool) // 培养方案 -> units 下标
+    var programmes []string
+    for rows.Next() {
+        var code, ruleType, category string
+        var courseCodes []string
+        if err := rows.Scan(&code, &ruleType, pq.Array(&courseCodes), &category); err != nil {
+            return fmt.Errorf("failed to scan programme requirement: %w", queryError(ctx, err))
+        }
+        if _, ok := members[code]; !ok {
+            members[code] = make(map[int]bool)
+            programmes = append(programmes, code)
+        }
+
+        listed := make(map[string]bool, len(courseCodes))
+        for _, courseCode := range courseCodes {
+            listed[courseCode] = true
+        }
+        for _, unit := range courseUnit {
+            u := units[unit]
+            if listed[u.courseCode] || (ruleType == RuleCredits && category != "" && u.category == category) {
+                members[code][unit] = true
+            }
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    for _, code := range programmes {
+        byYear := make(map[byte][]int)
+        var years []byte
+        for unit := range units {
+            if !members[code][unit] {
+                continue
+            }
+            year := courseYear(units[unit].courseCode)
+            if year == 0 {
+                continue
+            }
+            if _, ok := byYear[year]; !ok {
+                years = append(years, year)
+            }
+            byYear[year] = append(byYear[year], unit)
+        }
+        for _, year := range years {
+            addPairwiseConflicts(problem, units, byYear[year], fmt.Sprintf("both in %s year %c", code, year))
+        }
+    }
+    return nil
+}
+
+// unitIndexes 为 units 下标，两两之间的每次上课都不能重叠
+func addPairwiseConflicts(problem *timetable.Problem, units []timetableUnit, unitIndexes []int, reason string) {
+    for i := range unitIndexes {
+        for j := i + 1; j < len(unitIndexes); j++ {
+            for _, a := range units[unitIndexes[i]].meetings {
+                for _, b := range units[unitIndexes[j]].meetings {
+                    problem.Conflicts = append(problem.Conflicts, timetable.Conflict{A: a, B: b, Reason: reason})
+                }
+            }
+        }
+    }
+}
+
+// 为课程或教学班每周的每次上课添加一个求解单元。各次上课互不重叠且使用同一教室
+func addMeetingUnits(problem *timetable.Problem, u *timetableUnit, size int) {
+    durations := meetingDurations(u.entry.PreviousTimeSlot, u.sectionType)
+    for n, duration := range durations {
+        label := u.entry.Label
+        if len(durations) > 1 {
+            label = fmt.Sprintf("%s (meeting %d of %d)", u.entry.Label, n+1, len(durations))
+        }
+        for _, other := range u.meetings {
+            problem.Conflicts = append(problem.Conflicts, timetable.Conflict{
+                A: other, B: len(problem.Units), Reason: "same course " + u.courseCode,
+            })
+        }
+        u.meetings = append(u.meetings, len(problem.Units))
+        problem.Units = append(problem.Units, timetable.Unit{
+            Label:    label,
+            Duration: duration,
+            Size:     size,
+            Features: sectionFeatures[u.sectionType],
+        })
+    }
+    if len(u.meetings) > 1 {
+        problem.SameRoom = append(problem.SameRoom, u.meetings)
+    }
+}
+
+// 课程代码中第一位数字表示年级，如 COMP2119 为二年级
+func courseYear(courseCode string) byte {
+    for i := 0; i < len(courseCode); i++ {
+        if c := courseCode[i]; c >= '1' && c <= '9' {
+            return c
+        }
+    }
+    return 0
+}
+
+// 每周各次上课的时长取原有上课时间，无法解析时为一次默认时长的上课
+func meetingDurations(timeSlot, sectionType string) []int {
+    meetings, err := parseTimeSlot(timeSlot)
+    if err != nil {
+        return []int{defaultDurations[sectionType]}
+    }
+    durations := make([]int, len(meetings))
+    for i, m := range meetings {
+        durations[i] = m.End - m.Start
+    }
+    return durations
+}
+
+// 需要的座位数：设置了容量时按容量，否则按当前选课人数
+func unitSize(capacity *int, enrolled int) int {
+    if capacity != nil {
+        return *capacity
+    }
+    return max(enrolled, 1)
+}
+
+func timetableFingerprint(proposal *TimetableProposal) string {
+    hash := sha256.New()
+    fmt.Fprintf(hash, "%s\n", proposal.Semester)
+    for _, e := range proposal.Entries {
+        fmt.Fprintf(hash, "%d|%d|%s|%d|%s|%s\n", e.CourseID, e.SectionID, e.TimeSlot, e.RoomID,
+            e.PreviousTimeSlot, e.PreviousLocation)
+    }
+    return hex.EncodeToString(hash.Sum(nil))[:16]
+}
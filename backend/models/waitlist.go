@@ -0,0 +1,374 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "course-management/events"
+    "course-management/logging"
+
+    "github.com/lib/pq"
+)
+
+// 候补状态。只有 waiting 表示仍在排队，其余均为历史记录
+const (
+    WaitlistWaiting          = "waiting"
+    WaitlistPromoted         = "promoted"          // 有名额后按候补顺序自动选课
+    WaitlistEnrolledDirectly = "enrolled_directly" // 未经递补选上课程，如自行选课、选课轮次分配、批准选课申请或批量选课
+    WaitlistLeft             = "left"              // 学生退出候补
+    WaitlistSkipped          = "skipped"           // 轮到递补时不满足名额以外的选课规则，Reason 为失败原因
+    WaitlistRemoved          = "removed"           // 管理员清空课程时一并移除
+)
+
+// 候补记录：学生只因课程或教学班已满无法选课时加入候补，有名额时按加入顺序自动选课。
+// Position 为排队中的位次（从 1 开始），已结束的候补为空
+type WaitlistEntry struct {
+    ID         int        `json:"id"`
+    StudentID  int        `json:"student_id"`
+    CourseID   int        `json:"course_id"`
+    CourseCode string     `json:"course_code"`
+    SectionIDs []int      `json:"section_ids"`
+    Status     string     `json:"status"`
+    Reason     string     `json:"reason"`
+    Position   *int       `json:"position"`
+    CreatedAt  time.Time  `json:"created_at"`
+    EndedAt    *time.Time `json:"ended_at"`
+}
+
+const waitlistQuery = `
+    SELECT w.id, w.student_id, w.course_id, c.course_code, w.section_ids, w.status, w.reason,
+           CASE WHEN w.status = 'waiting' THEN
+               (SELECT COUNT(*) FROM waitlist_entries o
+                WHERE o.course_id = w.course_id AND o.status = 'waiting' AND o.id <= w.id)
+           END,
+           w.created_at, w.ended_at
+    FROM waitlist_entries w
+    JOIN courses c ON c.id = w.course_id
+`
+
+func scanWaitlistEntry(row rowScanner) (WaitlistEntry, error) {
+    var w WaitlistEntry
+    var sectionIDs pq.Int64Array
+    err := row.Scan(&w.ID, &w.StudentID, &w.CourseID, &w.CourseCode, &sectionIDs, &w.Status, &w.Reason,
+        &w.Position, &w.CreatedAt, &w.EndedAt)
+    if err != nil {
+        return w, err
+    }
+    w.SectionIDs = make([]int, len(sectionIDs))
+    for i, id := range sectionIDs {
+        w.SectionIDs[i] = int(id)
+    }
+    return w, nil
+}
+
+// 获取学生的候补。默认只返回排队中的候补，includeHistory 为 true 时包含已结束的记录
+func (db *Database) GetStudentWaitlist(ctx context.Context, studentID int, includeHistory bool) ([]WaitlistEntry, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    query := waitlistQuery + `
+        WHERE w.student_id = $1 AND ($2 OR w.status = 'waiting')
+        ORDER BY w.created_at DESC, w.id DESC
+    `
+
+    rows, err := db.query(ctx, query, studentID, includeHistory)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query waitlist: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    entries := []WaitlistEntry{}
+    for rows.Next() {
+        entry, err := scanWaitlistEntry(rows)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan waitlist entry: %w", queryError(ctx, err))
+        }
+        entries = append(entries, entry)
+    }
+
+    if err = rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+
+    return entries, nil
+}
+
+// 课程排队中的候补人数
+func (db *Database) GetWaitlistCount(ctx context.Context, courseID int) (int, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    var count int
+    err := db.queryRow(ctx, `SELECT COUNT(*) FROM waitlist_entries WHERE course_id = $1 AND status = 'waiting'`,
+        courseID).Scan(&count)
+    if err != nil {
+        return 0, fmt.Errorf("failed to count waitlist: %w", queryError(ctx, err))
+    }
+    return count, nil
+}
+
+// 加入课程候补。先按选课规则试选（不生效）：可以直接选课时返回 ErrCourseNotFull；
+// 课程或所选教学班已满时，名额以外的规则（如学分上限、时间冲突）也须满足，否则返回对应错误
+func (db *Database) JoinWaitlist(ctx context.Context, studentID, courseID int, sectionIDs []int) (*WaitlistEntry, error) {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    if sectionIDs == nil {
+        sectionIDs = []int{}
+    }
+
+    var entry WaitlistEntry
+    err := db.inTx(ctx, func(tx txn) error {
+        if err := tx.lockStudent(ctx, studentID); err != nil {
+            return err
+        }
+
+        var waiting bool
+        err := tx.queryRow(ctx, `
+            SELECT EXISTS(SELECT 1 FROM waitlist_entries WHERE student_id = $1 AND course_id = $2 AND status = 'waiting')
+        `, studentID, courseID).Scan(&waiting)
+        if err != nil {
+            return fmt.Errorf("failed to check waitlist: %w", queryError(ctx, err))
+        }
+        if waiting {
+            return fmt.Errorf("%w (student %d, course %d)", ErrAlreadyWaitlisted, studentID, courseID)
+        }
+
+        if err := tx.checkWaitlistable(ctx, studentID, courseID, sectionIDs); err != nil {
+            return err
+        }
+
+        var id int
+        err = tx.queryRow(ctx, `
+            INSERT INTO waitlist_entries (student_id, course_id, section_ids)
+            VALUES ($1, $2, $3)
+            RETURNING id
+        `, studentID, courseID, pq.Array(sectionIDs)).Scan(&id)
+        if err != nil {
+            return fmt.Errorf("failed to join waitlist: %w", queryError(ctx, err))
+        }
+
+        entry, err = scanWaitlistEntry(tx.queryRow(ctx, waitlistQuery+" WHERE w.id = $1", id))
+        if err != nil {
+            return fmt.Errorf("failed to read waitlist entry: %w", queryError(ctx, err))
+        }
+
+        return tx.recordAudit(ctx, AuditWaitlistJoined, studentID, courseID, nil, entry)
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &entry, nil
+}
+
+// 试选课程，确认学生只因课程或教学班已满无法选课。试选在保存点中进行并随即回滚，不会产生选课记录
+func (tx txn) checkWaitlistable(ctx context.Context, studentID, courseID int, sectionIDs []int) error {
+    trial := func(overrides []string) error {
+        if err := tx.savepoint(ctx, "waitlist_check"); err != nil {
+            return err
+        }
+        _, enrollErr := tx.enrollWithOverrides(ctx, studentID, courseID, sectionIDs, overrides)
+        if err := tx.rollbackToSavepoint(ctx, "waitlist_check"); err != nil {
+            return err
+        }
+        return enrollErr
+    }
+
+    err := trial(nil)
+    switch {
+    case err == nil:
+        return fmt.Errorf("%w (course %d)", ErrCourseNotFull, courseID)
+    case errors.Is(err, ErrCourseFull), errors.Is(err, ErrSectionFull):
+        return trial([]string{OverrideCapacity})
+    default:
+        return err
+    }
+}
+
+// 学生退出课程候补
+func (db *Database) LeaveWaitlist(ctx context.Context, studentID, courseID int) error {
+    ctx, cancel := db.withTimeout(ctx)
+    defer cancel()
+
+    err := db.inTx(ctx, func(tx txn) error {
+        ended, err := tx.endWaitlistEntries(ctx, studentID, courseID, WaitlistLeft, "")
+        if err != nil {
+            return err
+        }
+        if len(ended) == 0 {
+            return fmt.Errorf("%w (student %d, course %d)", ErrNotWaitlisted, studentID, courseID)
+        }
+        return tx.recordAudit(ctx, AuditWaitlistLeft, studentID, courseID, ended[0].previous(), ended[0])
+    })
+    if err != nil {
+        return err
+    }
+
+    // 排在前面的学生所选教学班仍满时，后面的学生可能可以递补
+    db.promoteWaitlists(ctx, courseID)
+    return nil
+}
+
+// 结束排队中的候补并返回结束后的记录。studentID 为 0 时结束课程的所有候补
+func (tx txn) endWaitlistEntries(ctx context.Context, studentID, courseID int, status, reason string) ([]WaitlistEntry, error) {
+    rows, err := tx.query(ctx, `
+        UPDATE waitlist_entries
+        SET status = $3, reason = $4, ended_at = CURRENT_TIMESTAMP
+        WHERE course_id = $2 AND ($1 = 0 OR student_id = $1) AND status = 'waiting'
+        RETURNING id
+    `, studentID, courseID, status, reason)
+    if err != nil {
+        return nil, fmt.Errorf("failed to end waitlist entries: %w", queryError(ctx, err))
+    }
+    defer rows.Close()
+
+    var ids []int
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("failed to scan waitlist entry: %w", queryError(ctx, err))
+        }
+        ids = append(ids, id)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows iteration error: %w", queryError(ctx, err))
+    }
+    rows.Close()
+
+    ended := make([]WaitlistEntry, 0, len(ids))
+    for _, id := range ids {
+        entry, err := scanWaitlistEntry(tx.queryRow(ctx, waitlistQuery+" WHERE w.id = $1", id))
+        if err != nil {
+            return nil, fmt.Errorf("failed to read waitlist entry: %w", queryError(ctx, err))
+        }
+        ended = append(ended, entry)
+    }
+    return ended, nil
+}
+
+// 选课期间释放了名额的操作（退课、换课、退出候补、管理员移除、批量转课、扩容）提交后调用，为课程递补候补学生。
+// 修完课程、登记成绩等不是释放名额，不调用。递补在独立的事务中进行，失败只记录日志，不影响已提交的操作，
+// 下一次释放名额时会重试
+func (db *Database) promoteWaitlists(ctx context.Context, courseIDs ...int) {
+    if len(courseIDs) == 0 {
+        return
+    }
+
+    // 请求可能已接近超时，递补不受请求上下文取消的影响，以系统身份记录审计
+    ctx, cancel := db.withTimeout(WithActor(context.WithoutCancel(ctx), SystemActor))
+    defer cancel()
+
+    for _, courseID := range courseIDs {
+        if err := db.promoteWaitlist(ctx, courseID); err != nil {
+            logging.FromContext(ctx).Warn("候补递补失败", "course_id", courseID, "error", err)
+        }
+    }
+}
+
+// 按加入顺序为课程递补候补学生，直到课程再次满员或没有可递补的学生。
+// 轮到的学生所选教学班已满时保留候补，继续递补后面的学生
+func (db *Database) promoteWaitlist(ctx context.Context, courseID int) error {
+    afterID := 0
+    for {
+        var entryID, studentID int
+        err := db.queryRow(ctx, `
+            SELECT id, student_id
+            FROM waitlist_entries
+            WHERE course_id = $1 AND status = 'waiting' AND id > $2
+            ORDER BY id
+            LIMIT 1
+        `, courseID, afterID).Scan(&entryID, &studentID)
+        if err == sql.ErrNoRows {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("failed to get next waitlist entry: %w", queryError(ctx, err))
+        }
+        afterID = entryID
+
+        full, err := db.promoteWaitlistEntry(ctx, entryID, studentID)
+        if err != nil || full {
+            return err
+        }
+    }
+}
+
+// 为一条候补选课，成功时推送递补事件。课程已满或选课轮次未分配时返回 full 为 true，停止递补；
+// 不满足名额以外的选课规则时移出候补并记录原因
+func (db *Database) promoteWaitlistEntry(ctx context.Context, entryID, studentID int) (full bool, err error) {
+    pending, err := db.commitTx(ctx, func(tx txn) error {
+        if err := tx.lockStudent(ctx, studentID); err != nil {
+            return err
+        }
+
+        entry, err := scanWaitlistEntry(tx.queryRow(ctx, waitlistQuery+" WHERE w.id = $1 FOR UPDATE OF w", entryID))
+        if err == sql.ErrNoRows {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("failed to lock waitlist entry: %w", queryError(ctx, err))
+        }
+        // 加锁前学生已退出候补或已自行选课
+        if entry.Status != WaitlistWaiting {
+            return nil
+        }
+
+        // 先将候补标记为 promoted，选课时不再按自行选课结束候补；选课失败时随保存点回滚
+        if err := tx.savepoint(ctx, "waitlist_promotion"); err != nil {
+            return err
+        }
+        if _, err := tx.endWaitlistEntries(ctx, entry.StudentID, entry.CourseID, WaitlistPromoted, ""); err != nil {
+            return err
+        }
+        enrollment, enrollErr := tx.enroll(ctx, entry.StudentID, entry.CourseID, entry.SectionIDs)
+        if enrollErr == nil {
+            if err := tx.recordAudit(ctx, AuditWaitlistPromoted, entry.StudentID, entry.CourseID, entry, enrollment); err != nil {
+                return err
+            }
+            tx.publishEnrollment(events.WaitlistPromoted, *enrollment)
+            return nil
+        }
+        if err := tx.rollbackToSavepoint(ctx, "waitlist_promotion"); err != nil {
+            return err
+        }
+
+        switch {
+        case errors.Is(enrollErr, ErrCourseFull), errors.Is(enrollErr, ErrRoundPending):
+            full = true
+            return nil
+        case errors.Is(enrollErr, ErrSectionFull):
+            return nil
+        case !isEnrollmentRuleError(enrollErr):
+            return enrollErr
+        }
+
+        skipped, err := tx.endWaitlistEntries(ctx, entry.StudentID, entry.CourseID, WaitlistSkipped, enrollErr.Error())
+        if err != nil {
+            return err
+        }
+        for _, s := range skipped {
+            if err := tx.recordAudit(ctx, AuditWaitlistSkipped, s.StudentID, s.CourseID, entry, s); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return false, err
+    }
+
+    db.publishPending(ctx, pending)
+    return full, nil
+}
+
+// 结束前的候补记录，用于审计日志
+func (w WaitlistEntry) previous() WaitlistEntry {
+    w.Status = WaitlistWaiting
+    w.Reason = ""
+    w.EndedAt = nil
+    return w
+}
@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"course-management/logging"
+	"course-management/requestid"
+	"course-management/types"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// 令牌桶参数：每分钟补充 PerMinute 个令牌，桶容量为 Burst
+type Rule struct {
+    PerMinute int `json:"per_minute"`
+    Burst     int `json:"burst"`
+}
+
+// 检查规则是否有效。PerMinute 或 Burst 为 0 的令牌桶会永远拒绝请求，需要关闭限流时应使用 Enabled 开关
+func (r Rule) Validate() error {
+    if r.PerMinute < 1 || r.Burst < 1 {
+        return fmt.Errorf("per_minute and burst must be at least 1, got per_minute=%d burst=%d", r.PerMinute, r.Burst)
+    }
+    return nil
+}
+
+// 闲置超过该时间的令牌桶会被回收，避免按IP/学生建立的桶无限增长
+const idleTTL = 10 * time.Minute
+
+type bucket struct {
+    limiter  *rate.Limiter
+    lastSeen time.Time
+}
+
+// 按键（IP、学生ID等）分别限流的令牌桶集合
+type Limiter struct {
+    rule Rule
+
+    mu          sync.Mutex
+    buckets     map[string]*bucket
+    lastCleanup time.Time
+}
+
+func NewLimiter(rule Rule) *Limiter {
+    return &Limiter{
+        rule:        rule,
+        buckets:     make(map[string]*bucket),
+        lastCleanup: time.Now(),
+    }
+}
+
+// 尝试为 key 消耗一个令牌。被拒绝时不消耗令牌，返回需要等待的时间；
+// 成功时返回的 cancel 归还该令牌，用于请求随后被其他策略拒绝的情况
+func (l *Limiter) Reserve(key string) (ok bool, retryAfter time.Duration, cancel func()) {
+    now := time.Now()
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if now.Sub(l.lastCleanup) > time.Minute {
+        for k, b := range l.buckets {
+            if now.Sub(b.lastSeen) > idleTTL {
+                delete(l.buckets, k)
+            }
+        }
+        l.lastCleanup = now
+    }
+
+    b, ok := l.buckets[key]
+    if !ok {
+        b = &bucket{
+            limiter: rate.NewLimiter(rate.Limit(float64(l.rule.PerMinute)/60), l.rule.Burst),
+        }
+        l.buckets[key] = b
+    }
+    b.lastSeen = now
+
+    reservation := b.limiter.ReserveN(now, 1)
+    if !reservation.OK() {
+        return false, time.Minute, nil
+    }
+    if delay := reservation.DelayFrom(now); delay > 0 {
+        reservation.CancelAt(now)
+        return false, delay, nil
+    }
+
+    // 立即可用的预留只能按预留时刻取消，之后调用 Cancel 不会归还令牌
+    return true, 0, func() { reservation.CancelAt(now) }
+}
+
+// 限流策略：Match 决定请求是否适用，Key 决定按什么维度计数（返回空字符串表示不限流）
+type Policy struct {
+    Name    string
+    Limiter *Limiter
+    Match   func(c *gin.Context) bool
+    Key     func(c *gin.Context) string
+}
+
+// 按客户端IP计数
+func ByIP(c *gin.Context) string {
+    return "ip:" + c.ClientIP()
+}
+
+// 按路径参数计数，如学生ID
+func ByParam(name string) func(c *gin.Context) string {
+    return func(c *gin.Context) string {
+        if value := c.Param(name); value != "" {
+            return name + ":" + value
+        }
+        return ""
+    }
+}
+
+// 匹配指定方法和路由模板的请求
+func Route(method, path string) func(c *gin.Context) bool {
+    return func(c *gin.Context) bool {
+        return c.Request.Method == method && c.FullPath() == path
+    }
+}
+
+// 限流中间件：检查所有适用的策略，任一策略超限即返回429并在 Retry-After 中给出最长的等待秒数。
+// 被拒绝的请求不消耗任何策略的令牌，已在其他策略预留的令牌会归还。
+// 依赖 c.FullPath()，必须在注册路由之前通过 r.Use 注册。
+func Middleware(policies []Policy, onLimited func(policy string)) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var reserved []func()
+        var retryAfter time.Duration
+        limited := false
+        for _, policy := range policies {
+            if policy.Match != nil && !policy.Match(c) {
+                continue
+            }
+            key := policy.Key(c)
+            if key == "" {
+                continue
+            }
+
+            allowed, wait, cancel := policy.Limiter.Reserve(key)
+            if allowed {
+                reserved = append(reserved, cancel)
+                continue
+            }
+
+            limited = true
+            retryAfter = max(retryAfter, wait)
+            if onLimited != nil {
+                onLimited(policy.Name)
+            }
+            logging.FromContext(c.Request.Context()).Warn("请求被限流",
+                "policy", policy.Name, "key", key, "retry_after", wait)
+        }
+
+        if !limited {
+            c.Next()
+            return
+        }
+
+        for _, cancel := range reserved {
+            cancel()
+        }
+        seconds := int(math.Ceil(retryAfter.Seconds()))
+        c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+        c.AbortWithStatusJSON(http.StatusTooManyRequests, types.ErrorResponse{
+            Error:     "请求过于频繁，请稍后再试",
+            RequestID: requestid.Get(c),
+        })
+    }
+}
@@ -0,0 +1,66 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"course-management/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求ID使用的HTTP头
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// 客户端传入的请求ID只接受有限字符集，既防止日志注入，也保证可以安全地写入SQL注释
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// 将请求ID存入上下文
+func WithContext(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, contextKey{}, id)
+}
+
+// 从上下文中取出请求ID，不存在时返回空字符串
+func FromContext(ctx context.Context) string {
+    if ctx == nil {
+        return ""
+    }
+    id, _ := ctx.Value(contextKey{}).(string)
+    return id
+}
+
+// 从gin上下文中取出请求ID
+func Get(c *gin.Context) string {
+    return FromContext(c.Request.Context())
+}
+
+// 请求ID中间件：沿用客户端传入的合法 X-Request-ID，否则生成新的ID；
+// ID会写入响应头、请求上下文以及请求级日志记录器。需注册在 logging.Middleware 之后。
+func Middleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := c.GetHeader(Header)
+        if !validID.MatchString(id) {
+            id = newID()
+        }
+
+        c.Header(Header, id)
+
+        ctx := WithContext(c.Request.Context(), id)
+        ctx = logging.WithContext(ctx, logging.FromContext(ctx).With("request_id", id))
+        c.Request = c.Request.WithContext(ctx)
+
+        c.Next()
+    }
+}
+
+func newID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "unknown"
+    }
+    return hex.EncodeToString(b)
+}
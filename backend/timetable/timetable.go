@@ -0,0 +1,426 @@
+// Package timetable 为课程和教学班安排上课时间与教室。
+// 求解器只处理内存中的排课问题，数据的读取和写回由调用方负责。
+package timetable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 一段上课时间。Day 为 0 表示周一，Start/End 为一天内的分钟数
+type Slot struct {
+    Day   int
+    Start int
+    End   int
+}
+
+var weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+func (s Slot) Overlaps(other Slot) bool {
+    return s.Day == other.Day && s.Start < other.End && other.Start < s.End
+}
+
+// 与课程 time_slot 相同的写法，如 "Mon 9:00-12:00"
+func (s Slot) String() string {
+    return fmt.Sprintf("%s %d:%02d-%d:%02d", weekdays[s.Day], s.Start/60, s.Start%60, s.End/60, s.End%60)
+}
+
+// 可供安排的教室
+type Room struct {
+    ID       int
+    Label    string
+    Capacity int
+    Features []string
+}
+
+// 待排课的单元：一门课程的讲课或一个教学班的一次上课。每周上课多次时每次为一个单元
+type Unit struct {
+    Label    string
+    Duration int      // 分钟
+    Size     int      // 需要的座位数
+    Features []string // 需要的教室设施
+    Blocked  []Slot   // 不能安排的时间，如任课教师不可用
+}
+
+// 两个单元不能安排在重叠的时间，Reason 用于解释无解的原因
+type Conflict struct {
+    A      int
+    B      int
+    Reason string
+}
+
+// 候选时间网格：每周前 Days 天，每天 DayStart 到 DayEnd 之间每隔 Step 分钟可以开始上课
+type Grid struct {
+    Days     int
+    DayStart int
+    DayEnd   int
+    Step     int
+}
+
+// 周一至周五 9:00-19:00，整点开始
+var DefaultGrid = Grid{Days: 5, DayStart: 9 * 60, DayEnd: 19 * 60, Step: 60}
+
+// 默认的搜索步数上限，超过后视为无解
+const DefaultMaxSteps = 200000
+
+// 每隔多少步检查一次 ctx 是否已结束
+const cancelCheckInterval = 256
+
+type Problem struct {
+    Units     []Unit
+    Rooms     []Room
+    Conflicts []Conflict
+    SameRoom  [][]int // 必须安排在同一教室的单元，如同一课程每周的多次上课
+    Grid      Grid
+    MaxSteps  int
+}
+
+// 单元的安排结果，Room 为 Problem.Rooms 的下标
+type Placement struct {
+    Slot Slot
+    Room int
+}
+
+// 求解结果。有解时 Placements 与 Problem.Units 一一对应；无解时 Reasons 说明原因
+type Result struct {
+    Feasible   bool
+    Placements []Placement
+    Reasons    []string
+    Steps      int
+}
+
+// 回溯搜索一个满足所有约束的安排：同一教室的单元时间不重叠，存在冲突的单元时间不重叠，
+// 同组单元使用同一教室，教室容量和设施满足要求，且避开不可用时间。
+// 每次优先安排候选最少的单元，并在安排后剪除其他单元的候选。
+// 相同输入总是得到相同结果，因此预览和确认两次求解的结果一致。
+// ctx 结束时停止搜索并返回 ctx.Err()，调用方应为求解设置超时
+func Solve(ctx context.Context, p Problem) (Result, error) {
+    if p.Grid.Step <= 0 {
+        p.Grid = DefaultGrid
+    }
+    if p.MaxSteps <= 0 {
+        p.MaxSteps = DefaultMaxSteps
+    }
+
+    s := newSolver(ctx, p)
+    if reasons := s.precheck(); len(reasons) > 0 {
+        return Result{Reasons: reasons}, nil
+    }
+
+    domains := make([][]int, len(p.Units))
+    for i := range domains {
+        domains[i] = make([]int, len(s.options[i]))
+        for j := range domains[i] {
+            domains[i][j] = j
+        }
+    }
+
+    if !s.search(domains) {
+        if s.err != nil {
+            return Result{Steps: s.steps}, s.err
+        }
+        return Result{Reasons: s.explain(), Steps: s.steps}, nil
+    }
+
+    placements := make([]Placement, len(p.Units))
+    for i, option := range s.assigned {
+        placements[i] = s.options[i][option]
+    }
+    return Result{Feasible: true, Placements: placements, Steps: s.steps}, nil
+}
+
+type solver struct {
+    ctx       context.Context
+    problem   Problem
+    options   [][]Placement      // 每个单元的候选安排
+    conflicts map[[2]int]string  // 无序单元对 -> 冲突原因
+    group     []int              // 单元所在的同教室组，-1 表示不属于任何组
+    degree    []int
+    assigned  []int              // 每个单元选中的候选下标，-1 表示未安排
+    steps     int
+    aborted   bool  // 超过步数上限或 ctx 已结束
+    err       error // ctx 结束的原因
+
+    wipeouts []int         // 单元候选被剪空的次数
+    blame    []map[int]int // 单元 -> 导致其候选被剪空的其他单元及次数
+}
+
+func newSolver(ctx context.Context, p Problem) *solver {
+    s := &solver{
+        ctx:       ctx,
+        problem:   p,
+        options:   make([][]Placement, len(p.Units)),
+        conflicts: make(map[[2]int]string),
+        group:     make([]int, len(p.Units)),
+        degree:    make([]int, len(p.Units)),
+        assigned:  make([]int, len(p.Units)),
+        wipeouts:  make([]int, len(p.Units)),
+        blame:     make([]map[int]int, len(p.Units)),
+    }
+
+    for _, c := range p.Conflicts {
+        key := pairKey(c.A, c.B)
+        if _, ok := s.conflicts[key]; !ok && c.A != c.B {
+            s.conflicts[key] = c.Reason
+            s.degree[c.A]++
+            s.degree[c.B]++
+        }
+    }
+
+    for i := range s.group {
+        s.group[i] = -1
+    }
+    for g, units := range p.SameRoom {
+        for _, unit := range units {
+            s.group[unit] = g
+        }
+    }
+
+    // 候选教室按容量从小到大，尽量把大教室留给人数多的单元
+    rooms := make([]int, len(p.Rooms))
+    for i := range rooms {
+        rooms[i] = i
+    }
+    sort.SliceStable(rooms, func(i, j int) bool {
+        return p.Rooms[rooms[i]].Capacity < p.Rooms[rooms[j]].Capacity
+    })
+
+    for i, unit := range p.Units {
+        s.assigned[i] = -1
+        s.blame[i] = make(map[int]int)
+        for _, slot := range s.slots(unit) {
+            for _, room := range rooms {
+                if roomFits(p.Rooms[room], unit) {
+                    s.options[i] = append(s.options[i], Placement{Slot: slot, Room: room})
+                }
+            }
+        }
+    }
+
+    return s
+}
+
+// 单元可用的上课时间
+func (s *solver) slots(unit Unit) []Slot {
+    g := s.problem.Grid
+    var slots []Slot
+    for day := 0; day < g.Days; day++ {
+        for start := g.DayStart; start+unit.Duration <= g.DayEnd; start += g.Step {
+            slot := Slot{Day: day, Start: start, End: start + unit.Duration}
+            blocked := false
+            for _, b := range unit.Blocked {
+                if slot.Overlaps(b) {
+                    blocked = true
+                    break
+                }
+            }
+            if !blocked {
+                slots = append(slots, slot)
+            }
+        }
+    }
+    return slots
+}
+
+func roomFits(room Room, unit Unit) bool {
+    if room.Capacity < unit.Size {
+        return false
+    }
+    for _, feature := range unit.Features {
+        found := false
+        for _, f := range room.Features {
+            if f == feature {
+                found = true
+                break
+            }
+        }
+        if !found {
+            return false
+        }
+    }
+    return true
+}
+
+// 单独看每个单元就无法安排的情况，如没有足够大的教室或任课教师全周不可用
+func (s *solver) precheck() []string {
+    var reasons []string
+    for i, unit := range s.problem.Units {
+        if len(s.options[i]) > 0 {
+            continue
+        }
+
+        rooms := 0
+        largest := 0
+        for _, room := range s.problem.Rooms {
+            if roomFits(room, unit) {
+                rooms++
+            }
+            if room.Capacity > largest {
+                largest = room.Capacity
+            }
+        }
+
+        switch {
+        case unit.Duration > s.problem.Grid.DayEnd-s.problem.Grid.DayStart:
+            reasons = append(reasons, fmt.Sprintf("%s lasts %d minutes, longer than a teaching day", unit.Label, unit.Duration))
+        case rooms == 0 && len(unit.Features) > 0:
+            reasons = append(reasons, fmt.Sprintf("%s needs %d seats with %s, but no room qualifies (largest room seats %d)",
+                unit.Label, unit.Size, strings.Join(unit.Features, ", "), largest))
+        case rooms == 0:
+            reasons = append(reasons, fmt.Sprintf("%s needs %d seats, but the largest room seats %d", unit.Label, unit.Size, largest))
+        default:
+            reasons = append(reasons, fmt.Sprintf("%s has no available time: every candidate slot is blocked", unit.Label))
+        }
+    }
+    return reasons
+}
+
+func (s *solver) search(domains [][]int) bool {
+    unit := s.nextUnit(domains)
+    if unit < 0 {
+        return true
+    }
+
+    for _, option := range domains[unit] {
+        s.steps++
+        if s.steps > s.problem.MaxSteps {
+            s.aborted = true
+            return false
+        }
+        if s.steps%cancelCheckInterval == 0 {
+            if err := s.ctx.Err(); err != nil {
+                s.aborted = true
+                s.err = err
+                return false
+            }
+        }
+
+        next, ok := s.prune(domains, unit, option)
+        if !ok {
+            continue
+        }
+
+        s.assigned[unit] = option
+        if s.search(next) {
+            return true
+        }
+        s.assigned[unit] = -1
+        if s.aborted {
+            return false
+        }
+    }
+    return false
+}
+
+// 选择候选最少的未安排单元，候选数相同时选冲突最多的
+func (s *solver) nextUnit(domains [][]int) int {
+    best := -1
+    for i := range domains {
+        if s.assigned[i] >= 0 {
+            continue
+        }
+        if best < 0 || len(domains[i]) < len(domains[best]) ||
+            (len(domains[i]) == len(domains[best]) && s.degree[i] > s.degree[best]) {
+            best = i
+        }
+    }
+    return best
+}
+
+// 将 unit 安排到 option 后，剪除其他未安排单元中与之冲突的候选。某单元的候选被剪空时返回 false。
+// 没有候选被剪除的单元沿用上一层的候选列表，不复制
+func (s *solver) prune(domains [][]int, unit, option int) ([][]int, bool) {
+    chosen := s.options[unit][option]
+    next := make([][]int, len(domains))
+
+    for other := range domains {
+        next[other] = domains[other]
+        if other == unit || s.assigned[other] >= 0 {
+            continue
+        }
+
+        _, conflicting := s.conflicts[pairKey(unit, other)]
+        sameRoom := s.group[unit] >= 0 && s.group[unit] == s.group[other]
+        var kept []int
+        for i, o := range domains[other] {
+            candidate := s.options[other][o]
+            if (sameRoom && candidate.Room != chosen.Room) ||
+                (candidate.Slot.Overlaps(chosen.Slot) && (conflicting || candidate.Room == chosen.Room)) {
+                if kept == nil {
+                    kept = make([]int, i, len(domains[other]))
+                    copy(kept, domains[other][:i])
+                }
+                continue
+            }
+            if kept != nil {
+                kept = append(kept, o)
+            }
+        }
+        if kept == nil {
+            continue
+        }
+
+        if len(kept) == 0 {
+            s.wipeouts[other]++
+            s.blame[other][unit]++
+            return nil, false
+        }
+        next[other] = kept
+    }
+
+    next[unit] = []int{option}
+    return next, true
+}
+
+// 根据搜索中候选最常被剪空的单元说明无解原因
+func (s *solver) explain() []string {
+    var reasons []string
+    if s.aborted {
+        reasons = append(reasons, fmt.Sprintf("search stopped after %d steps without finding a conflict-free timetable", s.problem.MaxSteps))
+    }
+
+    worst := -1
+    for i, count := range s.wipeouts {
+        if count > 0 && (worst < 0 || count > s.wipeouts[worst]) {
+            worst = i
+        }
+    }
+    if worst < 0 {
+        return append(reasons, "no conflict-free timetable exists for the given rooms and time grid")
+    }
+
+    blamed := make([]int, 0, len(s.blame[worst]))
+    for other := range s.blame[worst] {
+        blamed = append(blamed, other)
+    }
+    sort.Slice(blamed, func(i, j int) bool {
+        if s.blame[worst][blamed[i]] != s.blame[worst][blamed[j]] {
+            return s.blame[worst][blamed[i]] > s.blame[worst][blamed[j]]
+        }
+        return blamed[i] < blamed[j]
+    })
+    if len(blamed) > 3 {
+        blamed = blamed[:3]
+    }
+
+    var causes []string
+    for _, other := range blamed {
+        reason, ok := s.conflicts[pairKey(worst, other)]
+        if !ok {
+            reason = "competing for the same rooms"
+        }
+        causes = append(causes, fmt.Sprintf("%s (%s)", s.problem.Units[other].Label, reason))
+    }
+
+    return append(reasons, fmt.Sprintf("%s could not be placed: its remaining times and rooms are taken by %s",
+        s.problem.Units[worst].Label, strings.Join(causes, ", ")))
+}
+
+func pairKey(a, b int) [2]int {
+    if a > b {
+        a, b = b, a
+    }
+    return [2]int{a, b}
+}
@@ -0,0 +1,177 @@
+package timetable
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 周一 9:00-11:00，只有两个整点开始的一小时时段
+var twoSlotGrid = Grid{Days: 1, DayStart: 9 * 60, DayEnd: 11 * 60, Step: 60}
+
+func TestSolve(t *testing.T) {
+    rooms := []Room{
+        {ID: 1, Label: "A101", Capacity: 30},
+        {ID: 2, Label: "B201", Capacity: 80, Features: []string{"computers"}},
+    }
+
+    tests := []struct {
+        name     string
+        problem  Problem
+        feasible bool
+        reason   string // 无解时 Reasons 中应包含的内容
+    }{
+        {
+            name: "feasible",
+            problem: Problem{
+                Units: []Unit{
+                    {Label: "COMP1117", Duration: 120, Size: 60},
+                    {Label: "COMP1117 LAB1", Duration: 60, Size: 25, Features: []string{"computers"}},
+                    {Label: "MATH1013 (meeting 1 of 2)", Duration: 60, Size: 20},
+                    {Label: "MATH1013 (meeting 2 of 2)", Duration: 60, Size: 20},
+                },
+                Rooms: rooms,
+                Conflicts: []Conflict{
+                    {A: 0, B: 1, Reason: "same course COMP1117"},
+                    {A: 2, B: 3, Reason: "same course MATH1013"},
+                },
+                SameRoom: [][]int{{2, 3}},
+            },
+            feasible: true,
+        },
+        {
+            name: "room too small",
+            problem: Problem{
+                Units: []Unit{{Label: "COMP1117", Duration: 60, Size: 100}},
+                Rooms: rooms,
+            },
+            reason: "COMP1117 needs 100 seats, but the largest room seats 80",
+        },
+        {
+            name: "blocked instructor",
+            problem: Problem{
+                Units: []Unit{{Label: "COMP1117", Duration: 60, Size: 20, Blocked: []Slot{{Day: 0, Start: 9 * 60, End: 11 * 60}}}},
+                Rooms: rooms,
+                Grid:  twoSlotGrid,
+            },
+            reason: "COMP1117 has no available time",
+        },
+        {
+            name: "clash wipeout",
+            problem: Problem{
+                Units: []Unit{
+                    {Label: "COMP1117", Duration: 60, Size: 20},
+                    {Label: "COMP2119", Duration: 60, Size: 20},
+                    {Label: "COMP3230", Duration: 60, Size: 20},
+                },
+                Rooms: rooms,
+                Conflicts: []Conflict{
+                    {A: 0, B: 1, Reason: "both taught by Dr. Lee"},
+                    {A: 0, B: 2, Reason: "both taught by Dr. Lee"},
+                    {A: 1, B: 2, Reason: "both taught by Dr. Lee"},
+                },
+                Grid: twoSlotGrid,
+            },
+            reason: "could not be placed: its remaining times and rooms are taken by",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            result, err := Solve(context.Background(), tt.problem)
+            if err != nil {
+                t.Fatalf("Solve returned error: %v", err)
+            }
+            if result.Feasible != tt.feasible {
+                t.Fatalf("Feasible = %v, want %v (reasons: %v)", result.Feasible, tt.feasible, result.Reasons)
+            }
+            if !tt.feasible {
+                if !strings.Contains(strings.Join(result.Reasons, "; "), tt.reason) {
+                    t.Errorf("Reasons = %v, want one containing %q", result.Reasons, tt.reason)
+                }
+                return
+            }
+            checkPlacements(t, tt.problem, result.Placements)
+        })
+    }
+}
+
+func TestSolveDeterministic(t *testing.T) {
+    problem := Problem{
+        Rooms: []Room{
+            {ID: 1, Label: "A101", Capacity: 40},
+            {ID: 2, Label: "A102", Capacity: 40},
+        },
+    }
+    for i := 0; i < 12; i++ {
+        problem.Units = append(problem.Units, Unit{Label: "U" + string(rune('A'+i)), Duration: 120, Size: 30})
+    }
+    for i := 0; i+1 < len(problem.Units); i += 2 {
+        problem.Conflicts = append(problem.Conflicts, Conflict{A: i, B: i + 1, Reason: "same course"})
+    }
+
+    first, err := Solve(context.Background(), problem)
+    if err != nil || !first.Feasible {
+        t.Fatalf("Solve = %+v, %v; want a feasible timetable", first, err)
+    }
+    for run := 0; run < 5; run++ {
+        again, err := Solve(context.Background(), problem)
+        if err != nil {
+            t.Fatalf("Solve returned error: %v", err)
+        }
+        if !reflect.DeepEqual(first, again) {
+            t.Fatalf("run %d differs:\nfirst: %+v\nagain: %+v", run, first, again)
+        }
+    }
+}
+
+func TestSolveCanceled(t *testing.T) {
+    // 六个互相冲突的单元只有五个时段，需要搜索足够多的步数才能确定无解
+    problem := Problem{Rooms: []Room{{ID: 1, Label: "A101", Capacity: 40}}}
+    for i := 0; i < 6; i++ {
+        problem.Units = append(problem.Units, Unit{Label: "U" + string(rune('A'+i)), Duration: 60, Size: 10})
+        for j := 0; j < i; j++ {
+            problem.Conflicts = append(problem.Conflicts, Conflict{A: j, B: i, Reason: "clash"})
+        }
+    }
+    problem.Grid = Grid{Days: 1, DayStart: 9 * 60, DayEnd: 14 * 60, Step: 10}
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := Solve(ctx, problem); !errors.Is(err, context.Canceled) {
+        t.Fatalf("Solve error = %v, want context.Canceled", err)
+    }
+}
+
+// 检查安排满足所有约束
+func checkPlacements(t *testing.T, p Problem, placements []Placement) {
+    t.Helper()
+    if len(placements) != len(p.Units) {
+        t.Fatalf("got %d placements for %d units", len(placements), len(p.Units))
+    }
+
+    conflicting := make(map[[2]int]bool)
+    for _, c := range p.Conflicts {
+        conflicting[pairKey(c.A, c.B)] = true
+    }
+    for i, a := range placements {
+        if !roomFits(p.Rooms[a.Room], p.Units[i]) {
+            t.Errorf("%s placed in unsuitable room %s", p.Units[i].Label, p.Rooms[a.Room].Label)
+        }
+        for j := i + 1; j < len(placements); j++ {
+            b := placements[j]
+            if a.Slot.Overlaps(b.Slot) && (a.Room == b.Room || conflicting[pairKey(i, j)]) {
+                t.Errorf("%s at %s and %s at %s overlap", p.Units[i].Label, a.Slot, p.Units[j].Label, b.Slot)
+            }
+        }
+    }
+    for _, group := range p.SameRoom {
+        for _, unit := range group[1:] {
+            if placements[unit].Room != placements[group[0]].Room {
+                t.Errorf("%s and %s should share a room", p.Units[group[0]].Label, p.Units[unit].Label)
+            }
+        }
+    }
+}
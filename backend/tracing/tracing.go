@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"course-management/logging"
+	"course-management/requestid"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Config struct {
+    Enabled      bool    `json:"enabled"`
+    ServiceName  string  `json:"service_name"`
+    Exporter     string  `json:"exporter"`      // otlp、stdout 或 file
+    OTLPEndpoint string  `json:"otlp_endpoint"` // 如 localhost:4318，为空时使用 OTEL_EXPORTER_OTLP_* 环境变量
+    OTLPInsecure bool    `json:"otlp_insecure"`
+    FilePath     string  `json:"file_path"`    // exporter 为 file 时的输出文件
+    SampleRatio  float64 `json:"sample_ratio"` // 采样比例 0-1，上游已采样的请求始终记录
+}
+
+// 初始化全局 TracerProvider。未启用时保持默认的空实现，返回的 shutdown 不做任何事情。
+// 服务退出前需调用 shutdown 将缓冲中的 span 导出。
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+    noop := func(context.Context) error { return nil }
+    if !cfg.Enabled {
+        return noop, nil
+    }
+
+    exporter, closeOutput, err := newExporter(ctx, cfg)
+    if err != nil {
+        return noop, err
+    }
+
+    res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+        semconv.SchemaURL,
+        semconv.ServiceName(cfg.ServiceName),
+    ))
+    if err != nil {
+        return noop, fmt.Errorf("failed to build trace resource: %w", err)
+    }
+
+    provider := sdktrace.NewTracerProvider(
+        sdktrace.WithBatcher(exporter),
+        sdktrace.WithResource(res),
+        sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+    )
+
+    otel.SetTracerProvider(provider)
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+        propagation.TraceContext{},
+        propagation.Baggage{},
+    ))
+
+    shutdown := func(ctx context.Context) error {
+        err := provider.Shutdown(ctx)
+        if closeOutput != nil {
+            closeOutput.Close()
+        }
+        return err
+    }
+
+    return shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, io.Closer, error) {
+    switch strings.ToLower(cfg.Exporter) {
+    case "otlp":
+        opts := []otlptracehttp.Option{}
+        if cfg.OTLPEndpoint != "" {
+            opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTLPEndpoint))
+        }
+        if cfg.OTLPInsecure {
+            opts = append(opts, otlptracehttp.WithInsecure())
+        }
+        exporter, err := otlptracehttp.New(ctx, opts...)
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+        }
+        return exporter, nil, nil
+
+    case "stdout":
+        exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+        }
+        return exporter, nil, nil
+
+    case "file":
+        file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+        }
+        exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+        if err != nil {
+            file.Close()
+            return nil, nil, fmt.Errorf("failed to create file exporter: %w", err)
+        }
+        return exporter, file, nil
+
+    default:
+        return nil, nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+    }
+}
+
+// 请求追踪中间件：为每个请求创建 span，并将 trace_id 写入请求日志、将请求ID写入 span。
+// skipPaths 中的路径（如健康检查）不创建 span。需注册在 requestid.Middleware 之后。
+func Middleware(serviceName string, skipPaths ...string) []gin.HandlerFunc {
+    skip := make(map[string]bool, len(skipPaths))
+    for _, path := range skipPaths {
+        skip[path] = true
+    }
+    filter := func(r *http.Request) bool {
+        return !skip[r.URL.Path]
+    }
+
+    return []gin.HandlerFunc{
+        otelgin.Middleware(serviceName, otelgin.WithFilter(filter)),
+        func(c *gin.Context) {
+            span := trace.SpanFromContext(c.Request.Context())
+            if span.SpanContext().IsValid() {
+                span.SetAttributes(attribute.String("http.request_id", requestid.Get(c)))
+
+                ctx := c.Request.Context()
+                logger := logging.FromContext(ctx).With("trace_id", span.SpanContext().TraceID().String())
+                c.Request = c.Request.WithContext(logging.WithContext(ctx, logger))
+            }
+            c.Next()
+        },
+    }
+}
@@ -1,11 +1,17 @@
 package types
 
+import (
+    "encoding/json"
+    "time"
+)
+
 // ==================== API响应结构体 ====================
 // 严格按照Swagger文档定义，并添加管理功能需要的结构体
 
 // 错误响应结构体
 type ErrorResponse struct {
-    Error string `json:"error" example:"查询失败"`
+    Error     string `json:"error" example:"查询失败"`
+    RequestID string `json:"request_id,omitempty" example:"3f2b6c1e9a0d4e7f8b5c2a1d0e9f8a7b"`
 }
 
 // 成功响应结构体
@@ -38,17 +44,38 @@ type CourseDetail struct {
     Semester          string `json:"semester" example:"2024春"`
     TimeSlot          string `json:"time_slot" example:"周一3-4节, 周三5-6节"`
     CourseLocation    string `json:"course_location" example:"教学楼A101"`
+    Category          string `json:"category" example:"core"`
+    RoomID            *int   `json:"room_id" example:"1"`
+    Capacity          *int   `json:"capacity" example:"120"`
+    EnrolledCount     int    `json:"enrolled_count" example:"87"`
 }
 
 // 学生选课信息结构体
 type StudentCourse struct {
-    CourseID   int    `json:"course_id" example:"1"`
-    CourseCode string `json:"course_code" example:"COMP1117"`
-    CourseName string `json:"course_name" example:"Computer programming"`
+    CourseID   int        `json:"course_id" example:"1"`
+    CourseCode string     `json:"course_code" example:"COMP1117"`
+    CourseName string     `json:"course_name" example:"Computer programming"`
+    Status     string     `json:"status" example:"enrolled"`
+    Sections   []string   `json:"sections,omitempty" example:"L1,LAB2"`
+    EnrolledAt time.Time  `json:"enrolled_at" example:"2024-03-01T10:00:00Z"`
+    EndedAt    *time.Time `json:"ended_at,omitempty" example:"2024-03-15T10:00:00Z"`
 }
 
 // ==================== API响应结构体 ====================
 
+// 教学班信息
+type Section struct {
+    ID             int    `json:"id" example:"1"`
+    SectionCode    string `json:"section_code" example:"L1"`
+    SectionType    string `json:"section_type" example:"lecture"`
+    Instructor     string `json:"instructor" example:"Prof. Chen"`
+    TimeSlot       string `json:"time_slot" example:"Mon 9:00-12:00"`
+    CourseLocation string `json:"course_location" example:"CYC LT1"`
+    RoomID         *int   `json:"room_id" example:"1"`
+    Capacity       *int   `json:"capacity" example:"120"`
+    EnrolledCount  int    `json:"enrolled_count" example:"87"`
+}
+
 // 课程列表响应
 type CoursesResponse struct {
     Courses []Course `json:"courses"`
@@ -59,6 +86,315 @@ type CourseDetailResponse struct {
     Course CourseDetail `json:"course"`
 }
 
+// 课程教学班列表响应
+type SectionsResponse struct {
+    CourseID int       `json:"course_id" example:"1"`
+    Sections []Section `json:"sections"`
+}
+
+// 添加教学班响应
+type AddSectionResponse struct {
+    Section Section `json:"section"`
+    Message string  `json:"message" example:"教学班添加成功"`
+}
+
+// 教师信息
+type Instructor struct {
+    ID          int    `json:"id" example:"1"`
+    Name        string `json:"name" example:"Prof. Chen"`
+    Email       string `json:"email,omitempty" example:"chen@cs.hku.hk"`
+    CourseCount int      `json:"course_count" example:"2"`
+    Unavailable []string `json:"unavailable,omitempty" example:"Fri 14:00-18:00"`
+}
+
+// 教师列表响应
+type InstructorsResponse struct {
+    Instructors []Instructor `json:"instructors"`
+}
+
+// 教师详情响应
+type InstructorResponse struct {
+    Instructor Instructor `json:"instructor"`
+}
+
+// 教师任教课程响应
+type InstructorCoursesResponse struct {
+    Instructor Instructor `json:"instructor"`
+    Courses    []Course   `json:"courses"`
+}
+
+// 课程名单中的学生。候补学生的 status 为 waitlisted，enrolled_at 为加入候补的时间
+type RosterStudent struct {
+    ID               int        `json:"id" example:"1"`
+    Name             string     `json:"name" example:"张三"`
+    Email            string     `json:"email" example:"zhangsan@connect.hku.hk"`
+    Status           string     `json:"status" example:"enrolled"`
+    Sections         []string   `json:"sections,omitempty" example:"L1,LAB2"`
+    EnrolledAt       time.Time  `json:"enrolled_at"`
+    EndedAt          *time.Time `json:"ended_at,omitempty"`
+    WaitlistPosition *int       `json:"waitlist_position,omitempty" example:"3"`
+}
+
+// 课程名单响应
+type RosterResponse struct {
+    Course     Course          `json:"course"`
+    Students   []RosterStudent `json:"students"`
+    TotalCount int             `json:"total_count" example:"42"`
+}
+
+// 课程选课学生分页响应。TotalCount 为符合条件的总人数，EnrolledCount 为当前在读人数，
+// WaitlistCount 为排队中的候补人数，课程不限人数时 Capacity 和 AvailableSeats 为空
+type CourseStudentsResponse struct {
+    Course         Course          `json:"course"`
+    Students       []RosterStudent `json:"students"`
+    TotalCount     int             `json:"total_count" example:"42"`
+    Page           int             `json:"page" example:"1"`
+    PageSize       int             `json:"page_size" example:"20"`
+    EnrolledCount  int             `json:"enrolled_count" example:"42"`
+    WaitlistCount  int             `json:"waitlist_count" example:"5"`
+    Capacity       *int            `json:"capacity" example:"120"`
+    AvailableSeats *int            `json:"available_seats" example:"78"`
+}
+
+// 教室信息
+type Room struct {
+    ID       int      `json:"id" example:"1"`
+    Building string   `json:"building" example:"CYC"`
+    Room     string   `json:"room" example:"LT1"`
+    Name     string   `json:"name" example:"CYC LT1"`
+    Capacity int      `json:"capacity" example:"200"`
+    Features []string `json:"features" example:"projector,recording"`
+}
+
+// 教室列表响应
+type RoomsResponse struct {
+    Rooms []Room `json:"rooms"`
+}
+
+// 教室详情响应
+type RoomResponse struct {
+    Room Room `json:"room"`
+}
+
+// 排课方案中的一项
+type TimetableEntry struct {
+    CourseID         int    `json:"course_id" example:"1"`
+    SectionID        int    `json:"section_id,omitempty" example:"3"`
+    Label            string `json:"label" example:"COMP1117 LAB1"`
+    TimeSlot         string `json:"time_slot" example:"Tue 14:00-16:00"`
+    RoomID           int    `json:"room_id" example:"7"`
+    Location         string `json:"course_location" example:"CB Lab 1"`
+    PreviousTimeSlot string `json:"previous_time_slot" example:"Thu 14:00-16:00"`
+    PreviousLocation string `json:"previous_course_location" example:"Lab 2"`
+    Changed          bool   `json:"changed" example:"true"`
+}
+
+// 排课方案响应
+type TimetableResponse struct {
+    Semester    string           `json:"semester" example:"2024 Spring"`
+    Feasible    bool             `json:"feasible" example:"true"`
+    Fingerprint string           `json:"fingerprint,omitempty" example:"9c1e5b7a0d3f2e41"`
+    Entries     []TimetableEntry `json:"entries"`
+    Reasons     []string         `json:"reasons,omitempty"`
+}
+
+// 选课方案中的一门课程及选择的教学班
+type PlannedCourse struct {
+    CourseID   int       `json:"course_id" example:"1"`
+    CourseCode string    `json:"course_code" example:"COMP1117"`
+    CourseName string    `json:"course_name" example:"Computer Programming"`
+    TimeSlot   string    `json:"time_slot" example:"Mon 9:00-12:00"`
+    Sections   []Section `json:"sections"`
+}
+
+// 选课方案的时间特征
+type PlanStats struct {
+    EarlyStarts   int `json:"early_starts" example:"1"`
+    FridayClasses int `json:"friday_classes" example:"0"`
+    TeachingDays  int `json:"teaching_days" example:"3"`
+    IdleMinutes   int `json:"idle_minutes" example:"60"`
+}
+
+// 一个无时间冲突的选课方案，penalty 越小越符合偏好
+type Plan struct {
+    Penalty int             `json:"penalty" example:"15"`
+    Stats   PlanStats       `json:"stats"`
+    Courses []PlannedCourse `json:"courses"`
+}
+
+// 选课规划响应
+type PlanResponse struct {
+    Semester  string   `json:"semester" example:"2024 Spring"`
+    Total     int      `json:"total" example:"12"`
+    Truncated bool     `json:"truncated" example:"false"`
+    Plans     []Plan   `json:"plans"`
+    Reasons   []string `json:"reasons,omitempty"`
+    Notes     []string `json:"notes,omitempty"`
+}
+
+// 购物车中的一门课程
+type CartItem struct {
+    CourseID   int       `json:"course_id" example:"1"`
+    CourseCode string    `json:"course_code" example:"COMP1117"`
+    CourseName string    `json:"course_name" example:"Computer Programming"`
+    Credits    int       `json:"credits" example:"3"`
+    Semester   string    `json:"semester" example:"2024 Spring"`
+    TimeSlot   string    `json:"time_slot" example:"Mon 9:00-12:00"`
+    SectionIDs []int     `json:"section_ids" example:"1,3"`
+    AddedAt    time.Time `json:"added_at" example:"2024-03-01T10:00:00Z"`
+}
+
+// 购物车响应
+type CartResponse struct {
+    StudentID    int        `json:"student_id" example:"1"`
+    Items        []CartItem `json:"items"`
+    TotalCredits int        `json:"total_credits" example:"7"`
+}
+
+// 购物车中一门课程的校验或结算结果
+type CartItemResult struct {
+    CourseID   int    `json:"course_id" example:"2"`
+    CourseCode string `json:"course_code" example:"COMP2119"`
+    Status     string `json:"status" example:"failed"`                // valid, enrolled, failed, not_enrolled
+    Reason     string `json:"reason,omitempty" example:"time_clash"` // 失败原因代码
+    Error      string `json:"error,omitempty" example:"course time clashes with an enrolled course: COMP2119 clashes with COMP1117 at Mon 9:00-12:00"`
+}
+
+// 购物车校验或结算响应
+type CheckoutResponse struct {
+    Mode      string           `json:"mode,omitempty" example:"all_or_nothing"`
+    Committed bool             `json:"committed" example:"false"`
+    Message   string           `json:"message" example:"有课程不满足选课条件，未选任何课程"`
+    Items     []CartItemResult `json:"items"`
+}
+
+// 选课轮次。seed 在分配后公开，用于核查抽签结果
+type RegistrationRound struct {
+    ID              int        `json:"id" example:"1"`
+    Name            string     `json:"name" example:"2024 秋季第一轮"`
+    Semester        string     `json:"semester" example:"2024-Fall"`
+    OpensAt         time.Time  `json:"opens_at" example:"2024-08-01T00:00:00Z"`
+    ClosesAt        time.Time  `json:"closes_at" example:"2024-08-08T00:00:00Z"`
+    Status          string     `json:"status" example:"allocated"`
+    Seed            *int64     `json:"seed,omitempty" example:"20240801"`
+    AllocationError string     `json:"allocation_error,omitempty" example:"failed to record round result: query timed out"`
+    AllocatedAt     *time.Time `json:"allocated_at,omitempty" example:"2024-08-08T09:00:00Z"`
+    CreatedAt       time.Time  `json:"created_at" example:"2024-07-20T10:00:00Z"`
+}
+
+// 选课轮次列表响应
+type RegistrationRoundsResponse struct {
+    Rounds []RegistrationRound `json:"rounds"`
+}
+
+// 选课轮次详情响应
+type RegistrationRoundResponse struct {
+    Round RegistrationRound `json:"round"`
+}
+
+// 学生的一个志愿
+type RoundPreference struct {
+    Rank       int    `json:"rank" example:"1"`
+    CourseID   int    `json:"course_id" example:"1"`
+    CourseCode string `json:"course_code" example:"COMP1117"`
+    CourseName string `json:"course_name" example:"Computer programming"`
+    SectionIDs []int  `json:"section_ids" example:"1"`
+}
+
+// 学生志愿响应
+type RoundPreferencesResponse struct {
+    RoundID     int               `json:"round_id" example:"1"`
+    StudentID   int               `json:"student_id" example:"1"`
+    Preferences []RoundPreference `json:"preferences"`
+}
+
+// 一个志愿的分配结果
+type RoundResult struct {
+    StudentID     int    `json:"student_id" example:"1"`
+    CourseID      int    `json:"course_id" example:"1"`
+    CourseCode    string `json:"course_code" example:"COMP1117"`
+    Rank          int    `json:"rank" example:"1"`
+    Priority      int    `json:"priority" example:"0"`
+    EarnedCredits int    `json:"earned_credits" example:"24"`
+    Ticket        int    `json:"ticket" example:"17"`
+    Outcome       string `json:"outcome" example:"failed"`
+    Reason        string `json:"reason,omitempty" example:"course is full (COMP1117, 60 seats)"`
+}
+
+// 分配结果响应
+type RoundResultsResponse struct {
+    Round   RegistrationRound `json:"round"`
+    Results []RoundResult     `json:"results"`
+}
+
+// 选课申请。overrides 为审批通过时豁免的规则
+type Petition struct {
+    ID         int        `json:"id" example:"1"`
+    StudentID  int        `json:"student_id" example:"1"`
+    CourseID   int        `json:"course_id" example:"2"`
+    CourseCode string     `json:"course_code" example:"COMP2119"`
+    SectionIDs []int      `json:"section_ids" example:"3"`
+    Overrides  []string   `json:"overrides" example:"capacity"`
+    Reason     string     `json:"reason" example:"毕业前最后一学期，必须修读该课程"`
+    Status     string     `json:"status" example:"pending"`
+    ReviewedBy string     `json:"reviewed_by,omitempty" example:"instructor:1"`
+    ReviewNote string     `json:"review_note,omitempty" example:"同意"`
+    CreatedAt  time.Time  `json:"created_at" example:"2024-03-01T10:00:00Z"`
+    ReviewedAt *time.Time `json:"reviewed_at,omitempty" example:"2024-03-02T10:00:00Z"`
+}
+
+// 候补记录。position 为排队中的位次，已结束的候补为空；reason 为 skipped 时的失败原因
+type WaitlistEntry struct {
+    ID         int        `json:"id" example:"1"`
+    StudentID  int        `json:"student_id" example:"1"`
+    CourseID   int        `json:"course_id" example:"2"`
+    CourseCode string     `json:"course_code" example:"COMP2119"`
+    SectionIDs []int      `json:"section_ids" example:"3"`
+    Status     string     `json:"status" example:"waiting"`
+    Reason     string     `json:"reason,omitempty" example:"semester credit limit exceeded: 21 credits in 2024-Fall, limit is 20"`
+    Position   *int       `json:"position,omitempty" example:"3"`
+    CreatedAt  time.Time  `json:"created_at" example:"2024-03-01T10:00:00Z"`
+    EndedAt    *time.Time `json:"ended_at,omitempty" example:"2024-03-02T10:00:00Z"`
+}
+
+// 学生候补列表响应
+type WaitlistResponse struct {
+    Entries []WaitlistEntry `json:"entries"`
+}
+
+// 加入候补响应
+type WaitlistEntryResponse struct {
+    Entry WaitlistEntry `json:"entry"`
+}
+
+// 选课申请列表响应
+type PetitionsResponse struct {
+    Petitions []Petition `json:"petitions"`
+}
+
+// 选课申请响应
+type PetitionResponse struct {
+    Petition Petition `json:"petition"`
+}
+
+// 批量操作中一个学生的结果
+type BulkItemResult struct {
+    StudentID  int    `json:"student_id" example:"1"`
+    CourseID   int    `json:"course_id" example:"2"`
+    CourseCode string `json:"course_code" example:"COMP2119"`
+    Status     string `json:"status" example:"failed"`                 // enrolled, failed, not_enrolled
+    Reason     string `json:"reason,omitempty" example:"course_full"` // 失败原因代码
+    Error      string `json:"error,omitempty" example:"course is full (COMP2119, 60 seats)"`
+}
+
+// 批量选课操作响应
+type BulkEnrollmentResponse struct {
+    Mode      string           `json:"mode" example:"best_effort"`
+    Committed bool             `json:"committed" example:"true"`
+    Message   string           `json:"message" example:"已处理 3 名学生，成功 2 名，失败 1 名"`
+    Items     []BulkItemResult `json:"items"`
+}
+
 // 学生列表响应
 type StudentsResponse struct {
     Students []Student `json:"students"`
@@ -83,6 +419,9 @@ type AddCourseRequest struct {
     Semester          string `json:"semester" example:"2024春"`
     TimeSlot          string `json:"time_slot" example:"周一3-4节, 周三5-6节"`
     CourseLocation    string `json:"course_location" example:"教学楼A101"`
+    Category          string `json:"category" example:"core"`
+    RoomID            int    `json:"room_id" example:"1"`    // 关联教室，设置后 course_location 取教室名称
+    Capacity          int    `json:"capacity" example:"120"` // 课程容量，0 表示不限
 }
 
 // 添加课程响应
@@ -97,8 +436,308 @@ type AddStudentRequest struct {
     Email string `json:"email" binding:"required,email" example:"zhangsan@connect.hku.hk"`
 }
 
+// 选课请求，课程没有教学班或每种类型只有一个教学班时可省略
+type EnrollRequest struct {
+    SectionIDs []int `json:"section_ids" example:"1,3"`
+}
+
+// 添加教学班请求
+type AddSectionRequest struct {
+    SectionCode    string `json:"section_code" binding:"required" example:"LAB1"`
+    SectionType    string `json:"section_type" binding:"required" example:"lab"`
+    Instructor     string `json:"instructor" example:"Dr. Chan"`
+    TimeSlot       string `json:"time_slot" example:"Thu 14:00-16:00"`
+    CourseLocation string `json:"course_location" example:"Lab 2"`
+    Capacity       *int   `json:"capacity" example:"30"`
+}
+
+// 添加教师请求
+type AddInstructorRequest struct {
+    Name  string `json:"name" binding:"required" example:"Prof. Chen"`
+    Email string `json:"email" binding:"omitempty,email" example:"chen@cs.hku.hk"`
+}
+
+// 添加教室请求
+type AddRoomRequest struct {
+    Building string   `json:"building" binding:"required" example:"CYC"`
+    Room     string   `json:"room" binding:"required" example:"LT1"`
+    Capacity int      `json:"capacity" binding:"required" example:"200"`
+    Features []string `json:"features" example:"projector,recording"`
+}
+
+// 修改课程排课请求 (管理员功能)，省略的字段保持不变
+type UpdateCourseScheduleRequest struct {
+    TimeSlot *string `json:"time_slot" example:"Tue 9:00-12:00"`
+    RoomID   *int    `json:"room_id" example:"2"`
+    Capacity *int    `json:"capacity" example:"150"`
+}
+
+// 设置教师不可用时间请求
+type InstructorUnavailabilityRequest struct {
+    TimeSlots []string `json:"time_slots" example:"Fri 14:00-18:00"`
+}
+
+// 生成或确认排课方案请求，确认时 fingerprint 为预览返回的值
+type TimetableRequest struct {
+    Semester    string `json:"semester" binding:"required" example:"2024 Spring"`
+    Fingerprint string `json:"fingerprint" example:"9c1e5b7a0d3f2e41"`
+}
+
+// 换课请求，section_ids 为新课程的教学班
+type SwapCourseRequest struct {
+    ToCourseID int   `json:"to_course_id" binding:"required,min=1" example:"5"`
+    SectionIDs []int `json:"section_ids" example:"7"`
+}
+
+// 加入购物车请求，section_ids 为选择的教学班
+type CartItemRequest struct {
+    SectionIDs []int `json:"section_ids" example:"1,3"`
+}
+
+// 结算请求，mode 默认为 all_or_nothing
+type CheckoutRequest struct {
+    Mode string `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort" example:"best_effort"`
+}
+
+// 设置先修课程请求
+type PrerequisitesRequest struct {
+    CourseCodes []string `json:"course_codes" example:"COMP1117"`
+}
+
+// 先修课程响应
+type PrerequisitesResponse struct {
+    CourseID    int      `json:"course_id" example:"2"`
+    CourseCodes []string `json:"course_codes" example:"COMP1117"`
+}
+
+// 选课规划的偏好
+type PlanPreferences struct {
+    NoEarlyMornings bool `json:"no_early_mornings" example:"true"`
+    CompactDays     bool `json:"compact_days" example:"false"`
+    FreeFridays     bool `json:"free_fridays" example:"true"`
+}
+
+// 选课规划请求
+type PlanRequest struct {
+    CourseIDs   []int           `json:"course_ids" binding:"required,min=1,max=10" example:"1,2"` // 最多 10 门
+    Preferences PlanPreferences `json:"preferences"`
+    Limit       int             `json:"limit" example:"10"` // 返回的方案数，默认 10，最多 50
+}
+
+// 创建选课轮次请求，seed 为 0 时随机生成
+type CreateRoundRequest struct {
+    Name     string    `json:"name" binding:"required" example:"2024 秋季第一轮"`
+    Semester string    `json:"semester" binding:"required" example:"2024-Fall"`
+    OpensAt  time.Time `json:"opens_at" binding:"required" example:"2024-08-01T00:00:00Z"`
+    ClosesAt time.Time `json:"closes_at" binding:"required" example:"2024-08-08T00:00:00Z"`
+    Seed     int64     `json:"seed" example:"20240801"`
+}
+
+// 提交志愿请求，列表顺序即志愿顺序
+type RoundPreferencesRequest struct {
+    Preferences []RoundPreferenceRequest `json:"preferences" binding:"dive"`
+}
+
+// 提交的一个志愿
+type RoundPreferenceRequest struct {
+    CourseID   int   `json:"course_id" binding:"required,min=1" example:"1"`
+    SectionIDs []int `json:"section_ids" example:"1"`
+}
+
+// 提交选课申请请求
+type SubmitPetitionRequest struct {
+    CourseID   int    `json:"course_id" binding:"required,min=1" example:"2"`
+    SectionIDs []int  `json:"section_ids" example:"3"`
+    Reason     string `json:"reason" binding:"required" example:"毕业前最后一学期，必须修读该课程"`
+}
+
+// 审批选课申请请求
+type ReviewPetitionRequest struct {
+    Decision string `json:"decision" binding:"required,oneof=approve reject" example:"approve"`
+    Note     string `json:"note" example:"同意"`
+}
+
+// 批量选课请求，mode 默认为 all_or_nothing
+type BulkEnrollRequest struct {
+    StudentIDs []int  `json:"student_ids" binding:"required,min=1" example:"1,2,3"`
+    SectionIDs []int  `json:"section_ids" example:"3"`
+    Mode       string `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort" example:"best_effort"`
+}
+
+// 批量转课请求。from_section_id 不为 0 时只转该教学班的学生；转到同一课程时用于合并教学班
+type MoveEnrollmentsRequest struct {
+    ToCourseID    int    `json:"to_course_id" binding:"required,min=1" example:"2"`
+    FromSectionID int    `json:"from_section_id" binding:"min=0" example:"4"`
+    ToSectionIDs  []int  `json:"to_section_ids" example:"3"`
+    Mode          string `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort" example:"best_effort"`
+}
+
+// 跨学期复制选课请求，course_codes 为空时复制所有课程
+type CopyEnrollmentsRequest struct {
+    FromSemester string   `json:"from_semester" binding:"required" example:"2024-Fall"`
+    ToSemester   string   `json:"to_semester" binding:"required" example:"2025-Spring"`
+    CourseCodes  []string `json:"course_codes" example:"COMP3297"`
+    Mode         string   `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort" example:"best_effort"`
+}
+
+// 修改选课状态请求 (管理员功能)
+type UpdateEnrollmentStatusRequest struct {
+    Status string `json:"status" binding:"required" example:"withdrawn"`
+}
+
 // 添加学生响应
 type AddStudentResponse struct {
     Student Student `json:"student"`
     Message string  `json:"message" example:"学生添加成功"`
-}
\ No newline at end of file
+}
+
+// ==================== 健康检查响应 ====================
+
+// 健康检查响应
+type HealthResponse struct {
+    Status string            `json:"status" example:"ok"`
+    Checks map[string]string `json:"checks,omitempty"`
+}
+
+// ==================== 成绩响应 ====================
+
+// 成绩单中的一门课程
+type TranscriptCourse struct {
+    CourseID    int      `json:"course_id" example:"1"`
+    CourseCode  string   `json:"course_code" example:"COMP1117"`
+    CourseName  string   `json:"course_name" example:"Computer programming"`
+    Credits     int      `json:"credits" example:"3"`
+    Status      string   `json:"status" example:"completed"`
+    Grade       *string  `json:"grade" example:"A-"`
+    GradePoints *float64 `json:"grade_points" example:"3.7"`
+    PassFail    bool     `json:"pass_fail" example:"false"`
+}
+
+// 成绩单中的一个学期
+type TranscriptSemester struct {
+    Semester      string             `json:"semester" example:"2024 Spring"`
+    Courses       []TranscriptCourse `json:"courses"`
+    GPA           *float64           `json:"gpa" example:"3.52"`
+    GPACredits    int                `json:"gpa_credits" example:"10"`
+    CreditsEarned int                `json:"credits_earned" example:"13"`
+}
+
+// 成绩单响应
+type TranscriptResponse struct {
+    Student       Student              `json:"student"`
+    Semesters     []TranscriptSemester `json:"semesters"`
+    GPA           *float64             `json:"cumulative_gpa" example:"3.52"`
+    GPACredits    int                  `json:"gpa_credits" example:"10"`
+    CreditsEarned int                  `json:"credits_earned" example:"13"`
+}
+
+// 录入成绩请求
+type RecordGradeRequest struct {
+    Grade    string `json:"grade" binding:"required" example:"A-"`
+    PassFail bool   `json:"pass_fail" example:"false"`
+}
+
+// 录入成绩响应
+type RecordGradeResponse struct {
+    Grade       string   `json:"grade" example:"A-"`
+    GradePoints *float64 `json:"grade_points" example:"3.7"`
+    PassFail    bool     `json:"pass_fail" example:"false"`
+    Amended     bool     `json:"amended" example:"false"`
+    Message     string   `json:"message" example:"成绩已录入"`
+}
+
+// ==================== 培养方案响应 ====================
+
+// 毕业要求
+type Requirement struct {
+    ID          int      `json:"id" example:"1"`
+    Name        string   `json:"requirement_name" example:"专业核心课程"`
+    RuleType    string   `json:"rule_type" example:"courses"`
+    CourseCodes []string `json:"course_codes" example:"COMP1117,COMP2119"`
+    Category    string   `json:"category,omitempty" example:"elective"`
+    MinCourses  int      `json:"min_courses,omitempty" example:"2"`
+    MinCredits  int      `json:"min_credits,omitempty" example:"6"`
+}
+
+// 培养方案
+type Programme struct {
+    ID           int           `json:"id" example:"1"`
+    Code         string        `json:"programme_code" example:"BSC-CS"`
+    Name         string        `json:"programme_name" example:"Bachelor of Science in Computer Science"`
+    Type         string        `json:"programme_type" example:"major"`
+    Requirements []Requirement `json:"requirements"`
+}
+
+// 培养方案列表响应
+type ProgrammesResponse struct {
+    Programmes []Programme `json:"programmes"`
+}
+
+// 培养方案详情响应
+type ProgrammeResponse struct {
+    Programme Programme `json:"programme"`
+}
+
+// 添加培养方案请求
+type AddProgrammeRequest struct {
+    Code         string                  `json:"programme_code" binding:"required" example:"BSC-CS"`
+    Name         string                  `json:"programme_name" binding:"required" example:"Bachelor of Science in Computer Science"`
+    Type         string                  `json:"programme_type" binding:"required" example:"major"`
+    Requirements []AddRequirementRequest `json:"requirements"`
+}
+
+// 添加培养方案时的毕业要求
+type AddRequirementRequest struct {
+    Name        string   `json:"requirement_name" binding:"required" example:"专业核心课程"`
+    RuleType    string   `json:"rule_type" binding:"required" example:"courses"`
+    CourseCodes []string `json:"course_codes" example:"COMP1117,COMP2119"`
+    Category    string   `json:"category" example:"elective"`
+    MinCourses  int      `json:"min_courses" example:"2"`
+    MinCredits  int      `json:"min_credits" example:"6"`
+}
+
+// 单条毕业要求的审核结果
+type RequirementProgress struct {
+    Requirement       Requirement `json:"requirement"`
+    Status            string      `json:"status" example:"in_progress"`
+    CompletedCourses  []string    `json:"completed_courses" example:"COMP1117"`
+    InProgressCourses []string    `json:"in_progress_courses" example:"COMP2119"`
+    MissingCourses    []string    `json:"missing_courses" example:"COMP3234"`
+    CreditsCompleted  int         `json:"credits_completed" example:"3"`
+    CreditsInProgress int         `json:"credits_in_progress" example:"4"`
+}
+
+// 一个培养方案的毕业审核结果
+type DegreeAudit struct {
+    Programme    Programme             `json:"programme"`
+    DeclaredAt   time.Time             `json:"declared_at" example:"2024-03-01T10:00:00Z"`
+    Status       string                `json:"status" example:"in_progress"`
+    Requirements []RequirementProgress `json:"requirements"`
+}
+
+// 毕业审核响应
+type DegreeAuditResponse struct {
+    Student Student       `json:"student"`
+    Audits  []DegreeAudit `json:"audits"`
+}
+
+// ==================== 管理功能响应 ====================
+
+// 审计事件
+type AuditEvent struct {
+    ID        int64           `json:"id" example:"1"`
+    Actor     string          `json:"actor" example:"student:1"`
+    Action    string          `json:"action" example:"enrollment.created"`
+    StudentID *int            `json:"student_id" example:"1"`
+    CourseID  *int            `json:"course_id" example:"1"`
+    Before    json.RawMessage `json:"before"`
+    After     json.RawMessage `json:"after"`
+    RequestID string          `json:"request_id,omitempty" example:"3f2b6c1e9a0d4e7f8b5c2a1d0e9f8a7b"`
+    CreatedAt time.Time       `json:"created_at" example:"2024-03-01T10:00:00Z"`
+}
+
+// 审计日志查询响应
+type AuditEventsResponse struct {
+    Events     []AuditEvent `json:"events"`
+    TotalCount int          `json:"total_count" example:"1"`
+}
@@ -1,6 +1,26 @@
+DROP TABLE IF EXISTS waitlist_entries;
+DROP TABLE IF EXISTS enrollment_petitions;
+DROP TABLE IF EXISTS round_results;
+DROP TABLE IF EXISTS round_preferences;
+DROP TABLE IF EXISTS registration_rounds;
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS course_prerequisites;
+DROP TABLE IF EXISTS instructor_unavailability;
+DROP TABLE IF EXISTS course_instructors;
+DROP TABLE IF EXISTS instructors;
+DROP TABLE IF EXISTS enrollment_sections;
+DROP TABLE IF EXISTS course_sections;
+DROP TABLE IF EXISTS student_programmes;
+DROP TABLE IF EXISTS programme_requirements;
+DROP TABLE IF EXISTS programmes;
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS schema_migrations;
 DROP TABLE IF EXISTS student_courses;
 DROP TABLE IF EXISTS students;
 DROP TABLE IF EXISTS courses;
+DROP TABLE IF EXISTS rooms;
 
 CREATE TABLE students (
     id SERIAL PRIMARY KEY,
@@ -9,6 +29,16 @@ CREATE TABLE students (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE rooms (
+    id SERIAL PRIMARY KEY,
+    building VARCHAR(100) NOT NULL,
+    room VARCHAR(50) NOT NULL,
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    features TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (building, room)
+);
+
 CREATE TABLE courses (
     id SERIAL PRIMARY KEY,
     course_code VARCHAR(20) NOT NULL,
@@ -19,6 +49,9 @@ CREATE TABLE courses (
     semester VARCHAR(20),
     time_slot VARCHAR(100),
     course_location VARCHAR(100),
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    room_id INTEGER REFERENCES rooms(id) ON DELETE SET NULL,
+    capacity INTEGER CHECK (capacity > 0),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -26,12 +59,216 @@ CREATE TABLE student_courses (
     id SERIAL PRIMARY KEY,
     student_id INTEGER REFERENCES students(id) ON DELETE CASCADE,
     course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'enrolled',
     enrolled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE(student_id, course_id)
+    ended_at TIMESTAMP,
+    grade VARCHAR(2),
+    grade_points NUMERIC(2, 1),
+    pass_fail BOOLEAN NOT NULL DEFAULT false,
+    graded_at TIMESTAMP,
+    CONSTRAINT student_courses_status_check
+        CHECK (status IN ('enrolled', 'dropped', 'withdrawn', 'removed_by_admin', 'moved', 'completed')),
+    CONSTRAINT student_courses_grade_check
+        CHECK (grade IN ('A+', 'A', 'A-', 'B+', 'B', 'B-', 'C+', 'C', 'C-', 'D+', 'D', 'F', 'P'))
+);
+
+CREATE TABLE idempotency_keys (
+    idempotency_key VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(500) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(100),
+    response_body BYTEA,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (idempotency_key, method, path)
+);
+
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    student_id INTEGER,
+    course_id INTEGER,
+    before_data JSONB,
+    after_data JSONB,
+    request_id VARCHAR(64),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TABLE programmes (
+    id SERIAL PRIMARY KEY,
+    programme_code VARCHAR(20) NOT NULL UNIQUE,
+    programme_name VARCHAR(200) NOT NULL,
+    programme_type VARCHAR(10) NOT NULL CHECK (programme_type IN ('major', 'minor')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE programme_requirements (
+    id SERIAL PRIMARY KEY,
+    programme_id INTEGER NOT NULL REFERENCES programmes(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    requirement_name VARCHAR(200) NOT NULL,
+    rule_type VARCHAR(10) NOT NULL CHECK (rule_type IN ('courses', 'credits')),
+    course_codes TEXT[] NOT NULL DEFAULT '{}',
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    min_courses INTEGER,
+    min_credits INTEGER
+);
+
+CREATE TABLE student_programmes (
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    programme_id INTEGER NOT NULL REFERENCES programmes(id) ON DELETE CASCADE,
+    declared_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (student_id, programme_id)
+);
+
+CREATE TABLE course_sections (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    section_code VARCHAR(20) NOT NULL,
+    section_type VARCHAR(10) NOT NULL CHECK (section_type IN ('lecture', 'tutorial', 'lab')),
+    instructor VARCHAR(100),
+    time_slot VARCHAR(100),
+    course_location VARCHAR(100),
+    capacity INTEGER CHECK (capacity > 0),
+    room_id INTEGER REFERENCES rooms(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (course_id, section_code)
+);
+
+CREATE TABLE enrollment_sections (
+    enrollment_id INTEGER NOT NULL REFERENCES student_courses(id) ON DELETE CASCADE,
+    section_id INTEGER NOT NULL REFERENCES course_sections(id) ON DELETE CASCADE,
+    PRIMARY KEY (enrollment_id, section_id)
+);
+
+CREATE TABLE instructors (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    email VARCHAR(255) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE course_instructors (
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+    PRIMARY KEY (course_id, instructor_id)
+);
+
+CREATE TABLE instructor_unavailability (
+    id SERIAL PRIMARY KEY,
+    instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+    time_slot VARCHAR(100) NOT NULL
+);
+
+CREATE TABLE course_prerequisites (
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    prerequisite_code VARCHAR(20) NOT NULL,
+    PRIMARY KEY (course_id, prerequisite_code)
+);
+
+CREATE TABLE cart_items (
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    section_ids INTEGER[] NOT NULL DEFAULT '{}',
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (student_id, course_id)
+);
+
+CREATE TABLE registration_rounds (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    semester VARCHAR(20) NOT NULL,
+    opens_at TIMESTAMP NOT NULL,
+    closes_at TIMESTAMP NOT NULL,
+    seed BIGINT NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'allocating', 'allocated')),
+    allocation_error TEXT NOT NULL DEFAULT '',
+    allocated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (closes_at > opens_at)
+);
+
+CREATE TABLE round_preferences (
+    round_id INTEGER NOT NULL REFERENCES registration_rounds(id) ON DELETE CASCADE,
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL CHECK (rank > 0),
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    section_ids INTEGER[] NOT NULL DEFAULT '{}',
+    PRIMARY KEY (round_id, student_id, rank),
+    UNIQUE (round_id, student_id, course_id)
+);
+
+CREATE TABLE round_results (
+    round_id INTEGER NOT NULL REFERENCES registration_rounds(id) ON DELETE CASCADE,
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL,
+    priority INTEGER NOT NULL,
+    earned_credits INTEGER NOT NULL,
+    ticket INTEGER NOT NULL,
+    outcome VARCHAR(10) NOT NULL CHECK (outcome IN ('enrolled', 'failed')),
+    reason TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (round_id, student_id, course_id)
+);
+
+CREATE TABLE enrollment_petitions (
+    id SERIAL PRIMARY KEY,
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    section_ids INTEGER[] NOT NULL DEFAULT '{}',
+    overrides TEXT[] NOT NULL DEFAULT '{}',
+    reason TEXT NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewed_by VARCHAR(50),
+    review_note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP
+);
+
+CREATE TABLE waitlist_entries (
+    id SERIAL PRIMARY KEY,
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    section_ids INTEGER[] NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'promoted', 'enrolled_directly', 'left', 'skipped', 'removed')),
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMPTZ
 );
 
 CREATE INDEX idx_student_courses_student_id ON student_courses(student_id);
 CREATE INDEX idx_student_courses_course_id ON student_courses(course_id);
 CREATE INDEX idx_students_email ON students(email);
 CREATE INDEX idx_courses_code ON courses(course_code);
-CREATE INDEX idx_courses_semester ON courses(semester);
\ No newline at end of file
+CREATE INDEX idx_courses_semester ON courses(semester);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+CREATE INDEX idx_audit_events_student_id ON audit_events(student_id, created_at);
+CREATE INDEX idx_audit_events_course_id ON audit_events(course_id, created_at);
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+CREATE UNIQUE INDEX idx_student_courses_active ON student_courses(student_id, course_id) WHERE status = 'enrolled';
+CREATE INDEX idx_courses_category ON courses(category);
+CREATE INDEX idx_programme_requirements_programme_id ON programme_requirements(programme_id);
+CREATE INDEX idx_course_sections_course_id ON course_sections(course_id);
+CREATE INDEX idx_enrollment_sections_section_id ON enrollment_sections(section_id);
+CREATE INDEX idx_course_instructors_instructor_id ON course_instructors(instructor_id);
+CREATE INDEX idx_courses_room_id ON courses(room_id);
+CREATE INDEX idx_instructor_unavailability_instructor_id ON instructor_unavailability(instructor_id);
+CREATE INDEX idx_cart_items_course_id ON cart_items(course_id);
+CREATE UNIQUE INDEX idx_registration_rounds_pending_semester ON registration_rounds(semester) WHERE status <> 'allocated';
+CREATE UNIQUE INDEX idx_enrollment_petitions_pending ON enrollment_petitions(student_id, course_id) WHERE status = 'pending';
+CREATE INDEX idx_enrollment_petitions_course_id ON enrollment_petitions(course_id);
+CREATE UNIQUE INDEX idx_waitlist_entries_waiting ON waitlist_entries(student_id, course_id) WHERE status = 'waiting';
+CREATE INDEX idx_waitlist_entries_course_queue ON waitlist_entries(course_id, id) WHERE status = 'waiting';
\ No newline at end of file